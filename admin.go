@@ -0,0 +1,36 @@
+package main
+
+import (
+	"bookpulse/internal/config"
+	"bookpulse/internal/repo"
+	"bookpulse/internal/service/auth"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+)
+
+// runAdmin implements `bookpulse admin bootstrap <email>`, which promotes
+// the first admin. Once an admin exists, roles are managed through the API.
+func runAdmin(cfg *config.Config, args []string) {
+	if len(args) != 2 || args[0] != "bootstrap" {
+		fmt.Fprintln(os.Stderr, "usage: bookpulse [-config file] admin bootstrap <email>")
+		os.Exit(2)
+	}
+
+	st := openStores(cfg)
+	authSvc := auth.NewServicePGX(st.auth, nil, nil, nil, auth.Options{})
+
+	u, err := authSvc.BootstrapAdmin(context.Background(), args[1])
+	switch {
+	case errors.Is(err, repo.ErrAdminExists):
+		log.Fatal("admin bootstrap: an admin already exists; use PATCH /api/admin/users/{id}/role")
+	case errors.Is(err, auth.ErrUserNotFound):
+		log.Fatalf("admin bootstrap: no user with email %q; register first", args[1])
+	case err != nil:
+		log.Fatal("admin bootstrap: ", err)
+	}
+	st.close()
+	fmt.Printf("user %d (%s) is now admin\n", u.ID, u.Email)
+}
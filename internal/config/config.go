@@ -0,0 +1,668 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net/netip"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	EnvDev     = "dev"
+	EnvStaging = "staging"
+	EnvProd    = "prod"
+)
+
+// DefaultJWTSecret is only accepted when Env is "dev".
+const DefaultJWTSecret = "dev_secret_change_me"
+
+type Config struct {
+	Env     string        `yaml:"env"`
+	HTTP    HTTPConfig    `yaml:"http"`
+	DB      DBConfig      `yaml:"db"`
+	JWT     JWTConfig     `yaml:"jwt"`
+	CORS    CORSConfig    `yaml:"cors"`
+	Google  GoogleConfig  `yaml:"google"`
+	App     AppConfig     `yaml:"app"`
+	Auth    AuthConfig    `yaml:"auth"`
+	Mail    MailConfig    `yaml:"mail"`
+	OIDC    OIDCConfig    `yaml:"oidc"`
+	Account AccountConfig `yaml:"account"`
+}
+
+type HTTPConfig struct {
+	Addr string `yaml:"addr"`
+	// TrustProxy takes the client IP from X-Forwarded-For / X-Real-IP on
+	// requests from TrustedProxies.
+	TrustProxy bool `yaml:"trustProxy"`
+	// TrustedProxies are the IP addresses or CIDR ranges of the reverse
+	// proxies in front of the server. Loopback only by default.
+	TrustedProxies []string `yaml:"trustedProxies"`
+	// AuthRateLimit caps requests per client IP to /api/auth/*.
+	AuthRateLimit RateLimitConfig `yaml:"authRateLimit"`
+	// MaxBodyBytes caps JSON request bodies.
+	MaxBodyBytes int `yaml:"maxBodyBytes"`
+	// StrictJSON rejects request bodies with fields the endpoint does not
+	// know. Off by default: the web client posts whole book objects.
+	StrictJSON bool `yaml:"strictJson"`
+}
+
+// RateLimitConfig allows Requests per Window; 0 requests disables the limit.
+type RateLimitConfig struct {
+	Requests int           `yaml:"requests"`
+	Window   time.Duration `yaml:"window"`
+}
+
+type DBConfig struct {
+	// Driver is "postgres", "sqlite" for a single self-hosted instance, or
+	// "memory" to keep all data in the process for frontend development and
+	// demos.
+	Driver string `yaml:"driver"`
+	// DSN is the Postgres connection string, or the database file path for
+	// the sqlite driver.
+	DSN         string `yaml:"dsn"`
+	AutoMigrate bool   `yaml:"autoMigrate"`
+	// Snapshot is a JSON file the memory driver loads at startup and saves
+	// every SnapshotInterval and on shutdown. Empty keeps nothing.
+	Snapshot         string        `yaml:"snapshot"`
+	SnapshotInterval time.Duration `yaml:"snapshotInterval"`
+}
+
+type JWTConfig struct {
+	Secret     string        `yaml:"secret"`
+	AccessTTL  time.Duration `yaml:"accessTtl"`
+	RefreshTTL time.Duration `yaml:"refreshTtl"`
+	// Issuer is the iss claim of every token BookPulse signs.
+	Issuer string `yaml:"issuer"`
+
+	// Keys switches signing from the HS256 secret to RS256/EdDSA. Every key
+	// verifies; SigningKey names the one new tokens are signed with.
+	Keys       []JWTKeyConfig `yaml:"keys"`
+	SigningKey string         `yaml:"signingKey"`
+}
+
+type JWTKeyConfig struct {
+	ID   string `yaml:"id"`
+	File string `yaml:"file"`
+}
+
+type CORSConfig struct {
+	AllowedOrigins []string `yaml:"allowedOrigins"`
+}
+
+type GoogleConfig struct {
+	BaseURL string        `yaml:"baseUrl"`
+	APIKey  string        `yaml:"apiKey"`
+	Timeout time.Duration `yaml:"timeout"`
+}
+
+type AppConfig struct {
+	// BaseURL is the public URL of the frontend, used in emailed links.
+	BaseURL string `yaml:"baseUrl"`
+}
+
+type AuthConfig struct {
+	PasswordMinLength          int           `yaml:"passwordMinLength"`
+	PasswordResetTTL           time.Duration `yaml:"passwordResetTtl"`
+	EmailVerificationTTL       time.Duration `yaml:"emailVerificationTtl"`
+	VerificationResendInterval time.Duration `yaml:"verificationResendInterval"`
+	// RequireVerifiedEmailForReviews blocks unverified users from posting reviews.
+	RequireVerifiedEmailForReviews bool `yaml:"requireVerifiedEmailForReviews"`
+
+	LoginThrottle LoginThrottleConfig `yaml:"loginThrottle"`
+}
+
+const (
+	StoreMemory   = "memory"
+	StorePostgres = "postgres"
+	StoreSQLite   = "sqlite"
+)
+
+type LoginThrottleConfig struct {
+	// Store is "memory" for a single instance or "postgres" to share
+	// counters between instances. The memory and sqlite db drivers, which
+	// serve a single instance, imply "memory".
+	Store               string        `yaml:"store"`
+	AccountFreeFailures int           `yaml:"accountFreeFailures"`
+	IPFreeFailures      int           `yaml:"ipFreeFailures"`
+	BaseLockout         time.Duration `yaml:"baseLockout"`
+	MaxLockout          time.Duration `yaml:"maxLockout"`
+	Window              time.Duration `yaml:"window"`
+}
+
+const (
+	MailDriverLog  = "log"
+	MailDriverSMTP = "smtp"
+)
+
+type MailConfig struct {
+	Driver string     `yaml:"driver"`
+	From   string     `yaml:"from"`
+	Dir    string     `yaml:"dir"`
+	SMTP   SMTPConfig `yaml:"smtp"`
+}
+
+type SMTPConfig struct {
+	Host     string `yaml:"host"`
+	Port     int    `yaml:"port"`
+	Username string `yaml:"username"`
+	Password string `yaml:"password"`
+}
+
+const (
+	ReviewsDelete    = "delete"
+	ReviewsAnonymize = "anonymize"
+)
+
+type AccountConfig struct {
+	// DeletionGracePeriod is how long a deleted account can still be
+	// restored before it is purged.
+	DeletionGracePeriod time.Duration `yaml:"deletionGracePeriod"`
+	PurgeInterval       time.Duration `yaml:"purgeInterval"`
+	// ReviewsOnDelete is "delete" to remove a purged user's reviews or
+	// "anonymize" to keep them without an author.
+	ReviewsOnDelete string `yaml:"reviewsOnDelete"`
+}
+
+type OIDCConfig struct {
+	Providers map[string]OIDCProviderConfig `yaml:"providers"`
+}
+
+type OIDCProviderConfig struct {
+	Issuer       string   `yaml:"issuer"`
+	ClientID     string   `yaml:"clientId"`
+	ClientSecret string   `yaml:"clientSecret"`
+	RedirectURL  string   `yaml:"redirectUrl"`
+	Scopes       []string `yaml:"scopes"`
+}
+
+// wellKnownIssuers lets a provider be configured from env vars alone.
+var wellKnownIssuers = map[string]string{
+	"google": "https://accounts.google.com",
+}
+
+func Default() *Config {
+	return &Config{
+		Env: EnvDev,
+		HTTP: HTTPConfig{
+			Addr: ":8080",
+			AuthRateLimit: RateLimitConfig{
+				Requests: 30,
+				Window:   time.Minute,
+			},
+			MaxBodyBytes:   1 << 20,
+			TrustedProxies: []string{"127.0.0.0/8", "::1"},
+		},
+		DB: DBConfig{
+			Driver:           StorePostgres,
+			DSN:              "host=127.0.0.1 port=5433 user=bookpulse password=bookpulse dbname=bookpulse sslmode=disable",
+			AutoMigrate:      true,
+			SnapshotInterval: time.Minute,
+		},
+		JWT: JWTConfig{
+			Secret:     DefaultJWTSecret,
+			AccessTTL:  15 * time.Minute,
+			RefreshTTL: 30 * 24 * time.Hour,
+			Issuer:     "bookpulse",
+		},
+		CORS: CORSConfig{
+			AllowedOrigins: []string{"http://localhost:4200"},
+		},
+		Google: GoogleConfig{
+			BaseURL: "https://www.googleapis.com/books/v1",
+			Timeout: 10 * time.Second,
+		},
+		App: AppConfig{
+			BaseURL: "http://localhost:4200",
+		},
+		Auth: AuthConfig{
+			PasswordMinLength:          8,
+			PasswordResetTTL:           time.Hour,
+			EmailVerificationTTL:       48 * time.Hour,
+			VerificationResendInterval: time.Minute,
+			LoginThrottle: LoginThrottleConfig{
+				Store:               StorePostgres,
+				AccountFreeFailures: 5,
+				IPFreeFailures:      20,
+				BaseLockout:         30 * time.Second,
+				MaxLockout:          15 * time.Minute,
+				Window:              15 * time.Minute,
+			},
+		},
+		Mail: MailConfig{
+			Driver: MailDriverLog,
+			From:   "BookPulse <no-reply@bookpulse.local>",
+			SMTP: SMTPConfig{
+				Port: 587,
+			},
+		},
+		Account: AccountConfig{
+			DeletionGracePeriod: 30 * 24 * time.Hour,
+			PurgeInterval:       time.Hour,
+			ReviewsOnDelete:     ReviewsAnonymize,
+		},
+	}
+}
+
+// Load builds the config from defaults, then the YAML file at path (if any),
+// then BOOKPULSE_* environment variables, and validates the result.
+// An empty path falls back to BOOKPULSE_CONFIG.
+func Load(path string) (*Config, error) {
+	cfg := Default()
+
+	if path == "" {
+		path = os.Getenv("BOOKPULSE_CONFIG")
+	}
+	if path != "" {
+		if err := cfg.loadFile(path); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := cfg.loadEnv(); err != nil {
+		return nil, err
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+func (c *Config) loadFile(path string) error {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("config: %w", err)
+	}
+	if err := yaml.Unmarshal(b, c); err != nil {
+		return fmt.Errorf("config %s: %w", path, err)
+	}
+	return nil
+}
+
+func (c *Config) loadEnv() error {
+	setString(&c.Env, "BOOKPULSE_ENV")
+	setString(&c.HTTP.Addr, "BOOKPULSE_HTTP_ADDR")
+	if port := os.Getenv("PORT"); port != "" && os.Getenv("BOOKPULSE_HTTP_ADDR") == "" {
+		c.HTTP.Addr = ":" + port
+	}
+	if err := setBool(&c.HTTP.TrustProxy, "BOOKPULSE_HTTP_TRUST_PROXY"); err != nil {
+		return err
+	}
+	if v, ok := os.LookupEnv("BOOKPULSE_HTTP_TRUSTED_PROXIES"); ok {
+		c.HTTP.TrustedProxies = splitList(v)
+	}
+	if err := setInt(&c.HTTP.AuthRateLimit.Requests, "BOOKPULSE_HTTP_AUTH_RATE_LIMIT_REQUESTS"); err != nil {
+		return err
+	}
+	if err := setDuration(&c.HTTP.AuthRateLimit.Window, "BOOKPULSE_HTTP_AUTH_RATE_LIMIT_WINDOW"); err != nil {
+		return err
+	}
+	if err := setInt(&c.HTTP.MaxBodyBytes, "BOOKPULSE_HTTP_MAX_BODY_BYTES"); err != nil {
+		return err
+	}
+	if err := setBool(&c.HTTP.StrictJSON, "BOOKPULSE_HTTP_STRICT_JSON"); err != nil {
+		return err
+	}
+
+	setString(&c.DB.Driver, "BOOKPULSE_DB_DRIVER")
+	setString(&c.DB.DSN, "BOOKPULSE_DB_DSN")
+	if err := setBool(&c.DB.AutoMigrate, "BOOKPULSE_DB_AUTO_MIGRATE"); err != nil {
+		return err
+	}
+	setString(&c.DB.Snapshot, "BOOKPULSE_DB_SNAPSHOT")
+	if err := setDuration(&c.DB.SnapshotInterval, "BOOKPULSE_DB_SNAPSHOT_INTERVAL"); err != nil {
+		return err
+	}
+
+	setString(&c.JWT.Secret, "BOOKPULSE_JWT_SECRET")
+	if err := setDuration(&c.JWT.AccessTTL, "BOOKPULSE_JWT_ACCESS_TTL"); err != nil {
+		return err
+	}
+	if err := setDuration(&c.JWT.RefreshTTL, "BOOKPULSE_JWT_REFRESH_TTL"); err != nil {
+		return err
+	}
+	if v, ok := os.LookupEnv("BOOKPULSE_JWT_KEYS"); ok {
+		keys, err := parseKeyList(v)
+		if err != nil {
+			return fmt.Errorf("BOOKPULSE_JWT_KEYS: %w", err)
+		}
+		c.JWT.Keys = keys
+	}
+	setString(&c.JWT.SigningKey, "BOOKPULSE_JWT_SIGNING_KEY")
+	setString(&c.JWT.Issuer, "BOOKPULSE_JWT_ISSUER")
+
+	if v, ok := os.LookupEnv("BOOKPULSE_CORS_ORIGINS"); ok {
+		c.CORS.AllowedOrigins = splitList(v)
+	}
+
+	setString(&c.Google.BaseURL, "BOOKPULSE_GOOGLE_BASE_URL")
+	setString(&c.Google.APIKey, "BOOKPULSE_GOOGLE_API_KEY")
+	if err := setDuration(&c.Google.Timeout, "BOOKPULSE_GOOGLE_TIMEOUT"); err != nil {
+		return err
+	}
+
+	setString(&c.App.BaseURL, "BOOKPULSE_APP_BASE_URL")
+	if err := setInt(&c.Auth.PasswordMinLength, "BOOKPULSE_AUTH_PASSWORD_MIN_LENGTH"); err != nil {
+		return err
+	}
+	if err := setDuration(&c.Auth.PasswordResetTTL, "BOOKPULSE_AUTH_PASSWORD_RESET_TTL"); err != nil {
+		return err
+	}
+	if err := setDuration(&c.Auth.EmailVerificationTTL, "BOOKPULSE_AUTH_EMAIL_VERIFICATION_TTL"); err != nil {
+		return err
+	}
+	if err := setDuration(&c.Auth.VerificationResendInterval, "BOOKPULSE_AUTH_VERIFICATION_RESEND_INTERVAL"); err != nil {
+		return err
+	}
+	if err := setBool(&c.Auth.RequireVerifiedEmailForReviews, "BOOKPULSE_AUTH_REQUIRE_VERIFIED_EMAIL_FOR_REVIEWS"); err != nil {
+		return err
+	}
+	setString(&c.Auth.LoginThrottle.Store, "BOOKPULSE_LOGIN_THROTTLE_STORE")
+	if err := setInt(&c.Auth.LoginThrottle.AccountFreeFailures, "BOOKPULSE_LOGIN_THROTTLE_ACCOUNT_FREE_FAILURES"); err != nil {
+		return err
+	}
+	if err := setInt(&c.Auth.LoginThrottle.IPFreeFailures, "BOOKPULSE_LOGIN_THROTTLE_IP_FREE_FAILURES"); err != nil {
+		return err
+	}
+	if err := setDuration(&c.Auth.LoginThrottle.BaseLockout, "BOOKPULSE_LOGIN_THROTTLE_BASE_LOCKOUT"); err != nil {
+		return err
+	}
+	if err := setDuration(&c.Auth.LoginThrottle.MaxLockout, "BOOKPULSE_LOGIN_THROTTLE_MAX_LOCKOUT"); err != nil {
+		return err
+	}
+	if err := setDuration(&c.Auth.LoginThrottle.Window, "BOOKPULSE_LOGIN_THROTTLE_WINDOW"); err != nil {
+		return err
+	}
+
+	setString(&c.Mail.Driver, "BOOKPULSE_MAIL_DRIVER")
+	setString(&c.Mail.From, "BOOKPULSE_MAIL_FROM")
+	setString(&c.Mail.Dir, "BOOKPULSE_MAIL_DIR")
+	setString(&c.Mail.SMTP.Host, "BOOKPULSE_SMTP_HOST")
+	if err := setInt(&c.Mail.SMTP.Port, "BOOKPULSE_SMTP_PORT"); err != nil {
+		return err
+	}
+	setString(&c.Mail.SMTP.Username, "BOOKPULSE_SMTP_USERNAME")
+	setString(&c.Mail.SMTP.Password, "BOOKPULSE_SMTP_PASSWORD")
+
+	if err := setDuration(&c.Account.DeletionGracePeriod, "BOOKPULSE_ACCOUNT_DELETION_GRACE_PERIOD"); err != nil {
+		return err
+	}
+	if err := setDuration(&c.Account.PurgeInterval, "BOOKPULSE_ACCOUNT_PURGE_INTERVAL"); err != nil {
+		return err
+	}
+	setString(&c.Account.ReviewsOnDelete, "BOOKPULSE_ACCOUNT_REVIEWS_ON_DELETE")
+
+	c.loadOIDCEnv()
+	return nil
+}
+
+// loadOIDCEnv applies BOOKPULSE_OIDC_<NAME>_* overrides to configured
+// providers and to the well-known ones, which are enabled by setting
+// their client id.
+func (c *Config) loadOIDCEnv() {
+	names := map[string]bool{}
+	for name := range c.OIDC.Providers {
+		names[name] = true
+	}
+	for name := range wellKnownIssuers {
+		names[name] = true
+	}
+
+	for name := range names {
+		prefix := "BOOKPULSE_OIDC_" + strings.ToUpper(name) + "_"
+		p, configured := c.OIDC.Providers[name]
+		if !configured {
+			if _, ok := os.LookupEnv(prefix + "CLIENT_ID"); !ok {
+				continue
+			}
+			p.Issuer = wellKnownIssuers[name]
+		}
+
+		setString(&p.Issuer, prefix+"ISSUER")
+		setString(&p.ClientID, prefix+"CLIENT_ID")
+		setString(&p.ClientSecret, prefix+"CLIENT_SECRET")
+		setString(&p.RedirectURL, prefix+"REDIRECT_URL")
+		if v, ok := os.LookupEnv(prefix + "SCOPES"); ok {
+			p.Scopes = splitList(v)
+		}
+
+		if c.OIDC.Providers == nil {
+			c.OIDC.Providers = map[string]OIDCProviderConfig{}
+		}
+		c.OIDC.Providers[name] = p
+	}
+}
+
+func (c *Config) Validate() error {
+	var errs []error
+
+	switch c.Env {
+	case EnvDev, EnvStaging, EnvProd:
+	default:
+		errs = append(errs, fmt.Errorf("env must be one of dev, staging, prod (got %q)", c.Env))
+	}
+
+	if strings.TrimSpace(c.HTTP.Addr) == "" {
+		errs = append(errs, errors.New("http.addr is required"))
+	}
+	if rl := c.HTTP.AuthRateLimit; rl.Requests < 0 || (rl.Requests > 0 && rl.Window <= 0) {
+		errs = append(errs, errors.New("http.authRateLimit needs requests >= 0 and a positive window"))
+	}
+	if c.HTTP.MaxBodyBytes <= 0 {
+		errs = append(errs, errors.New("http.maxBodyBytes must be positive"))
+	}
+	switch c.DB.Driver {
+	case StorePostgres, StoreSQLite:
+		if strings.TrimSpace(c.DB.DSN) == "" {
+			errs = append(errs, errors.New("db.dsn is required"))
+		}
+	case StoreMemory:
+		if c.DB.Snapshot != "" && c.DB.SnapshotInterval <= 0 {
+			errs = append(errs, errors.New("db.snapshotInterval must be positive"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("db.driver must be postgres, sqlite or memory (got %q)", c.DB.Driver))
+	}
+
+	if len(c.JWT.Keys) > 0 {
+		found := false
+		for _, k := range c.JWT.Keys {
+			if k.ID == "" || k.File == "" {
+				errs = append(errs, errors.New("jwt.keys entries need id and file"))
+			}
+			found = found || k.ID == c.JWT.SigningKey
+		}
+		if !found {
+			errs = append(errs, fmt.Errorf("jwt.signingKey %q must be one of jwt.keys", c.JWT.SigningKey))
+		}
+	}
+
+	switch {
+	case c.JWT.Secret == "" && len(c.JWT.Keys) > 0:
+		// asymmetric only, no legacy HS256 tokens accepted
+	case c.JWT.Secret == "":
+		errs = append(errs, errors.New("jwt.secret is required unless jwt.keys is set"))
+	case !c.IsDev() && c.JWT.Secret == DefaultJWTSecret:
+		errs = append(errs, fmt.Errorf("jwt.secret must be changed from the default in %s", c.Env))
+	case !c.IsDev() && len(c.JWT.Secret) < 32:
+		errs = append(errs, errors.New("jwt.secret must be at least 32 bytes outside dev"))
+	}
+
+	if c.JWT.Issuer == "" {
+		errs = append(errs, errors.New("jwt.issuer is required"))
+	}
+	if c.JWT.AccessTTL <= 0 || c.JWT.RefreshTTL <= 0 {
+		errs = append(errs, errors.New("jwt.accessTtl and jwt.refreshTtl must be positive"))
+	} else if c.JWT.AccessTTL >= c.JWT.RefreshTTL {
+		errs = append(errs, errors.New("jwt.accessTtl must be shorter than jwt.refreshTtl"))
+	}
+
+	if c.HTTP.TrustProxy {
+		if len(c.HTTP.TrustedProxies) == 0 {
+			errs = append(errs, errors.New("http.trustedProxies must not be empty when http.trustProxy is set"))
+		}
+		for _, p := range c.HTTP.TrustedProxies {
+			if _, err := netip.ParsePrefix(p); err != nil {
+				if _, err := netip.ParseAddr(p); err != nil {
+					errs = append(errs, fmt.Errorf("http.trustedProxies: %q is not an IP address or CIDR range", p))
+				}
+			}
+		}
+	}
+
+	if len(c.CORS.AllowedOrigins) == 0 {
+		errs = append(errs, errors.New("cors.allowedOrigins must not be empty"))
+	}
+
+	if c.Google.BaseURL == "" {
+		errs = append(errs, errors.New("google.baseUrl is required"))
+	}
+	if c.Google.Timeout <= 0 {
+		errs = append(errs, errors.New("google.timeout must be positive"))
+	}
+
+	if c.App.BaseURL == "" {
+		errs = append(errs, errors.New("app.baseUrl is required"))
+	}
+	// bcrypt only looks at 72 bytes
+	if c.Auth.PasswordMinLength < 6 || c.Auth.PasswordMinLength > 64 {
+		errs = append(errs, errors.New("auth.passwordMinLength must be between 6 and 64"))
+	}
+	if c.Auth.PasswordResetTTL <= 0 {
+		errs = append(errs, errors.New("auth.passwordResetTtl must be positive"))
+	}
+	if c.Auth.EmailVerificationTTL <= 0 {
+		errs = append(errs, errors.New("auth.emailVerificationTtl must be positive"))
+	}
+	if c.Auth.VerificationResendInterval < 0 {
+		errs = append(errs, errors.New("auth.verificationResendInterval must not be negative"))
+	}
+
+	lt := c.Auth.LoginThrottle
+	if lt.Store != StoreMemory && lt.Store != StorePostgres {
+		errs = append(errs, fmt.Errorf("auth.loginThrottle.store must be memory or postgres (got %q)", lt.Store))
+	}
+	if lt.AccountFreeFailures <= 0 || lt.IPFreeFailures <= 0 {
+		errs = append(errs, errors.New("auth.loginThrottle free failure counts must be positive"))
+	}
+	if lt.BaseLockout <= 0 || lt.MaxLockout < lt.BaseLockout || lt.Window <= 0 {
+		errs = append(errs, errors.New("auth.loginThrottle needs 0 < baseLockout <= maxLockout and a positive window"))
+	}
+
+	switch c.Mail.Driver {
+	case MailDriverLog:
+		if !c.IsDev() {
+			errs = append(errs, fmt.Errorf("mail.driver log is for dev only; use smtp in %s", c.Env))
+		}
+	case MailDriverSMTP:
+		if c.Mail.SMTP.Host == "" || c.Mail.SMTP.Port <= 0 {
+			errs = append(errs, errors.New("mail.smtp.host and mail.smtp.port are required for the smtp driver"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("mail.driver must be log or smtp (got %q)", c.Mail.Driver))
+	}
+	if c.Mail.From == "" {
+		errs = append(errs, errors.New("mail.from is required"))
+	}
+
+	if c.Account.DeletionGracePeriod < 0 || c.Account.PurgeInterval <= 0 {
+		errs = append(errs, errors.New("account.deletionGracePeriod must not be negative and account.purgeInterval must be positive"))
+	}
+	if c.Account.ReviewsOnDelete != ReviewsDelete && c.Account.ReviewsOnDelete != ReviewsAnonymize {
+		errs = append(errs, fmt.Errorf("account.reviewsOnDelete must be delete or anonymize (got %q)", c.Account.ReviewsOnDelete))
+	}
+
+	for name, p := range c.OIDC.Providers {
+		if p.Issuer == "" || p.ClientID == "" || p.RedirectURL == "" {
+			errs = append(errs, fmt.Errorf("oidc.providers.%s needs issuer, clientId and redirectUrl", name))
+			continue
+		}
+		// plain http is only for a local mock provider
+		if !c.IsDev() && !strings.HasPrefix(p.Issuer, "https://") {
+			errs = append(errs, fmt.Errorf("oidc.providers.%s.issuer must use https outside dev", name))
+		}
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("invalid config: %w", errors.Join(errs...))
+	}
+	return nil
+}
+
+func (c *Config) IsDev() bool {
+	return c.Env == EnvDev
+}
+
+// parseKeyList reads "id=path,id=path".
+func parseKeyList(v string) ([]JWTKeyConfig, error) {
+	var out []JWTKeyConfig
+	for _, item := range splitList(v) {
+		id, file, ok := strings.Cut(item, "=")
+		if !ok {
+			return nil, fmt.Errorf("expected id=path, got %q", item)
+		}
+		out = append(out, JWTKeyConfig{ID: strings.TrimSpace(id), File: strings.TrimSpace(file)})
+	}
+	return out, nil
+}
+
+func setString(dst *string, key string) {
+	if v, ok := os.LookupEnv(key); ok {
+		*dst = v
+	}
+}
+
+func setBool(dst *bool, key string) error {
+	v, ok := os.LookupEnv(key)
+	if !ok {
+		return nil
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		return fmt.Errorf("%s: %w", key, err)
+	}
+	*dst = b
+	return nil
+}
+
+func setInt(dst *int, key string) error {
+	v, ok := os.LookupEnv(key)
+	if !ok {
+		return nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		return fmt.Errorf("%s: %w", key, err)
+	}
+	*dst = n
+	return nil
+}
+
+func setDuration(dst *time.Duration, key string) error {
+	v, ok := os.LookupEnv(key)
+	if !ok {
+		return nil
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		return fmt.Errorf("%s: %w", key, err)
+	}
+	*dst = d
+	return nil
+}
+
+func splitList(s string) []string {
+	out := []string{}
+	for _, p := range strings.Split(s, ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			out = append(out, p)
+		}
+	}
+	return out
+}
@@ -0,0 +1,322 @@
+package db
+
+import (
+	"context"
+	"crypto/sha256"
+	"embed"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+//go:embed migrations/*.sql
+var migrationsFS embed.FS
+
+// migrationLockID is the pg_advisory_lock key that serialises migration runs
+// across instances sharing one database.
+const migrationLockID int64 = 0x626f6f6b70756c73
+
+type Migration struct {
+	Version  int
+	Name     string
+	Up       string
+	Down     string
+	Checksum string
+}
+
+type MigrationStatus struct {
+	Version   int        `json:"version"`
+	Name      string     `json:"name"`
+	AppliedAt *time.Time `json:"appliedAt"`
+}
+
+type Migrator struct {
+	pool       *pgxpool.Pool
+	migrations []Migration
+}
+
+func NewMigrator(pool *pgxpool.Pool) (*Migrator, error) {
+	migrations, err := LoadMigrations(migrationsFS, "migrations")
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{pool: pool, migrations: migrations}, nil
+}
+
+// LoadMigrations reads NNNN_name.up.sql / NNNN_name.down.sql pairs from dir
+// and returns them ordered by version.
+func LoadMigrations(fsys fs.FS, dir string) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := map[int]*Migration{}
+	for _, e := range entries {
+		if e.IsDir() {
+			continue
+		}
+		file := e.Name()
+
+		var direction string
+		switch {
+		case strings.HasSuffix(file, ".up.sql"):
+			direction = "up"
+		case strings.HasSuffix(file, ".down.sql"):
+			direction = "down"
+		default:
+			continue
+		}
+
+		base := strings.TrimSuffix(file, "."+direction+".sql")
+		num, name, ok := strings.Cut(base, "_")
+		if !ok {
+			return nil, fmt.Errorf("migration %s: expected NNNN_name.%s.sql", file, direction)
+		}
+		version, err := strconv.Atoi(num)
+		if err != nil || version <= 0 {
+			return nil, fmt.Errorf("migration %s: bad version %q", file, num)
+		}
+
+		body, err := fs.ReadFile(fsys, path.Join(dir, file))
+		if err != nil {
+			return nil, err
+		}
+
+		m := byVersion[version]
+		if m == nil {
+			m = &Migration{Version: version, Name: name}
+			byVersion[version] = m
+		} else if m.Name != name {
+			return nil, fmt.Errorf("migration %d: name mismatch %q vs %q", version, m.Name, name)
+		}
+
+		if direction == "up" {
+			m.Up = string(body)
+			sum := sha256.Sum256(body)
+			m.Checksum = hex.EncodeToString(sum[:])
+		} else {
+			m.Down = string(body)
+		}
+	}
+
+	out := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" {
+			return nil, fmt.Errorf("migration %d_%s: missing up script", m.Version, m.Name)
+		}
+		out = append(out, *m)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Version < out[j].Version })
+	return out, nil
+}
+
+type appliedMigration struct {
+	name      string
+	checksum  string
+	appliedAt time.Time
+}
+
+// Up applies every pending migration and returns how many were applied.
+func (m *Migrator) Up(ctx context.Context) (int, error) {
+	applied := 0
+	err := m.withLock(ctx, func(conn *pgxpool.Conn) error {
+		done, err := m.verify(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		for _, mig := range m.migrations {
+			if _, ok := done[mig.Version]; ok {
+				continue
+			}
+			err := pgx.BeginFunc(ctx, conn, func(tx pgx.Tx) error {
+				if _, err := tx.Exec(ctx, mig.Up); err != nil {
+					return err
+				}
+				_, err := tx.Exec(ctx, `
+					INSERT INTO schema_migrations (version, name, checksum)
+					VALUES ($1, $2, $3)
+				`, mig.Version, mig.Name, mig.Checksum)
+				return err
+			})
+			if err != nil {
+				return fmt.Errorf("migration %d_%s up: %w", mig.Version, mig.Name, err)
+			}
+			log.Printf("Применена миграция %04d_%s", mig.Version, mig.Name)
+			applied++
+		}
+		return nil
+	})
+	return applied, err
+}
+
+// Down rolls back the last steps applied migrations.
+func (m *Migrator) Down(ctx context.Context, steps int) (int, error) {
+	if steps <= 0 {
+		return 0, errors.New("steps must be positive")
+	}
+
+	reverted := 0
+	err := m.withLock(ctx, func(conn *pgxpool.Conn) error {
+		done, err := m.verify(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		for i := len(m.migrations) - 1; i >= 0 && reverted < steps; i-- {
+			mig := m.migrations[i]
+			if _, ok := done[mig.Version]; !ok {
+				continue
+			}
+			if mig.Down == "" {
+				return fmt.Errorf("migration %d_%s has no down script", mig.Version, mig.Name)
+			}
+			err := pgx.BeginFunc(ctx, conn, func(tx pgx.Tx) error {
+				if _, err := tx.Exec(ctx, mig.Down); err != nil {
+					return err
+				}
+				_, err := tx.Exec(ctx, `DELETE FROM schema_migrations WHERE version = $1`, mig.Version)
+				return err
+			})
+			if err != nil {
+				return fmt.Errorf("migration %d_%s down: %w", mig.Version, mig.Name, err)
+			}
+			log.Printf("Откачена миграция %04d_%s", mig.Version, mig.Name)
+			reverted++
+		}
+		return nil
+	})
+	return reverted, err
+}
+
+func (m *Migrator) Status(ctx context.Context) ([]MigrationStatus, error) {
+	var out []MigrationStatus
+	err := m.withLock(ctx, func(conn *pgxpool.Conn) error {
+		done, err := m.applied(ctx, conn)
+		if err != nil {
+			return err
+		}
+		out = make([]MigrationStatus, 0, len(m.migrations))
+		for _, mig := range m.migrations {
+			st := MigrationStatus{Version: mig.Version, Name: mig.Name}
+			if a, ok := done[mig.Version]; ok {
+				at := a.appliedAt
+				st.AppliedAt = &at
+			}
+			out = append(out, st)
+		}
+		return nil
+	})
+	return out, err
+}
+
+func (m *Migrator) withLock(ctx context.Context, fn func(conn *pgxpool.Conn) error) error {
+	conn, err := m.pool.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Release()
+
+	if _, err := conn.Exec(ctx, `SELECT pg_advisory_lock($1)`, migrationLockID); err != nil {
+		return fmt.Errorf("acquire migration lock: %w", err)
+	}
+	defer func() {
+		// используем отдельный контекст, чтобы снять лок даже при отменённом ctx
+		unlockCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if _, err := conn.Exec(unlockCtx, `SELECT pg_advisory_unlock($1)`, migrationLockID); err != nil {
+			log.Printf("Не удалось снять лок миграций: %v", err)
+		}
+	}()
+
+	_, err = conn.Exec(ctx, `
+	CREATE TABLE IF NOT EXISTS schema_migrations (
+	version INT PRIMARY KEY,
+	name TEXT NOT NULL,
+	checksum TEXT NOT NULL,
+	applied_at TIMESTAMPTZ NOT NULL DEFAULT now()
+	);
+	`)
+	if err != nil {
+		return fmt.Errorf("create schema_migrations: %w", err)
+	}
+
+	return fn(conn)
+}
+
+func (m *Migrator) applied(ctx context.Context, conn *pgxpool.Conn) (map[int]appliedMigration, error) {
+	rows, err := conn.Query(ctx, `SELECT version, name, checksum, applied_at FROM schema_migrations`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := map[int]appliedMigration{}
+	for rows.Next() {
+		var version int
+		var a appliedMigration
+		if err := rows.Scan(&version, &a.name, &a.checksum, &a.appliedAt); err != nil {
+			return nil, err
+		}
+		out[version] = a
+	}
+	return out, rows.Err()
+}
+
+// verify makes sure every applied migration is still known and unchanged.
+func (m *Migrator) verify(ctx context.Context, conn *pgxpool.Conn) (map[int]appliedMigration, error) {
+	done, err := m.applied(ctx, conn)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkApplied(m.migrations, done); err != nil {
+		return nil, err
+	}
+	return done, nil
+}
+
+func checkApplied(migrations []Migration, done map[int]appliedMigration) error {
+	known := make(map[int]Migration, len(migrations))
+	for _, mig := range migrations {
+		known[mig.Version] = mig
+	}
+
+	for version, a := range done {
+		mig, ok := known[version]
+		if !ok {
+			return fmt.Errorf("migration %d_%s is applied but missing from this build", version, a.name)
+		}
+		if mig.Checksum != a.checksum {
+			return fmt.Errorf("migration %d_%s checksum mismatch: applied %s, embedded %s",
+				version, mig.Name, a.checksum, mig.Checksum)
+		}
+	}
+	return nil
+}
+
+// Migrate applies all pending migrations on DBpool.
+func Migrate(ctx context.Context) error {
+	m, err := NewMigrator(DBpool)
+	if err != nil {
+		return err
+	}
+	n, err := m.Up(ctx)
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		log.Println("Схема БД актуальна")
+	}
+	return nil
+}
@@ -0,0 +1,8 @@
+DROP TABLE IF EXISTS reviews;
+DROP TABLE IF EXISTS collection_books;
+DROP TABLE IF EXISTS collections;
+DROP TABLE IF EXISTS user_books;
+DROP TABLE IF EXISTS book_genres;
+DROP TABLE IF EXISTS genres;
+DROP TABLE IF EXISTS books;
+DROP TABLE IF EXISTS users;
@@ -0,0 +1,75 @@
+CREATE TABLE IF NOT EXISTS users (
+	id SERIAL PRIMARY KEY,
+	email TEXT NOT NULL UNIQUE,
+	password_hash TEXT NOT NULL,
+	name TEXT NOT NULL DEFAULT '',
+	created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE TABLE IF NOT EXISTS books (
+	id SERIAL PRIMARY KEY,
+	google_id TEXT NOT NULL UNIQUE,
+	title TEXT NOT NULL,
+	author TEXT NOT NULL DEFAULT '',
+	cover_url TEXT NOT NULL DEFAULT '',
+	description TEXT NOT NULL DEFAULT '',
+	published_year INT,
+	page_count INT,
+	age_rating TEXT NOT NULL DEFAULT '',
+	created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE TABLE IF NOT EXISTS genres (
+	id SERIAL PRIMARY KEY,
+	name TEXT NOT NULL UNIQUE
+);
+
+CREATE TABLE IF NOT EXISTS book_genres (
+	book_id INT NOT NULL REFERENCES books(id) ON DELETE CASCADE,
+	genre_id INT NOT NULL REFERENCES genres(id) ON DELETE CASCADE,
+	PRIMARY KEY (book_id, genre_id)
+);
+
+CREATE INDEX IF NOT EXISTS book_genres_genre_id_idx ON book_genres (genre_id);
+
+CREATE TABLE IF NOT EXISTS user_books (
+	user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+	book_id INT NOT NULL REFERENCES books(id) ON DELETE CASCADE,
+	status TEXT NOT NULL DEFAULT 'planned'
+		CHECK (status IN ('planned', 'reading', 'finished', 'dropped')),
+	created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+	PRIMARY KEY (user_id, book_id)
+);
+
+CREATE INDEX IF NOT EXISTS user_books_book_id_idx ON user_books (book_id);
+
+CREATE TABLE IF NOT EXISTS collections (
+	id SERIAL PRIMARY KEY,
+	user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+	name TEXT NOT NULL,
+	created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+	UNIQUE (user_id, name)
+);
+
+CREATE TABLE IF NOT EXISTS collection_books (
+	user_id INT NOT NULL,
+	collection_id INT NOT NULL REFERENCES collections(id) ON DELETE CASCADE,
+	book_id INT NOT NULL,
+	created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+	PRIMARY KEY (collection_id, book_id),
+	FOREIGN KEY (user_id, book_id) REFERENCES user_books (user_id, book_id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS collection_books_user_book_idx ON collection_books (user_id, book_id);
+
+CREATE TABLE IF NOT EXISTS reviews (
+	id SERIAL PRIMARY KEY,
+	user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+	book_id INT NOT NULL REFERENCES books(id) ON DELETE CASCADE,
+	rating INT NOT NULL CHECK (rating BETWEEN 1 AND 5),
+	text TEXT NOT NULL,
+	created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+	UNIQUE (user_id, book_id)
+);
+
+CREATE INDEX IF NOT EXISTS reviews_book_id_created_at_idx ON reviews (book_id, created_at DESC);
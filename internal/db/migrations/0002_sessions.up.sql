@@ -0,0 +1,24 @@
+CREATE TABLE sessions (
+	id BIGSERIAL PRIMARY KEY,
+	user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+	user_agent TEXT NOT NULL DEFAULT '',
+	ip TEXT NOT NULL DEFAULT '',
+	created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+	last_seen_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+	expires_at TIMESTAMPTZ NOT NULL,
+	revoked_at TIMESTAMPTZ,
+	revoke_reason TEXT NOT NULL DEFAULT ''
+);
+
+CREATE INDEX sessions_user_id_active_idx ON sessions (user_id) WHERE revoked_at IS NULL;
+
+CREATE TABLE refresh_tokens (
+	id BIGSERIAL PRIMARY KEY,
+	session_id BIGINT NOT NULL REFERENCES sessions(id) ON DELETE CASCADE,
+	token_hash TEXT NOT NULL UNIQUE,
+	created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+	expires_at TIMESTAMPTZ NOT NULL,
+	used_at TIMESTAMPTZ
+);
+
+CREATE INDEX refresh_tokens_session_id_idx ON refresh_tokens (session_id);
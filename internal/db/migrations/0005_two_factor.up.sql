@@ -0,0 +1,13 @@
+ALTER TABLE users
+	ADD COLUMN totp_secret TEXT,
+	ADD COLUMN totp_enabled_at TIMESTAMPTZ,
+	ADD COLUMN totp_last_counter BIGINT;
+
+CREATE TABLE recovery_codes (
+	id BIGSERIAL PRIMARY KEY,
+	user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+	code_hash TEXT NOT NULL,
+	created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+	used_at TIMESTAMPTZ,
+	UNIQUE (user_id, code_hash)
+);
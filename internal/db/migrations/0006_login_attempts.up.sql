@@ -0,0 +1,8 @@
+CREATE TABLE login_attempts (
+	key TEXT PRIMARY KEY,
+	failures INT NOT NULL DEFAULT 0,
+	last_failure_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+	locked_until TIMESTAMPTZ
+);
+
+CREATE INDEX login_attempts_last_failure_at_idx ON login_attempts (last_failure_at);
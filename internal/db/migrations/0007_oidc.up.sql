@@ -0,0 +1,22 @@
+CREATE TABLE user_identities (
+	id BIGSERIAL PRIMARY KEY,
+	user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+	provider TEXT NOT NULL,
+	subject TEXT NOT NULL,
+	email TEXT NOT NULL DEFAULT '',
+	created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+	UNIQUE (provider, subject),
+	UNIQUE (user_id, provider)
+);
+
+CREATE TABLE oidc_login_states (
+	state_hash TEXT PRIMARY KEY,
+	provider TEXT NOT NULL,
+	code_verifier TEXT NOT NULL,
+	nonce TEXT NOT NULL,
+	link_user_id INT REFERENCES users(id) ON DELETE CASCADE,
+	created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+	expires_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX oidc_login_states_expires_at_idx ON oidc_login_states (expires_at);
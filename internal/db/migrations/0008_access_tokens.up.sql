@@ -0,0 +1,13 @@
+CREATE TABLE personal_access_tokens (
+	id BIGSERIAL PRIMARY KEY,
+	user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+	name TEXT NOT NULL,
+	token_hash TEXT NOT NULL UNIQUE,
+	scopes TEXT[] NOT NULL,
+	created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+	last_used_at TIMESTAMPTZ,
+	expires_at TIMESTAMPTZ,
+	revoked_at TIMESTAMPTZ
+);
+
+CREATE INDEX personal_access_tokens_user_id_idx ON personal_access_tokens (user_id);
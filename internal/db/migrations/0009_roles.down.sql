@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS users_role_idx;
+ALTER TABLE users DROP COLUMN IF EXISTS role;
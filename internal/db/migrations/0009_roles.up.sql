@@ -0,0 +1,5 @@
+ALTER TABLE users
+	ADD COLUMN role TEXT NOT NULL DEFAULT 'user'
+	CHECK (role IN ('user', 'moderator', 'admin'));
+
+CREATE INDEX users_role_idx ON users (role) WHERE role <> 'user';
@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS audit_events;
+ALTER TABLE users
+	DROP COLUMN IF EXISTS suspend_reason,
+	DROP COLUMN IF EXISTS suspended_at;
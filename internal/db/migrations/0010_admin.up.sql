@@ -0,0 +1,18 @@
+ALTER TABLE users
+	ADD COLUMN suspended_at TIMESTAMPTZ,
+	ADD COLUMN suspend_reason TEXT NOT NULL DEFAULT '';
+
+-- user ids are kept without foreign keys so entries outlive deleted accounts
+CREATE TABLE audit_events (
+	id BIGSERIAL PRIMARY KEY,
+	event_type TEXT NOT NULL,
+	actor_id INT,
+	user_id INT,
+	ip TEXT NOT NULL DEFAULT '',
+	user_agent TEXT NOT NULL DEFAULT '',
+	details JSONB NOT NULL DEFAULT '{}',
+	created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX audit_events_user_id_idx ON audit_events (user_id, created_at DESC);
+CREATE INDEX audit_events_actor_id_idx ON audit_events (actor_id, created_at DESC);
@@ -0,0 +1,5 @@
+DELETE FROM reviews WHERE user_id IS NULL;
+ALTER TABLE reviews ALTER COLUMN user_id SET NOT NULL;
+
+DROP INDEX IF EXISTS users_deletion_requested_at_idx;
+ALTER TABLE users DROP COLUMN IF EXISTS deletion_requested_at;
@@ -0,0 +1,7 @@
+ALTER TABLE users ADD COLUMN deletion_requested_at TIMESTAMPTZ;
+
+CREATE INDEX users_deletion_requested_at_idx ON users (deletion_requested_at)
+	WHERE deletion_requested_at IS NOT NULL;
+
+-- anonymised reviews outlive their author
+ALTER TABLE reviews ALTER COLUMN user_id DROP NOT NULL;
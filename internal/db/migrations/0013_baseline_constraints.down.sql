@@ -0,0 +1,3 @@
+-- The up migration only adds constraints 0001 was meant to create, and
+-- cannot tell which ones it added, so there is nothing to undo.
+SELECT 1;
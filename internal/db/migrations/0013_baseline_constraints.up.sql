@@ -0,0 +1,77 @@
+-- 0001 creates its tables with IF NOT EXISTS so that it could adopt the
+-- databases set up by hand before migrations existed. On those it skipped
+-- the keys and constraints of tables that were already there, including the
+-- unique ones the ON CONFLICT clauses depend on. This adds whichever are
+-- missing. A constraint counts as present when the table already has one of
+-- the same kind over the same columns, whatever its name (checks go by
+-- name). If existing rows violate a constraint the migration fails and
+-- nothing is changed; clean up the rows and run it again.
+CREATE FUNCTION pg_temp.ensure_constraint(tbl regclass, kind "char", cols name[], con name, def text)
+RETURNS void LANGUAGE plpgsql AS $$
+BEGIN
+	IF NOT EXISTS (
+		SELECT 1 FROM pg_constraint c
+		WHERE c.conrelid = tbl
+		  AND c.contype = kind
+		  AND CASE WHEN kind = 'c' THEN c.conname = con
+		      ELSE cardinality(c.conkey) = cardinality(cols)
+		       AND c.conkey::int[] @> (
+		           SELECT array_agg(a.attnum::int) FROM pg_attribute a
+		           WHERE a.attrelid = tbl AND a.attname = ANY (cols))
+		      END
+	) THEN
+		RAISE NOTICE 'adding % to %', con, tbl;
+		EXECUTE format('ALTER TABLE %s ADD CONSTRAINT %I %s', tbl, con, def);
+	END IF;
+END
+$$;
+
+SELECT pg_temp.ensure_constraint('users', 'p', '{id}', 'users_pkey', 'PRIMARY KEY (id)');
+SELECT pg_temp.ensure_constraint('users', 'u', '{email}', 'users_email_key', 'UNIQUE (email)');
+
+SELECT pg_temp.ensure_constraint('books', 'p', '{id}', 'books_pkey', 'PRIMARY KEY (id)');
+SELECT pg_temp.ensure_constraint('books', 'u', '{google_id}', 'books_google_id_key', 'UNIQUE (google_id)');
+
+SELECT pg_temp.ensure_constraint('genres', 'p', '{id}', 'genres_pkey', 'PRIMARY KEY (id)');
+SELECT pg_temp.ensure_constraint('genres', 'u', '{name}', 'genres_name_key', 'UNIQUE (name)');
+
+SELECT pg_temp.ensure_constraint('book_genres', 'p', '{book_id,genre_id}', 'book_genres_pkey',
+	'PRIMARY KEY (book_id, genre_id)');
+SELECT pg_temp.ensure_constraint('book_genres', 'f', '{book_id}', 'book_genres_book_id_fkey',
+	'FOREIGN KEY (book_id) REFERENCES books(id) ON DELETE CASCADE');
+SELECT pg_temp.ensure_constraint('book_genres', 'f', '{genre_id}', 'book_genres_genre_id_fkey',
+	'FOREIGN KEY (genre_id) REFERENCES genres(id) ON DELETE CASCADE');
+
+SELECT pg_temp.ensure_constraint('user_books', 'p', '{user_id,book_id}', 'user_books_pkey',
+	'PRIMARY KEY (user_id, book_id)');
+SELECT pg_temp.ensure_constraint('user_books', 'f', '{user_id}', 'user_books_user_id_fkey',
+	'FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE');
+SELECT pg_temp.ensure_constraint('user_books', 'f', '{book_id}', 'user_books_book_id_fkey',
+	'FOREIGN KEY (book_id) REFERENCES books(id) ON DELETE CASCADE');
+SELECT pg_temp.ensure_constraint('user_books', 'c', '{status}', 'user_books_status_check',
+	'CHECK (status IN (''planned'', ''reading'', ''finished'', ''dropped''))');
+
+SELECT pg_temp.ensure_constraint('collections', 'p', '{id}', 'collections_pkey', 'PRIMARY KEY (id)');
+SELECT pg_temp.ensure_constraint('collections', 'f', '{user_id}', 'collections_user_id_fkey',
+	'FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE');
+SELECT pg_temp.ensure_constraint('collections', 'u', '{user_id,name}', 'collections_user_id_name_key',
+	'UNIQUE (user_id, name)');
+
+SELECT pg_temp.ensure_constraint('collection_books', 'p', '{collection_id,book_id}', 'collection_books_pkey',
+	'PRIMARY KEY (collection_id, book_id)');
+SELECT pg_temp.ensure_constraint('collection_books', 'f', '{collection_id}', 'collection_books_collection_id_fkey',
+	'FOREIGN KEY (collection_id) REFERENCES collections(id) ON DELETE CASCADE');
+SELECT pg_temp.ensure_constraint('collection_books', 'f', '{user_id,book_id}', 'collection_books_user_id_book_id_fkey',
+	'FOREIGN KEY (user_id, book_id) REFERENCES user_books (user_id, book_id) ON DELETE CASCADE');
+
+SELECT pg_temp.ensure_constraint('reviews', 'p', '{id}', 'reviews_pkey', 'PRIMARY KEY (id)');
+SELECT pg_temp.ensure_constraint('reviews', 'f', '{user_id}', 'reviews_user_id_fkey',
+	'FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE');
+SELECT pg_temp.ensure_constraint('reviews', 'f', '{book_id}', 'reviews_book_id_fkey',
+	'FOREIGN KEY (book_id) REFERENCES books(id) ON DELETE CASCADE');
+SELECT pg_temp.ensure_constraint('reviews', 'u', '{user_id,book_id}', 'reviews_user_id_book_id_key',
+	'UNIQUE (user_id, book_id)');
+SELECT pg_temp.ensure_constraint('reviews', 'c', '{rating}', 'reviews_rating_check',
+	'CHECK (rating BETWEEN 1 AND 5)');
+
+DROP FUNCTION pg_temp.ensure_constraint(regclass, "char", name[], name, text);
@@ -0,0 +1,26 @@
+-- Email addresses are compared without regard to case: Alice@Example.com
+-- and alice@example.com are one account. The service lowercases addresses
+-- before storing or looking them up; this lowercases the rows stored before
+-- it did, and moves uniqueness to lower(email) so that no two accounts can
+-- differ by case only. Accounts that already do must be merged or renamed by
+-- hand first; the migration stops and lists them.
+DO $$
+DECLARE
+	dupes text;
+BEGIN
+	SELECT string_agg(emails, '; ') INTO dupes FROM (
+		SELECT string_agg(email, ', ' ORDER BY id) AS emails
+		FROM users
+		GROUP BY lower(email)
+		HAVING COUNT(*) > 1
+	) d;
+	IF dupes IS NOT NULL THEN
+		RAISE EXCEPTION 'accounts differ only by the case of their email: %', dupes;
+	END IF;
+END
+$$;
+
+UPDATE users SET email = lower(email) WHERE email <> lower(email);
+
+ALTER TABLE users DROP CONSTRAINT IF EXISTS users_email_key;
+CREATE UNIQUE INDEX users_email_lower_key ON users (lower(email));
@@ -0,0 +1,16 @@
+DROP TABLE IF EXISTS audit_events;
+DROP TABLE IF EXISTS personal_access_tokens;
+DROP TABLE IF EXISTS oidc_login_states;
+DROP TABLE IF EXISTS user_identities;
+DROP TABLE IF EXISTS recovery_codes;
+DROP TABLE IF EXISTS password_reset_tokens;
+DROP TABLE IF EXISTS refresh_tokens;
+DROP TABLE IF EXISTS sessions;
+DROP TABLE IF EXISTS reviews;
+DROP TABLE IF EXISTS collection_books;
+DROP TABLE IF EXISTS collections;
+DROP TABLE IF EXISTS user_books;
+DROP TABLE IF EXISTS book_genres;
+DROP TABLE IF EXISTS genres;
+DROP TABLE IF EXISTS books;
+DROP TABLE IF EXISTS users;
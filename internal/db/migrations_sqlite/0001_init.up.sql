@@ -0,0 +1,201 @@
+-- The SQLite schema mirrors the Postgres migrations up to 0012. Timestamps
+-- are TIMESTAMP text written by the application in UTC, which the driver
+-- reads back as time.Time; arrays and JSON are stored as JSON text.
+
+CREATE TABLE users (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	email TEXT NOT NULL UNIQUE,
+	password_hash TEXT NOT NULL,
+	name TEXT NOT NULL DEFAULT '',
+	created_at TIMESTAMP NOT NULL,
+	email_verified_at TIMESTAMP,
+	verification_sent_at TIMESTAMP,
+	totp_secret TEXT,
+	totp_enabled_at TIMESTAMP,
+	totp_last_counter INTEGER,
+	role TEXT NOT NULL DEFAULT 'user'
+		CHECK (role IN ('user', 'moderator', 'admin')),
+	suspended_at TIMESTAMP,
+	suspend_reason TEXT NOT NULL DEFAULT '',
+	deletion_requested_at TIMESTAMP
+);
+
+CREATE INDEX users_role_idx ON users (role) WHERE role <> 'user';
+CREATE INDEX users_deletion_requested_at_idx ON users (deletion_requested_at)
+	WHERE deletion_requested_at IS NOT NULL;
+
+CREATE TABLE books (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	google_id TEXT NOT NULL UNIQUE,
+	title TEXT NOT NULL,
+	author TEXT NOT NULL DEFAULT '',
+	cover_url TEXT NOT NULL DEFAULT '',
+	description TEXT NOT NULL DEFAULT '',
+	published_year INTEGER,
+	page_count INTEGER,
+	age_rating TEXT NOT NULL DEFAULT '',
+	created_at TIMESTAMP NOT NULL
+);
+
+CREATE TABLE genres (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	name TEXT NOT NULL UNIQUE
+);
+
+CREATE TABLE book_genres (
+	book_id INTEGER NOT NULL REFERENCES books(id) ON DELETE CASCADE,
+	genre_id INTEGER NOT NULL REFERENCES genres(id) ON DELETE CASCADE,
+	PRIMARY KEY (book_id, genre_id)
+);
+
+CREATE INDEX book_genres_genre_id_idx ON book_genres (genre_id);
+
+CREATE TABLE user_books (
+	user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+	book_id INTEGER NOT NULL REFERENCES books(id) ON DELETE CASCADE,
+	status TEXT NOT NULL DEFAULT 'planned'
+		CHECK (status IN ('planned', 'reading', 'finished', 'dropped')),
+	created_at TIMESTAMP NOT NULL,
+	PRIMARY KEY (user_id, book_id)
+);
+
+CREATE INDEX user_books_book_id_idx ON user_books (book_id);
+
+CREATE TABLE collections (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+	name TEXT NOT NULL,
+	created_at TIMESTAMP NOT NULL,
+	UNIQUE (user_id, name)
+);
+
+CREATE TABLE collection_books (
+	user_id INTEGER NOT NULL,
+	collection_id INTEGER NOT NULL REFERENCES collections(id) ON DELETE CASCADE,
+	book_id INTEGER NOT NULL,
+	created_at TIMESTAMP NOT NULL,
+	PRIMARY KEY (collection_id, book_id),
+	FOREIGN KEY (user_id, book_id) REFERENCES user_books (user_id, book_id) ON DELETE CASCADE
+);
+
+CREATE INDEX collection_books_user_book_idx ON collection_books (user_id, book_id);
+
+-- user_id is NULL for the anonymised reviews of deleted accounts
+CREATE TABLE reviews (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	user_id INTEGER REFERENCES users(id) ON DELETE CASCADE,
+	book_id INTEGER NOT NULL REFERENCES books(id) ON DELETE CASCADE,
+	rating INTEGER NOT NULL CHECK (rating BETWEEN 1 AND 5),
+	text TEXT NOT NULL,
+	created_at TIMESTAMP NOT NULL,
+	UNIQUE (user_id, book_id)
+);
+
+CREATE INDEX reviews_book_id_created_at_idx ON reviews (book_id, created_at DESC);
+
+CREATE TABLE sessions (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+	user_agent TEXT NOT NULL DEFAULT '',
+	ip TEXT NOT NULL DEFAULT '',
+	created_at TIMESTAMP NOT NULL,
+	last_seen_at TIMESTAMP NOT NULL,
+	expires_at TIMESTAMP NOT NULL,
+	revoked_at TIMESTAMP,
+	revoke_reason TEXT NOT NULL DEFAULT ''
+);
+
+CREATE INDEX sessions_user_id_active_idx ON sessions (user_id) WHERE revoked_at IS NULL;
+
+CREATE TABLE refresh_tokens (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	session_id INTEGER NOT NULL REFERENCES sessions(id) ON DELETE CASCADE,
+	token_hash TEXT NOT NULL UNIQUE,
+	created_at TIMESTAMP NOT NULL,
+	expires_at TIMESTAMP NOT NULL,
+	used_at TIMESTAMP
+);
+
+CREATE INDEX refresh_tokens_session_id_idx ON refresh_tokens (session_id);
+
+CREATE TABLE password_reset_tokens (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+	token_hash TEXT NOT NULL UNIQUE,
+	created_at TIMESTAMP NOT NULL,
+	expires_at TIMESTAMP NOT NULL,
+	used_at TIMESTAMP
+);
+
+CREATE INDEX password_reset_tokens_user_id_idx ON password_reset_tokens (user_id);
+
+CREATE TABLE recovery_codes (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+	code_hash TEXT NOT NULL,
+	created_at TIMESTAMP NOT NULL,
+	used_at TIMESTAMP,
+	UNIQUE (user_id, code_hash)
+);
+
+CREATE TABLE user_identities (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+	provider TEXT NOT NULL,
+	subject TEXT NOT NULL,
+	email TEXT NOT NULL DEFAULT '',
+	created_at TIMESTAMP NOT NULL,
+	UNIQUE (provider, subject),
+	UNIQUE (user_id, provider)
+);
+
+CREATE TABLE oidc_login_states (
+	state_hash TEXT PRIMARY KEY,
+	provider TEXT NOT NULL,
+	code_verifier TEXT NOT NULL,
+	nonce TEXT NOT NULL,
+	link_user_id INTEGER REFERENCES users(id) ON DELETE CASCADE,
+	created_at TIMESTAMP NOT NULL,
+	expires_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX oidc_login_states_expires_at_idx ON oidc_login_states (expires_at);
+
+-- scopes is a JSON array of strings
+CREATE TABLE personal_access_tokens (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+	name TEXT NOT NULL,
+	token_hash TEXT NOT NULL UNIQUE,
+	scopes TEXT NOT NULL,
+	created_at TIMESTAMP NOT NULL,
+	last_used_at TIMESTAMP,
+	expires_at TIMESTAMP,
+	revoked_at TIMESTAMP
+);
+
+CREATE INDEX personal_access_tokens_user_id_idx ON personal_access_tokens (user_id);
+
+-- user ids are kept without foreign keys so entries outlive deleted accounts
+CREATE TABLE audit_events (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	event_type TEXT NOT NULL,
+	actor_id INTEGER,
+	user_id INTEGER,
+	ip TEXT NOT NULL DEFAULT '',
+	user_agent TEXT NOT NULL DEFAULT '',
+	details TEXT NOT NULL DEFAULT '{}',
+	created_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX audit_events_user_id_idx ON audit_events (user_id, created_at DESC);
+CREATE INDEX audit_events_actor_id_idx ON audit_events (actor_id, created_at DESC);
+CREATE INDEX audit_events_type_idx ON audit_events (event_type, created_at DESC);
+
+CREATE TRIGGER audit_events_no_update
+	BEFORE UPDATE ON audit_events
+	BEGIN SELECT RAISE(ABORT, 'audit_events is append-only'); END;
+
+CREATE TRIGGER audit_events_no_delete
+	BEFORE DELETE ON audit_events
+	BEGIN SELECT RAISE(ABORT, 'audit_events is append-only'); END;
@@ -0,0 +1,2 @@
+-- Addresses stay lowercase.
+DROP INDEX IF EXISTS users_email_nocase_key;
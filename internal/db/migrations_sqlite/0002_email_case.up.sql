@@ -0,0 +1,8 @@
+-- Mirrors 0014_email_case: addresses are lowercased and unique without
+-- regard to case. SQLite's lower() and NOCASE fold ASCII letters only; the
+-- service lowercases every address it stores. Accounts that already differ
+-- by case only make the UPDATE fail on the email UNIQUE constraint and must
+-- be merged or renamed by hand first.
+UPDATE users SET email = lower(email) WHERE email <> lower(email);
+
+CREATE UNIQUE INDEX users_email_nocase_key ON users (email COLLATE NOCASE);
@@ -0,0 +1,213 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+//go:embed migrations_sqlite/*.sql
+var sqliteMigrationsFS embed.FS
+
+// OpenSQLite opens the SQLite database file at path, creating it if needed.
+// Foreign keys are enforced and transactions take the write lock up front.
+// SQLite allows one writer at a time, so the pool keeps a single
+// connection and callers queue for it instead of failing with SQLITE_BUSY.
+func OpenSQLite(path string) (*sql.DB, error) {
+	q := url.Values{}
+	q.Set("_foreign_keys", "on")
+	q.Set("_busy_timeout", "5000")
+	q.Set("_journal_mode", "WAL")
+	q.Set("_txlock", "immediate")
+
+	sqlDB, err := sql.Open("sqlite3", "file:"+path+"?"+q.Encode())
+	if err != nil {
+		return nil, err
+	}
+	sqlDB.SetMaxOpenConns(1)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := sqlDB.PingContext(ctx); err != nil {
+		sqlDB.Close()
+		return nil, err
+	}
+	return sqlDB, nil
+}
+
+// SQLiteMigrator applies the migrations_sqlite scripts. It keeps the same
+// schema_migrations bookkeeping as Migrator; no lock is needed because
+// a SQLite file serves a single instance.
+type SQLiteMigrator struct {
+	db         *sql.DB
+	migrations []Migration
+}
+
+func NewSQLiteMigrator(sqlDB *sql.DB) (*SQLiteMigrator, error) {
+	migrations, err := LoadMigrations(sqliteMigrationsFS, "migrations_sqlite")
+	if err != nil {
+		return nil, err
+	}
+	return &SQLiteMigrator{db: sqlDB, migrations: migrations}, nil
+}
+
+// Up applies every pending migration and returns how many were applied.
+func (m *SQLiteMigrator) Up(ctx context.Context) (int, error) {
+	done, err := m.verify(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	applied := 0
+	for _, mig := range m.migrations {
+		if _, ok := done[mig.Version]; ok {
+			continue
+		}
+		err := m.inTx(ctx, func(tx *sql.Tx) error {
+			if _, err := tx.ExecContext(ctx, mig.Up); err != nil {
+				return err
+			}
+			_, err := tx.ExecContext(ctx, `
+				INSERT INTO schema_migrations (version, name, checksum, applied_at)
+				VALUES (?, ?, ?, ?)
+			`, mig.Version, mig.Name, mig.Checksum, time.Now().UTC())
+			return err
+		})
+		if err != nil {
+			return applied, fmt.Errorf("migration %d_%s up: %w", mig.Version, mig.Name, err)
+		}
+		log.Printf("Применена миграция %04d_%s", mig.Version, mig.Name)
+		applied++
+	}
+	return applied, nil
+}
+
+// Down rolls back the last steps applied migrations.
+func (m *SQLiteMigrator) Down(ctx context.Context, steps int) (int, error) {
+	if steps <= 0 {
+		return 0, errors.New("steps must be positive")
+	}
+	done, err := m.verify(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	reverted := 0
+	for i := len(m.migrations) - 1; i >= 0 && reverted < steps; i-- {
+		mig := m.migrations[i]
+		if _, ok := done[mig.Version]; !ok {
+			continue
+		}
+		if mig.Down == "" {
+			return reverted, fmt.Errorf("migration %d_%s has no down script", mig.Version, mig.Name)
+		}
+		err := m.inTx(ctx, func(tx *sql.Tx) error {
+			if _, err := tx.ExecContext(ctx, mig.Down); err != nil {
+				return err
+			}
+			_, err := tx.ExecContext(ctx, `DELETE FROM schema_migrations WHERE version = ?`, mig.Version)
+			return err
+		})
+		if err != nil {
+			return reverted, fmt.Errorf("migration %d_%s down: %w", mig.Version, mig.Name, err)
+		}
+		log.Printf("Откачена миграция %04d_%s", mig.Version, mig.Name)
+		reverted++
+	}
+	return reverted, nil
+}
+
+func (m *SQLiteMigrator) Status(ctx context.Context) ([]MigrationStatus, error) {
+	done, err := m.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]MigrationStatus, 0, len(m.migrations))
+	for _, mig := range m.migrations {
+		st := MigrationStatus{Version: mig.Version, Name: mig.Name}
+		if a, ok := done[mig.Version]; ok {
+			at := a.appliedAt
+			st.AppliedAt = &at
+		}
+		out = append(out, st)
+	}
+	return out, nil
+}
+
+func (m *SQLiteMigrator) applied(ctx context.Context) (map[int]appliedMigration, error) {
+	_, err := m.db.ExecContext(ctx, `
+	CREATE TABLE IF NOT EXISTS schema_migrations (
+	version INTEGER PRIMARY KEY,
+	name TEXT NOT NULL,
+	checksum TEXT NOT NULL,
+	applied_at TIMESTAMP NOT NULL
+	);
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("create schema_migrations: %w", err)
+	}
+
+	rows, err := m.db.QueryContext(ctx, `SELECT version, name, checksum, applied_at FROM schema_migrations`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := map[int]appliedMigration{}
+	for rows.Next() {
+		var version int
+		var a appliedMigration
+		if err := rows.Scan(&version, &a.name, &a.checksum, &a.appliedAt); err != nil {
+			return nil, err
+		}
+		out[version] = a
+	}
+	return out, rows.Err()
+}
+
+// verify makes sure every applied migration is still known and unchanged.
+func (m *SQLiteMigrator) verify(ctx context.Context) (map[int]appliedMigration, error) {
+	done, err := m.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkApplied(m.migrations, done); err != nil {
+		return nil, err
+	}
+	return done, nil
+}
+
+func (m *SQLiteMigrator) inTx(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// MigrateSQLite applies all pending migrations on sqlDB.
+func MigrateSQLite(ctx context.Context, sqlDB *sql.DB) error {
+	m, err := NewSQLiteMigrator(sqlDB)
+	if err != nil {
+		return err
+	}
+	n, err := m.Up(ctx)
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		log.Println("Схема БД актуальна")
+	}
+	return nil
+}
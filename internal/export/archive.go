@@ -0,0 +1,117 @@
+package export
+
+import (
+	"archive/zip"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"bookpulse/internal/repo"
+)
+
+// WriteArchive writes data to w as a zip archive: the full export as JSON
+// plus CSV files for the tabular parts.
+func WriteArchive(w io.Writer, data *repo.UserExport) error {
+	zw := zip.NewWriter(w)
+
+	if err := writeJSON(zw, "export.json", data); err != nil {
+		return err
+	}
+
+	library := [][]string{{"google_id", "title", "author", "status", "added_at"}}
+	for _, b := range data.Library {
+		library = append(library, []string{b.GoogleID, b.Title, b.Author, b.Status, formatTime(b.AddedAt)})
+	}
+	if err := writeCSV(zw, "library.csv", library); err != nil {
+		return err
+	}
+
+	collections := [][]string{{"collection", "created_at", "google_id"}}
+	for _, c := range data.Collections {
+		if len(c.Books) == 0 {
+			collections = append(collections, []string{c.Name, formatTime(c.CreatedAt), ""})
+		}
+		for _, id := range c.Books {
+			collections = append(collections, []string{c.Name, formatTime(c.CreatedAt), id})
+		}
+	}
+	if err := writeCSV(zw, "collections.csv", collections); err != nil {
+		return err
+	}
+
+	reviews := [][]string{{"google_id", "title", "rating", "text", "created_at"}}
+	for _, r := range data.Reviews {
+		reviews = append(reviews, []string{r.GoogleID, r.Title, strconv.Itoa(r.Rating), r.Text, formatTime(r.CreatedAt)})
+	}
+	if err := writeCSV(zw, "reviews.csv", reviews); err != nil {
+		return err
+	}
+
+	sessions := [][]string{{"user_agent", "ip", "created_at", "last_seen_at", "revoked_at"}}
+	for _, s := range data.Sessions {
+		sessions = append(sessions, []string{s.UserAgent, s.IP, formatTime(s.CreatedAt), formatTime(s.LastSeenAt), formatTimePtr(s.RevokedAt)})
+	}
+	if err := writeCSV(zw, "sessions.csv", sessions); err != nil {
+		return err
+	}
+
+	events := [][]string{{"type", "ip", "user_agent", "created_at"}}
+	for _, e := range data.SecurityEvents {
+		events = append(events, []string{e.Type, e.IP, e.UserAgent, formatTime(e.CreatedAt)})
+	}
+	if err := writeCSV(zw, "security_events.csv", events); err != nil {
+		return err
+	}
+
+	return zw.Close()
+}
+
+func writeJSON(zw *zip.Writer, name string, v any) error {
+	f, err := zw.Create(name)
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(f)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+func writeCSV(zw *zip.Writer, name string, records [][]string) error {
+	f, err := zw.Create(name)
+	if err != nil {
+		return err
+	}
+	cw := csv.NewWriter(f)
+	for _, rec := range records {
+		for i, field := range rec {
+			rec[i] = neutralizeFormula(field)
+		}
+	}
+	if err := cw.WriteAll(records); err != nil {
+		return err
+	}
+	return cw.Error()
+}
+
+// neutralizeFormula stops spreadsheet apps from evaluating user text such as
+// review bodies as formulas.
+func neutralizeFormula(s string) string {
+	if s != "" && strings.ContainsRune("=+-@\t\r", rune(s[0])) {
+		return "'" + s
+	}
+	return s
+}
+
+func formatTime(t time.Time) string {
+	return t.UTC().Format(time.RFC3339)
+}
+
+func formatTimePtr(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return formatTime(*t)
+}
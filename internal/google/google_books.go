@@ -1,6 +1,7 @@
 package google
 
 import (
+	"bookpulse/internal/respond"
 	"encoding/json"
 	"fmt"
 	"net/http"
@@ -10,12 +11,16 @@ import (
 )
 
 type GoogleBooksHandler struct {
-	Client *http.Client
+	Client  *http.Client
+	BaseURL string
+	APIKey  string
 }
 
-func NewGoogleBooksHandler() *GoogleBooksHandler {
+func NewGoogleBooksHandler(baseURL, apiKey string, timeout time.Duration) *GoogleBooksHandler {
 	return &GoogleBooksHandler{
-		Client: &http.Client{Timeout: 10 * time.Second},
+		Client:  &http.Client{Timeout: timeout},
+		BaseURL: strings.TrimRight(baseURL, "/"),
+		APIKey:  apiKey,
 	}
 }
 
@@ -32,28 +37,11 @@ type GoogleBookDTO struct {
 	Maturity      string   `json:"maturity"`
 }
 
+// Search serves GET /api/books/google?q=&max=.
 func (h *GoogleBooksHandler) Search(w http.ResponseWriter, r *http.Request) {
-	if r.Method == http.MethodOptions {
-		w.WriteHeader(http.StatusNoContent)
-		return
-	}
-
-	if r.Method != http.MethodGet {
-		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
-		return
-	}
-	if r.Method == http.MethodOptions {
-		w.WriteHeader(http.StatusNoContent)
-		return
-	}
-	if r.Method != http.MethodGet {
-		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
-		return
-	}
-
 	q := strings.TrimSpace(r.URL.Query().Get("q"))
 	if q == "" {
-		http.Error(w, "missing query param: q", http.StatusBadRequest)
+		respond.WriteError(w, r, respond.Field("q", "required", "q is required"))
 		return
 	}
 
@@ -62,43 +50,40 @@ func (h *GoogleBooksHandler) Search(w http.ResponseWriter, r *http.Request) {
 		max = "12"
 	}
 
-	u := "https://www.googleapis.com/books/v1/volumes?q=" + url.QueryEscape(q) + "&maxResults=" + url.QueryEscape(max)
+	u := h.BaseURL + "/volumes?q=" + url.QueryEscape(q) + "&maxResults=" + url.QueryEscape(max)
+	if h.APIKey != "" {
+		u += "&key=" + url.QueryEscape(h.APIKey)
+	}
 
 	items, err := h.fetchVolumes(r, u)
 	if err != nil {
-		http.Error(w, "google books error: "+err.Error(), http.StatusBadGateway)
+		respond.WriteError(w, r, unavailable(err))
 		return
 	}
 
-	writeJSON(w, items)
+	respond.JSON(w, http.StatusOK, items)
 }
 
+// GetByID serves GET /api/books/google/{id}.
 func (h *GoogleBooksHandler) GetByID(w http.ResponseWriter, r *http.Request) {
-	if r.Method == http.MethodOptions {
-		w.WriteHeader(http.StatusNoContent)
-		return
-	}
-	if r.Method != http.MethodGet {
-		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
-		return
-	}
-
-	id := strings.TrimPrefix(r.URL.Path, "/api/books/google/")
-	id = strings.TrimSpace(id)
+	id := strings.TrimSpace(r.PathValue("id"))
 	if id == "" {
-		http.Error(w, "missing id", http.StatusBadRequest)
+		respond.WriteError(w, r, respond.Field("id", "required", "id is required"))
 		return
 	}
 
-	u := "https://www.googleapis.com/books/v1/volumes/" + url.PathEscape(id)
+	u := h.BaseURL + "/volumes/" + url.PathEscape(id)
+	if h.APIKey != "" {
+		u += "?key=" + url.QueryEscape(h.APIKey)
+	}
 
 	dto, err := h.fetchVolumeByID(r, u)
 	if err != nil {
-		http.Error(w, "google books error: "+err.Error(), http.StatusBadGateway)
+		respond.WriteError(w, r, unavailable(err))
 		return
 	}
 
-	writeJSON(w, dto)
+	respond.JSON(w, http.StatusOK, dto)
 }
 
 type gbSearchResp struct {
@@ -213,7 +198,9 @@ func parseYear(s string) int {
 	return 0
 }
 
-func writeJSON(w http.ResponseWriter, v any) {
-	w.Header().Set("Content-Type", "application/json; charset=utf-8")
-	_ = json.NewEncoder(w).Encode(v)
+// unavailable reports a failed upstream call; err is logged, not sent.
+func unavailable(err error) *respond.Error {
+	e := respond.NewError(http.StatusBadGateway, "google_books_unavailable", "Google Books is unavailable")
+	e.Err = err
+	return e
 }
@@ -0,0 +1,71 @@
+package handlers
+
+import (
+	"bookpulse/internal/repo"
+	"bookpulse/internal/request"
+	"bookpulse/internal/respond"
+	"bookpulse/internal/router"
+	"bookpulse/internal/service/auth"
+	"net/http"
+	"time"
+)
+
+type CreateAccessTokenRequest struct {
+	Name          string   `json:"name"`
+	Scopes        []string `json:"scopes"`
+	ExpiresInDays int      `json:"expiresInDays" validate:"min=0,max=365"`
+}
+
+// ListAccessTokens serves GET /api/me/tokens. Tokens are managed from a
+// signed-in session only, never with another token.
+func ListAccessTokens(authSvc *auth.ServicePGX) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID := caller(r).UserID
+
+		list, err := authSvc.AccessTokens(r.Context(), userID)
+		if err != nil {
+			respond.WriteError(w, r, respond.Internal(err))
+			return
+		}
+		respond.JSON(w, http.StatusOK, map[string]any{"tokens": list, "scopes": auth.Scopes})
+	}
+}
+
+// CreateAccessToken serves POST /api/me/tokens. The token itself is only in
+// this response.
+func CreateAccessToken(authSvc *auth.ServicePGX) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID := caller(r).UserID
+
+		var body CreateAccessTokenRequest
+		if err := request.Decode(r, &body); err != nil {
+			respond.WriteError(w, r, err)
+			return
+		}
+		created, err := authSvc.CreateAccessToken(r.Context(), userID, body.Name, body.Scopes,
+			time.Duration(body.ExpiresInDays)*24*time.Hour, auth.ClientFromRequest(r))
+		if err != nil {
+			respond.WriteError(w, r, authError(err))
+			return
+		}
+		respond.JSON(w, http.StatusCreated, created)
+	}
+}
+
+// RevokeAccessToken serves DELETE /api/me/tokens/{id}.
+func RevokeAccessToken(authSvc *auth.ServicePGX) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID := caller(r).UserID
+
+		id, err := router.Int64Param(r, "id")
+		if err != nil {
+			respond.WriteError(w, r, authError(repo.ErrAccessTokenNotFound))
+			return
+		}
+		if err := authSvc.RevokeAccessToken(r.Context(), userID, id, auth.ClientFromRequest(r)); err != nil {
+			respond.WriteError(w, r, authError(err))
+			return
+		}
+		respond.JSON(w, http.StatusOK, map[string]any{"ok": true})
+	}
+}
@@ -0,0 +1,83 @@
+package handlers
+
+import (
+	"bookpulse/internal/export"
+	"bookpulse/internal/request"
+	"bookpulse/internal/respond"
+	"bookpulse/internal/service/auth"
+	"bytes"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// ExportAccount serves GET /api/me/export: a zip archive with everything
+// stored about the caller.
+func ExportAccount(authSvc *auth.ServicePGX) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID := caller(r).UserID
+
+		data, err := authSvc.ExportData(r.Context(), userID, auth.ClientFromRequest(r))
+		if err != nil {
+			respond.WriteError(w, r, authError(err))
+			return
+		}
+
+		// build in memory so a failure still gets a proper error status
+		var buf bytes.Buffer
+		if err := export.WriteArchive(&buf, data); err != nil {
+			respond.WriteError(w, r, respond.Internal(fmt.Errorf("export archive: %w", err)))
+			return
+		}
+
+		name := fmt.Sprintf("bookpulse-export-%d-%s.zip", userID, time.Now().UTC().Format("20060102"))
+		w.Header().Set("Content-Type", "application/zip")
+		w.Header().Set("Content-Disposition", `attachment; filename="`+name+`"`)
+		w.Header().Set("Content-Length", strconv.Itoa(buf.Len()))
+		w.Header().Set("Cache-Control", "no-store")
+		_, _ = w.Write(buf.Bytes())
+	}
+}
+
+type DeleteAccountRequest struct {
+	Password     string `json:"password"`
+	ConfirmEmail string `json:"confirmEmail"`
+}
+
+// DeleteAccount serves POST /api/me/delete. The account is purged after the
+// configured grace period unless restored first.
+func DeleteAccount(authSvc *auth.ServicePGX) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID := caller(r).UserID
+
+		var body DeleteAccountRequest
+		if err := request.Decode(r, &body); err != nil {
+			respond.WriteError(w, r, err)
+			return
+		}
+
+		res, err := authSvc.RequestDeletion(r.Context(), userID, body.Password, body.ConfirmEmail, auth.ClientFromRequest(r))
+		if err != nil {
+			respond.WriteError(w, r, authError(err))
+			return
+		}
+
+		respond.JSON(w, http.StatusOK, map[string]any{"ok": true, "deletion": res})
+	}
+}
+
+// RestoreAccount serves POST /api/me/restore, cancelling a pending deletion.
+func RestoreAccount(authSvc *auth.ServicePGX) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID := caller(r).UserID
+
+		u, err := authSvc.RestoreAccount(r.Context(), userID, auth.ClientFromRequest(r))
+		if err != nil {
+			respond.WriteError(w, r, authError(err))
+			return
+		}
+
+		respond.JSON(w, http.StatusOK, u)
+	}
+}
@@ -0,0 +1,113 @@
+package handlers
+
+import (
+	"bookpulse/internal/request"
+	"bookpulse/internal/respond"
+	"bookpulse/internal/router"
+	"bookpulse/internal/service/auth"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+type SetRoleRequest struct {
+	Role string `json:"role" validate:"required"`
+}
+
+type SuspendRequest struct {
+	Reason string `json:"reason" validate:"max=500"`
+}
+
+// The admin user API is mounted behind middleware.RequireRole(auth.RoleAdmin):
+//
+//	GET    /api/admin/users?q=&page=&limit=
+//	GET    /api/admin/users/{id}
+//	DELETE /api/admin/users/{id}
+//	PATCH  /api/admin/users/{id}/role
+//	POST   /api/admin/users/{id}/suspend
+//	POST   /api/admin/users/{id}/unsuspend
+//	POST   /api/admin/users/{id}/force-password-reset
+
+func AdminListUsers(authSvc *auth.ServicePGX) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		page, _ := strconv.Atoi(q.Get("page"))
+		limit, _ := strconv.Atoi(q.Get("limit"))
+
+		res, err := authSvc.SearchUsers(r.Context(), q.Get("q"), page, limit)
+		if err != nil {
+			respond.WriteError(w, r, respond.Internal(err))
+			return
+		}
+		respond.JSON(w, http.StatusOK, res)
+	}
+}
+
+func AdminGetUser(authSvc *auth.ServicePGX) http.HandlerFunc {
+	return adminUserAction(func(r *http.Request, actor auth.Actor, userID int) (any, error) {
+		return authSvc.UserSummary(r.Context(), userID)
+	})
+}
+
+func AdminDeleteUser(authSvc *auth.ServicePGX) http.HandlerFunc {
+	return adminUserAction(func(r *http.Request, actor auth.Actor, userID int) (any, error) {
+		return map[string]any{"ok": true}, authSvc.DeleteUser(r.Context(), actor, userID)
+	})
+}
+
+func AdminSetRole(authSvc *auth.ServicePGX) http.HandlerFunc {
+	return adminUserAction(func(r *http.Request, actor auth.Actor, userID int) (any, error) {
+		var body SetRoleRequest
+		if err := request.Decode(r, &body); err != nil {
+			return nil, err
+		}
+		return authSvc.SetRole(r.Context(), actor, userID, body.Role)
+	})
+}
+
+func AdminSuspendUser(authSvc *auth.ServicePGX) http.HandlerFunc {
+	return adminUserAction(func(r *http.Request, actor auth.Actor, userID int) (any, error) {
+		var body SuspendRequest
+		if r.ContentLength != 0 {
+			if err := request.Decode(r, &body); err != nil {
+				return nil, err
+			}
+		}
+		return authSvc.SuspendUser(r.Context(), actor, userID, strings.TrimSpace(body.Reason))
+	})
+}
+
+func AdminUnsuspendUser(authSvc *auth.ServicePGX) http.HandlerFunc {
+	return adminUserAction(func(r *http.Request, actor auth.Actor, userID int) (any, error) {
+		return authSvc.UnsuspendUser(r.Context(), actor, userID)
+	})
+}
+
+func AdminForcePasswordReset(authSvc *auth.ServicePGX) http.HandlerFunc {
+	return adminUserAction(func(r *http.Request, actor auth.Actor, userID int) (any, error) {
+		return map[string]any{"ok": true}, authSvc.ForcePasswordReset(r.Context(), actor, userID)
+	})
+}
+
+// adminUserAction resolves the acting admin and the {id} user of the route,
+// runs action and writes its result or error.
+func adminUserAction(action func(r *http.Request, actor auth.Actor, userID int) (any, error)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		actorID := caller(r).UserID
+		actor := auth.Actor{UserID: actorID, Client: auth.ClientFromRequest(r)}
+
+		userID, err := router.IntParam(r, "id")
+		if err != nil {
+			respond.WriteError(w, r, authError(auth.ErrUserNotFound))
+			return
+		}
+
+		result, err := action(r, actor, userID)
+		if err != nil {
+			respond.WriteError(w, r, authError(err))
+			return
+		}
+
+		respond.JSON(w, http.StatusOK, result)
+	}
+}
@@ -0,0 +1,80 @@
+package handlers
+
+import (
+	"bookpulse/internal/repo"
+	"bookpulse/internal/request"
+	"bookpulse/internal/respond"
+	"bookpulse/internal/service/auth"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// auditPage is the response for both audit listings. NextBefore is passed
+// back as ?before= to fetch the next (older) page; it is 0 once a page comes
+// back empty.
+type auditPage struct {
+	Events     []repo.AuditEvent `json:"events"`
+	NextBefore int64             `json:"nextBefore"`
+}
+
+func newAuditPage(events []repo.AuditEvent) auditPage {
+	p := auditPage{Events: events}
+	if len(events) > 0 {
+		p.NextBefore = events[len(events)-1].ID
+	}
+	return p
+}
+
+// SecurityEvents serves GET /api/me/security-events?before=&limit=,
+// the signed-in user's own account activity.
+func SecurityEvents(authSvc *auth.ServicePGX) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID := caller(r).UserID
+
+		q := r.URL.Query()
+		before, _ := strconv.ParseInt(q.Get("before"), 10, 64)
+		limit, _ := strconv.Atoi(q.Get("limit"))
+
+		events, err := authSvc.SecurityEvents(r.Context(), userID, before, limit)
+		if err != nil {
+			respond.WriteError(w, r, respond.Internal(err))
+			return
+		}
+
+		respond.JSON(w, http.StatusOK, newAuditPage(events))
+	}
+}
+
+// AdminAuditEvents serves
+// GET /api/admin/audit-events?userId=&type=a,b&before=&limit=. It is mounted
+// behind middleware.RequireRole(auth.RoleAdmin).
+func AdminAuditEvents(authSvc *auth.ServicePGX) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		var f repo.AuditFilter
+		if v := q.Get("userId"); v != "" {
+			id, err := strconv.Atoi(v)
+			if err != nil || id <= 0 {
+				respond.WriteError(w, r, respond.Field("userId", request.CodeInvalid, "userId must be a positive integer"))
+				return
+			}
+			f.UserID = id
+		}
+		for _, t := range strings.Split(q.Get("type"), ",") {
+			if t = strings.TrimSpace(t); t != "" {
+				f.Types = append(f.Types, t)
+			}
+		}
+		f.BeforeID, _ = strconv.ParseInt(q.Get("before"), 10, 64)
+		f.Limit, _ = strconv.Atoi(q.Get("limit"))
+
+		events, err := authSvc.AuditEvents(r.Context(), f)
+		if err != nil {
+			respond.WriteError(w, r, respond.Internal(err))
+			return
+		}
+
+		respond.JSON(w, http.StatusOK, newAuditPage(events))
+	}
+}
@@ -1,39 +1,96 @@
 package handlers
 
 import (
+	"bookpulse/internal/repo"
+	"bookpulse/internal/request"
+	"bookpulse/internal/respond"
 	"bookpulse/internal/service/auth"
-	"bookpulse/internal/utils"
-	"encoding/json"
+	"errors"
 	"net/http"
 )
 
 func Authorization(authSvc *auth.ServicePGX) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		if r.Method == http.MethodOptions {
-			w.WriteHeader(http.StatusNoContent)
-			return
+		var body struct {
+			Email    string `json:"email"`
+			Password string `json:"password"`
 		}
-		if r.Method != http.MethodPost {
-			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		if err := request.Decode(r, &body); err != nil {
+			respond.WriteError(w, r, err)
 			return
 		}
 
-		var body struct {
-			Email    string `json:"email"`
-			Password string `json:"password"`
+		resp, err := authSvc.Login(r.Context(), body.Email, body.Password, auth.ClientFromRequest(r))
+		var challenge *auth.TwoFactorRequiredError
+		if errors.As(err, &challenge) {
+			respond.JSON(w, http.StatusOK, map[string]any{
+				"twoFactorRequired": true,
+				"challengeToken":    challenge.ChallengeToken,
+				"expiresIn":         challenge.ExpiresIn,
+			})
+			return
+		}
+		if errors.Is(err, repo.ErrInvalidCredentials) {
+			respond.WriteError(w, r, respond.NewError(http.StatusUnauthorized, CodeInvalidCredentials, "invalid email or password"))
+			return
 		}
-		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
-			http.Error(w, "bad json", http.StatusBadRequest)
+		if err != nil {
+			respond.WriteError(w, r, authError(err))
+			return
+		}
+
+		respond.JSON(w, http.StatusOK, resp)
+	}
+}
+
+type RefreshRequest struct {
+	RefreshToken string `json:"refreshToken"`
+}
+
+func Refresh(authSvc *auth.ServicePGX) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var body RefreshRequest
+		if err := request.Decode(r, &body); err != nil {
+			respond.WriteError(w, r, err)
 			return
 		}
 
-		resp, err := authSvc.Login(r.Context(), body.Email, body.Password)
+		resp, err := authSvc.Refresh(r.Context(), body.RefreshToken)
 		if err != nil {
-			http.Error(w, "invalid credentials", http.StatusUnauthorized)
+			respond.WriteError(w, r, authError(err))
+			return
+		}
+
+		respond.JSON(w, http.StatusOK, resp)
+	}
+}
+
+func Logout(authSvc *auth.ServicePGX) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var body RefreshRequest
+		if r.ContentLength != 0 {
+			if err := request.Decode(r, &body); err != nil {
+				respond.WriteError(w, r, err)
+				return
+			}
+		}
+
+		// the access token, when sent, lets logout work without a refresh token
+		var userID int
+		var sessionID int64
+		if p, ok := auth.PrincipalFrom(r.Context()); ok && !p.IsAccessToken() {
+			userID, sessionID = p.UserID, p.SessionID
+		}
+
+		if err := authSvc.Logout(r.Context(), body.RefreshToken, userID, sessionID); err != nil {
+			if errors.Is(err, auth.ErrInvalidRefreshToken) || errors.Is(err, repo.ErrSessionNotFound) {
+				respond.WriteError(w, r, respond.Unauthorized())
+				return
+			}
+			respond.WriteError(w, r, respond.Internal(err))
 			return
 		}
 
-		w.Header().Set("Content-Type", "application/json; charset=utf-8")
-		utils.WriteJSON(w, resp)
+		respond.JSON(w, http.StatusOK, map[string]any{"ok": true})
 	}
-}
\ No newline at end of file
+}
@@ -0,0 +1,30 @@
+package handlers
+
+import (
+	"bookpulse/internal/respond"
+	"bookpulse/internal/service/auth"
+	"net/http"
+)
+
+// caller returns the principal the route's auth middleware put in the
+// context. Routes using it must be registered behind middleware.RequireAuth
+// or RequireSession.
+func caller(r *http.Request) *auth.Principal {
+	p, ok := auth.PrincipalFrom(r.Context())
+	if !ok {
+		panic("handlers: route " + r.Pattern + " is registered without auth middleware")
+	}
+	return p
+}
+
+// authorize checks that a personal access token carries scope and writes
+// the 403 itself.
+func authorize(w http.ResponseWriter, r *http.Request, scope string) (int, bool) {
+	p := caller(r)
+	if !p.Allows(scope) {
+		respond.WriteError(w, r, respond.Forbidden(CodeInsufficientScope, "token lacks scope "+scope).
+			WithDetails(map[string]any{"scope": scope}))
+		return 0, false
+	}
+	return p.UserID, true
+}
@@ -1,221 +1,182 @@
 package handlers
 
 import (
-	"bookpulse/internal/db"
-	"bookpulse/internal/models"
+	"bookpulse/internal/request"
+	"bookpulse/internal/respond"
+	"bookpulse/internal/router"
 	"bookpulse/internal/service/auth"
-	"bookpulse/internal/utils"
-	"encoding/json"
+	"bookpulse/internal/service/library"
+	"fmt"
 	"net/http"
 )
 
 type CreateCollectionRequest struct {
-	Name    string `json:"name"`
-	BookIDs []int  `json:"bookIds"`
+	Name    string `json:"name" validate:"required,max=100"`
+	BookIDs []int  `json:"bookIds" validate:"required,max=500"`
 }
 
-func GetAndAddCollection(jwt *auth.JWT) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-
-		if r.Method == http.MethodOptions {
-			w.WriteHeader(http.StatusNoContent)
-			return
+func (b *CreateCollectionRequest) Validate(errs *request.Errors) {
+	for i, id := range b.BookIDs {
+		if id <= 0 {
+			errs.Add(fmt.Sprintf("bookIds[%d]", i), request.CodeInvalid, "book ids are positive integers")
 		}
+	}
+}
 
-		userID, ok := auth.MustAuth(r, jwt)
+// ListCollections serves GET /api/me/collections with the book count of
+// each collection.
+func ListCollections(lib *library.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, ok := authorize(w, r, auth.ScopeLibraryRead)
 		if !ok {
-			http.Error(w, "unauthorized", http.StatusUnauthorized)
 			return
 		}
 
-		switch r.Method {
-		case http.MethodGet:
-			rows, err := db.DBpool.Query(r.Context(), `
-			SELECT c.id, c.name, COUNT(cb.book_id) AS cnt
-			FROM collections c
-			LEFT JOIN collection_books cb
-			  ON cb.user_id = c.user_id AND cb.collection_id = c.id
-			WHERE c.user_id = $1
-			GROUP BY c.id, c.name
-			ORDER BY c.name;
-		`, userID)
-			if err != nil {
-				http.Error(w, "DB query error: "+err.Error(), http.StatusInternalServerError)
-				return
-			}
-			defer rows.Close()
-
-			out := make([]models.MyCollectionDTO, 0, 16)
-			for rows.Next() {
-				var dto models.MyCollectionDTO
-				if err := rows.Scan(&dto.ID, &dto.Name, &dto.Count); err != nil {
-					http.Error(w, "DB scan error: "+err.Error(), http.StatusInternalServerError)
-					return
-				}
-				out = append(out, dto)
-			}
-			if err := rows.Err(); err != nil {
-				http.Error(w, "DB rows error: "+err.Error(), http.StatusInternalServerError)
-				return
-			}
-
-			w.Header().Set("Content-Type", "application/json; charset=utf-8")
-			utils.WriteJSON(w, out)
+		list, err := lib.Collections(r.Context(), userID)
+		if err != nil {
+			respond.WriteError(w, r, err)
 			return
+		}
+		respond.JSON(w, http.StatusOK, list)
+	}
+}
 
-		case http.MethodPost:
-			var body CreateCollectionRequest
-			if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
-				http.Error(w, "bad json", http.StatusBadRequest)
-				return
-			}
-
-			name := body.Name
-			if name == "" {
-				http.Error(w, "name required", http.StatusBadRequest)
-				return
-			}
-			if len(body.BookIDs) == 0 {
-				http.Error(w, "bookIds required", http.StatusBadRequest)
-				return
-			}
-
-			var collectionID int
-			err := db.DBpool.QueryRow(r.Context(), `
-		INSERT INTO collections (user_id, name)
-		VALUES ($1,$2)
-		ON CONFLICT (user_id, name) DO UPDATE SET name = EXCLUDED.name
-		RETURNING id;
-	`, userID, name).Scan(&collectionID)
-			if err != nil {
-				http.Error(w, "DB insert collection error: "+err.Error(), http.StatusInternalServerError)
-				return
-			}
-
-			for _, bookID := range body.BookIDs {
-				if bookID == 0 {
-					continue
-				}
-
-				var inLib bool
-				if err := db.DBpool.QueryRow(r.Context(), `
-			SELECT EXISTS(SELECT 1 FROM user_books WHERE user_id=$1 AND book_id=$2)
-		`, userID, bookID).Scan(&inLib); err != nil {
-					http.Error(w, "DB error: "+err.Error(), http.StatusInternalServerError)
-					return
-				}
-				if !inLib {
-					http.Error(w, "book not in user's library", http.StatusBadRequest)
-					return
-				}
-
-				_, err := db.DBpool.Exec(r.Context(), `
-			INSERT INTO collection_books (user_id, collection_id, book_id)
-			VALUES ($1,$2,$3)
-			ON CONFLICT DO NOTHING
-		`, userID, collectionID, bookID)
-				if err != nil {
-					http.Error(w, "DB insert collection_books error: "+err.Error(), http.StatusInternalServerError)
-					return
-				}
-			}
+// CreateCollection serves POST /api/me/collections. Posting an existing name
+// adds the books to that collection.
+func CreateCollection(lib *library.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, ok := authorize(w, r, auth.ScopeLibraryWrite)
+		if !ok {
+			return
+		}
 
-			w.Header().Set("Content-Type", "application/json; charset=utf-8")
-			utils.WriteJSON(w, map[string]any{
-				"ok":           true,
-				"collectionId": collectionID,
-			})
+		var body CreateCollectionRequest
+		if err := request.Decode(r, &body); err != nil {
+			respond.WriteError(w, r, err)
 			return
+		}
 
-		default:
-			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		collectionID, err := lib.CreateCollection(r.Context(), userID, body.Name, body.BookIDs)
+		if err != nil {
+			respond.WriteError(w, r, libraryError(err))
 			return
 		}
+
+		respond.JSON(w, http.StatusOK, map[string]any{
+			"ok":           true,
+			"collectionId": collectionID,
+		})
 	}
 }
 
 type AddBooksToCollectionRequest struct {
 	CollectionID int      `json:"collectionId"`
-	GoogleIDs    []string `json:"googleIds"`
+	GoogleIDs    []string `json:"googleIds" validate:"required,max=500"`
 }
 
-func AddBookToCollection(jwt *auth.JWT) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-
-		if r.Method == http.MethodOptions {
-			w.WriteHeader(http.StatusNoContent)
-			return
+func (b *AddBooksToCollectionRequest) Validate(errs *request.Errors) {
+	if b.CollectionID <= 0 {
+		errs.Add("collectionId", request.CodeRequired, "collectionId is required")
+	}
+	for i, gid := range b.GoogleIDs {
+		if gid == "" {
+			errs.Add(fmt.Sprintf("googleIds[%d]", i), request.CodeRequired, "google ids must not be empty")
 		}
+	}
+}
 
-		userID, ok := auth.MustAuth(r, jwt)
+// AddBookToCollection serves POST /api/me/collections/{id}/books. The older
+// POST /api/me/collections/add-books takes collectionId in the body instead.
+func AddBookToCollection(lib *library.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, ok := authorize(w, r, auth.ScopeLibraryWrite)
 		if !ok {
-			http.Error(w, "unauthorized", http.StatusUnauthorized)
-			return
-		}
-
-		if r.Method != http.MethodPost {
-			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
 			return
 		}
 
+		// on the new route the path names the collection
 		var body AddBooksToCollectionRequest
-		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
-			http.Error(w, "bad json", http.StatusBadRequest)
-			return
+		if r.PathValue("id") != "" {
+			id, err := router.IntParam(r, "id")
+			if err != nil {
+				respond.WriteError(w, r, errCollectionNotFound)
+				return
+			}
+			body.CollectionID = id
 		}
-		if body.CollectionID == 0 || len(body.GoogleIDs) == 0 {
-			http.Error(w, "collectionId and googleIds required", http.StatusBadRequest)
+		pathID := body.CollectionID
+		if err := request.Decode(r, &body); err != nil {
+			respond.WriteError(w, r, err)
 			return
 		}
+		if pathID != 0 {
+			body.CollectionID = pathID
+		}
 
-		var exists bool
-		if err := db.DBpool.QueryRow(r.Context(), `
-		SELECT EXISTS(SELECT 1 FROM collections WHERE id=$1 AND user_id=$2)
-	`, body.CollectionID, userID).Scan(&exists); err != nil {
-			http.Error(w, "DB error: "+err.Error(), http.StatusInternalServerError)
+		if err := lib.AddToCollection(r.Context(), userID, body.CollectionID, body.GoogleIDs); err != nil {
+			respond.WriteError(w, r, libraryError(err))
 			return
 		}
-		if !exists {
-			http.Error(w, "collection not found", http.StatusBadRequest)
-			return
+
+		respond.JSON(w, http.StatusOK, map[string]any{"ok": true})
+	}
+}
+
+type RenameCollectionRequest struct {
+	Name string `json:"name" validate:"required,max=100"`
+}
+
+// RenameCollection serves PATCH /api/me/collections/{id}. Taking the name of
+// another of the caller's collections is a conflict.
+func RenameCollection(lib *library.Service) http.HandlerFunc {
+	return collectionAction(func(r *http.Request, userID, collectionID int) error {
+		var body RenameCollectionRequest
+		if err := request.Decode(r, &body); err != nil {
+			return err
 		}
+		return libraryError(lib.RenameCollection(r.Context(), userID, collectionID, body.Name))
+	})
+}
 
-		for _, gid := range body.GoogleIDs {
-			if gid == "" {
-				continue
-			}
+// DeleteCollection serves DELETE /api/me/collections/{id}. The books in the
+// collection stay on the caller's shelf.
+func DeleteCollection(lib *library.Service) http.HandlerFunc {
+	return collectionAction(func(r *http.Request, userID, collectionID int) error {
+		return libraryError(lib.DeleteCollection(r.Context(), userID, collectionID))
+	})
+}
 
-			var bookID int
-			err := db.DBpool.QueryRow(r.Context(), `SELECT id FROM books WHERE google_id=$1`, gid).Scan(&bookID)
-			if err != nil {
-				http.Error(w, "book not found: "+gid, http.StatusBadRequest)
-				return
-			}
+// RemoveBookFromCollection serves
+// DELETE /api/me/collections/{id}/books/{googleId}, naming the book like
+// AddBookToCollection does.
+func RemoveBookFromCollection(lib *library.Service) http.HandlerFunc {
+	return collectionAction(func(r *http.Request, userID, collectionID int) error {
+		book := library.BookRef{GoogleID: r.PathValue("googleId")}
+		return libraryError(lib.RemoveFromCollection(r.Context(), userID, collectionID, book))
+	})
+}
 
-			var inLib bool
-			if err := db.DBpool.QueryRow(r.Context(), `
-			SELECT EXISTS(SELECT 1 FROM user_books WHERE user_id=$1 AND book_id=$2)
-		`, userID, bookID).Scan(&inLib); err != nil {
-				http.Error(w, "DB error: "+err.Error(), http.StatusInternalServerError)
-				return
-			}
-			if !inLib {
-				http.Error(w, "book not in user's library", http.StatusBadRequest)
-				return
-			}
+// collectionAction checks the library:write scope, resolves the {id}
+// collection of the route and runs action, answering {"ok": true} if it
+// succeeds.
+func collectionAction(action func(r *http.Request, userID, collectionID int) error) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, ok := authorize(w, r, auth.ScopeLibraryWrite)
+		if !ok {
+			return
+		}
 
-			_, err = db.DBpool.Exec(r.Context(), `
-			INSERT INTO collection_books (user_id, collection_id, book_id)
-			VALUES ($1,$2,$3)
-			ON CONFLICT DO NOTHING
-		`, userID, body.CollectionID, bookID)
-			if err != nil {
-				http.Error(w, "DB insert error: "+err.Error(), http.StatusInternalServerError)
-				return
-			}
+		collectionID, err := router.IntParam(r, "id")
+		if err != nil {
+			respond.WriteError(w, r, errCollectionNotFound)
+			return
+		}
+
+		if err := action(r, userID, collectionID); err != nil {
+			respond.WriteError(w, r, err)
+			return
 		}
 
-		w.Header().Set("Content-Type", "application/json; charset=utf-8")
-		utils.WriteJSON(w, map[string]any{"ok": true})
+		respond.JSON(w, http.StatusOK, map[string]any{"ok": true})
 	}
 }
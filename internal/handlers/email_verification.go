@@ -0,0 +1,43 @@
+package handlers
+
+import (
+	"bookpulse/internal/request"
+	"bookpulse/internal/respond"
+	"bookpulse/internal/service/auth"
+	"net/http"
+)
+
+type VerifyEmailRequest struct {
+	Token string `json:"token"`
+}
+
+func VerifyEmail(authSvc *auth.ServicePGX) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var body VerifyEmailRequest
+		if err := request.Decode(r, &body); err != nil {
+			respond.WriteError(w, r, err)
+			return
+		}
+
+		me, err := authSvc.VerifyEmail(r.Context(), body.Token, auth.ClientFromRequest(r))
+		if err != nil {
+			respond.WriteError(w, r, authError(err))
+			return
+		}
+
+		respond.JSON(w, http.StatusOK, map[string]any{"ok": true, "user": me})
+	}
+}
+
+func ResendVerification(authSvc *auth.ServicePGX) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID := caller(r).UserID
+
+		if err := authSvc.ResendVerification(r.Context(), userID); err != nil {
+			respond.WriteError(w, r, authError(err))
+			return
+		}
+
+		respond.JSON(w, http.StatusOK, map[string]any{"ok": true})
+	}
+}
@@ -0,0 +1,186 @@
+package handlers
+
+import (
+	"bookpulse/internal/repo"
+	"bookpulse/internal/request"
+	"bookpulse/internal/respond"
+	"bookpulse/internal/service/auth"
+	"bookpulse/internal/service/library"
+	"errors"
+	"net/http"
+)
+
+// Error codes of the API. Clients switch on these, so never rename one;
+// add a new code instead.
+const (
+	// library
+	CodeBookNotFound        = "book_not_found"
+	CodeBookNotInLibrary    = "book_not_in_library"
+	CodeCollectionNotFound  = "collection_not_found"
+	CodeCollectionNameTaken = "collection_name_taken"
+	CodeBookNotInCollection = "book_not_in_collection"
+	CodeReviewNotFound      = "review_not_found"
+
+	// accounts and sign-in
+	CodeEmailTaken          = "email_taken"
+	CodeInvalidCredentials  = "invalid_credentials"
+	CodeInvalidPassword     = "invalid_password"
+	CodeNoPassword          = "no_password"
+	CodeAccountSuspended    = "account_suspended"
+	CodeTooManyAttempts     = "too_many_attempts"
+	CodeInvalidRefreshToken = "invalid_refresh_token"
+	CodeUserNotFound        = "user_not_found"
+	CodeEmailNotVerified    = "email_verification_required"
+
+	CodeVerificationTokenInvalid = "verification_token_invalid"
+	CodeAlreadyVerified          = "already_verified"
+	CodeResendThrottled          = "resend_throttled"
+	CodeResetTokenInvalid        = "reset_token_invalid"
+
+	CodeInvalidChallenge    = "invalid_challenge"
+	CodeInvalidTwoFactor    = "invalid_2fa_code"
+	CodeTwoFactorEnabled    = "two_factor_enabled"
+	CodeTwoFactorNotEnabled = "two_factor_not_enabled"
+	CodeTwoFactorNotPending = "two_factor_not_pending"
+
+	CodeSessionNotFound   = "session_not_found"
+	CodeTokenNotFound     = "token_not_found"
+	CodeTooManyTokens     = "too_many_tokens"
+	CodeInsufficientScope = "insufficient_scope"
+
+	CodeDeletionNotPending = "deletion_not_pending"
+	CodeSelfAction         = "self_action"
+	CodeTargetIsAdmin      = "target_is_admin"
+
+	// social sign-in
+	CodeUnknownProvider         = "unknown_provider"
+	CodeIdentityNotFound        = "identity_not_found"
+	CodeIdentityTaken           = "identity_taken"
+	CodeOIDCStateInvalid        = "oidc_state_invalid"
+	CodeProviderEmailUnverified = "provider_email_not_verified"
+	CodeLastLoginMethod         = "last_login_method"
+	CodeProviderError           = "provider_error"
+
+	// field error codes, next to the generic ones in package request
+	CodeWeakPassword = "weak_password"
+)
+
+// authError translates the errors of the auth service and its repos into
+// the error envelope. Anything it does not know is returned unchanged and
+// ends up as internal_error.
+func authError(err error) error {
+	var locked *auth.TooManyAttemptsError
+	var throttled *auth.ResendThrottledError
+	var policy *auth.PasswordPolicyError
+
+	switch {
+	case errors.As(err, &locked):
+		return respond.TooManyRequests(CodeTooManyAttempts, "too many failed login attempts", locked.RetryAfter)
+	case errors.As(err, &throttled):
+		return respond.TooManyRequests(CodeResendThrottled, throttled.Error(), throttled.RetryAfter)
+	case errors.As(err, &policy):
+		return respond.Field("password", CodeWeakPassword, policy.Reason)
+
+	case errors.Is(err, auth.ErrInvalidEmail):
+		return respond.Field("email", request.CodeInvalid, err.Error())
+	case errors.Is(err, auth.ErrEmailTaken):
+		return respond.Conflict(CodeEmailTaken, err.Error())
+	case errors.Is(err, auth.ErrAccountSuspended):
+		return respond.Forbidden(CodeAccountSuspended, err.Error())
+	case errors.Is(err, repo.ErrInvalidCredentials):
+		// outside login this is always a re-entered password
+		return respond.Forbidden(CodeInvalidPassword, "password is wrong")
+	case errors.Is(err, auth.ErrNoPassword):
+		return respond.Conflict(CodeNoPassword, err.Error())
+	case errors.Is(err, auth.ErrInvalidRefreshToken):
+		return respond.NewError(http.StatusUnauthorized, CodeInvalidRefreshToken, err.Error())
+	case errors.Is(err, auth.ErrUserNotFound):
+		return respond.NotFound(CodeUserNotFound, err.Error())
+
+	case errors.Is(err, auth.ErrInvalidVerificationToken):
+		return respond.BadRequest(CodeVerificationTokenInvalid, err.Error())
+	case errors.Is(err, auth.ErrAlreadyVerified):
+		return respond.Conflict(CodeAlreadyVerified, err.Error())
+	case errors.Is(err, repo.ErrResetTokenInvalid):
+		return respond.BadRequest(CodeResetTokenInvalid, "reset link is invalid or expired")
+
+	case errors.Is(err, auth.ErrInvalidChallenge):
+		return respond.NewError(http.StatusUnauthorized, CodeInvalidChallenge, err.Error())
+	case errors.Is(err, auth.ErrInvalidTwoFactorCode):
+		return respond.BadRequest(CodeInvalidTwoFactor, err.Error())
+	case errors.Is(err, auth.ErrTwoFactorEnabled):
+		return respond.Conflict(CodeTwoFactorEnabled, err.Error())
+	case errors.Is(err, auth.ErrTwoFactorNotEnabled):
+		return respond.Conflict(CodeTwoFactorNotEnabled, err.Error())
+	case errors.Is(err, auth.ErrTwoFactorNotPending):
+		return respond.BadRequest(CodeTwoFactorNotPending, err.Error())
+
+	case errors.Is(err, repo.ErrSessionNotFound):
+		return respond.NotFound(CodeSessionNotFound, "session not found")
+	case errors.Is(err, repo.ErrAccessTokenNotFound):
+		return respond.NotFound(CodeTokenNotFound, "token not found")
+	case errors.Is(err, auth.ErrInvalidTokenName):
+		return respond.Field("name", request.CodeInvalid, err.Error())
+	case errors.Is(err, auth.ErrInvalidScopes):
+		return respond.Field("scopes", request.CodeInvalid, err.Error())
+	case errors.Is(err, auth.ErrInvalidTokenExpiry):
+		return respond.Field("expiresInDays", request.CodeOutOfRange, err.Error())
+	case errors.Is(err, auth.ErrTooManyTokens):
+		return respond.Conflict(CodeTooManyTokens, err.Error())
+
+	case errors.Is(err, auth.ErrConfirmationMismatch):
+		return respond.Field("confirmEmail", request.CodeInvalid, err.Error())
+	case errors.Is(err, auth.ErrDeletionNotPending):
+		return respond.Conflict(CodeDeletionNotPending, err.Error())
+	case errors.Is(err, auth.ErrInvalidRole):
+		return respond.Field("role", request.CodeInvalid, err.Error())
+	case errors.Is(err, auth.ErrSelfAction):
+		return respond.Conflict(CodeSelfAction, err.Error())
+	case errors.Is(err, auth.ErrTargetIsAdmin):
+		return respond.Conflict(CodeTargetIsAdmin, err.Error())
+
+	case errors.Is(err, auth.ErrUnknownProvider):
+		return respond.NotFound(CodeUnknownProvider, err.Error())
+	case errors.Is(err, repo.ErrIdentityNotFound):
+		return respond.NotFound(CodeIdentityNotFound, err.Error())
+	case errors.Is(err, repo.ErrIdentityTaken):
+		return respond.Conflict(CodeIdentityTaken, err.Error())
+	case errors.Is(err, repo.ErrOIDCStateInvalid):
+		return respond.BadRequest(CodeOIDCStateInvalid, err.Error())
+	case errors.Is(err, auth.ErrEmailNotVerified):
+		return respond.Conflict(CodeProviderEmailUnverified, err.Error())
+	case errors.Is(err, auth.ErrLastLoginMethod):
+		return respond.Conflict(CodeLastLoginMethod, err.Error())
+	}
+	return err
+}
+
+// libraryError is authError for the library service.
+func libraryError(err error) error {
+	var book *library.BookError
+	switch {
+	case errors.As(err, &book):
+		details := map[string]any{"googleId": book.GoogleID}
+		if book.GoogleID == "" {
+			details = map[string]any{"bookId": book.BookID}
+		}
+		if errors.Is(err, repo.ErrNotInLibrary) {
+			return respond.BadRequest(CodeBookNotInLibrary, "book is not in your library").WithDetails(details)
+		}
+		if errors.Is(err, repo.ErrNotInCollection) {
+			return respond.NotFound(CodeBookNotInCollection, "book is not in this collection").WithDetails(details)
+		}
+		return respond.NotFound(CodeBookNotFound, "book not found").WithDetails(details)
+	case errors.Is(err, repo.ErrCollectionNotFound):
+		return errCollectionNotFound
+	case errors.Is(err, repo.ErrCollectionNameTaken):
+		return respond.Conflict(CodeCollectionNameTaken, err.Error())
+	case errors.Is(err, repo.ErrReviewNotFound):
+		return respond.NotFound(CodeReviewNotFound, "review not found")
+	case errors.Is(err, library.ErrEmailNotVerified):
+		return respond.Forbidden(CodeEmailNotVerified, err.Error())
+	}
+	return err
+}
+
+var errCollectionNotFound = respond.NotFound(CodeCollectionNotFound, "collection not found")
@@ -0,0 +1,28 @@
+package handlers
+
+import (
+	"bookpulse/internal/oidc"
+	"bookpulse/internal/respond"
+	"bookpulse/internal/service/auth"
+	"net/http"
+)
+
+// JWKS serves /.well-known/jwks.json, the public keys other services verify
+// BookPulse tokens with. It is empty while tokens are signed with HS256.
+// Verifiers must also check iss and that aud holds auth.AccessAudience.
+func JWKS(jwt *auth.JWT) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		set := oidc.JWKS{Keys: []oidc.JWK{}}
+		if jwt.Keys != nil {
+			var err error
+			set, err = jwt.Keys.JWKS()
+			if err != nil {
+				respond.WriteError(w, r, respond.Internal(err))
+				return
+			}
+		}
+
+		w.Header().Set("Cache-Control", "public, max-age=300")
+		respond.JSON(w, http.StatusOK, set)
+	}
+}
@@ -0,0 +1,32 @@
+package handlers
+
+import (
+	"bookpulse/internal/repo"
+	"bookpulse/internal/respond"
+	"bookpulse/internal/router"
+	"bookpulse/internal/service/auth"
+	"bookpulse/internal/service/library"
+	"net/http"
+)
+
+// DeleteReview serves DELETE /api/moderation/reviews/{id}. It is
+// mounted behind middleware.RequireRole(auth.RoleModerator).
+func DeleteReview(reviews *library.Reviews) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		client := auth.ClientFromRequest(r)
+		mod := library.Moderator{UserID: caller(r).UserID, IP: client.IP, UserAgent: client.UserAgent}
+
+		reviewID, err := router.IntParam(r, "id")
+		if err != nil {
+			respond.WriteError(w, r, libraryError(repo.ErrReviewNotFound))
+			return
+		}
+
+		if err := reviews.Delete(r.Context(), reviewID, mod); err != nil {
+			respond.WriteError(w, r, libraryError(err))
+			return
+		}
+
+		respond.JSON(w, http.StatusOK, map[string]any{"ok": true})
+	}
+}
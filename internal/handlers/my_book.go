@@ -1,275 +1,164 @@
 package handlers
 
 import (
-	"bookpulse/internal/db"
-	"bookpulse/internal/models"
+	"bookpulse/internal/request"
+	"bookpulse/internal/respond"
 	"bookpulse/internal/service/auth"
-	"bookpulse/internal/utils"
-	"encoding/json"
+	"bookpulse/internal/service/library"
 	"net/http"
-	"strings"
+	"strconv"
 )
 
 type AddMyBookRequest struct {
-	GoogleID string `json:"googleId"`
-	ID       string `json:"id"`
-
-	Title       string `json:"title"`
-	Author      string `json:"author"`
-	CoverURL    string `json:"coverUrl"`
-	Description string `json:"description"`
-
-	Categories    []string `json:"categories"`
-	PublishedYear int      `json:"publishedYear"`
-	PageCount     int      `json:"pageCount"`
+	GoogleID string `json:"googleId" validate:"max=64"`
+	// ID is the googleId under the name the Google Books search results use.
+	ID string `json:"id" validate:"max=64"`
+
+	Title       string `json:"title" validate:"required,max=500"`
+	Author      string `json:"author" validate:"max=500"`
+	CoverURL    string `json:"coverUrl" validate:"max=2048"`
+	Description string `json:"description" validate:"max=20000"`
+
+	Categories    []string `json:"categories" validate:"max=50"`
+	PublishedYear int      `json:"publishedYear" validate:"min=0,max=9999"`
+	PageCount     int      `json:"pageCount" validate:"min=0,max=100000"`
 	Maturity      string   `json:"maturity"`
-	Status        string   `json:"status"`
+	Status        string   `json:"status" validate:"oneof=planned reading finished dropped"`
 }
 
+func (b *AddMyBookRequest) Validate(errs *request.Errors) {
+	if b.GoogleID == "" && b.ID == "" {
+		errs.Add("googleId", request.CodeRequired, "googleId is required")
+	}
+}
 
-func GetAndAddMyBook(jwt *auth.JWT) http.HandlerFunc {
+// ListMyBooks serves GET /api/me/books.
+func ListMyBooks(lib *library.Service) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-
-		if r.Method == http.MethodOptions {
-			w.WriteHeader(http.StatusNoContent)
+		userID, ok := authorize(w, r, auth.ScopeLibraryRead)
+		if !ok {
 			return
 		}
 
-		userID, ok := auth.MustAuth(r, jwt)
-		if !ok {
-			http.Error(w, "unauthorized", http.StatusUnauthorized)
+		books, err := lib.Books(r.Context(), userID)
+		if err != nil {
+			respond.WriteError(w, r, err)
 			return
 		}
+		respond.JSON(w, http.StatusOK, books)
+	}
+}
 
-		switch r.Method {
-
-		case http.MethodGet:
-			w.Header().Set("Content-Type", "application/json; charset=utf-8")
-
-			rows, err := db.DBpool.Query(r.Context(), `
-			SELECT
-			b.id,
-			b.google_id,
-			b.title,
-			b.author,
-			b.cover_url,
-			ub.status,
-			COALESCE(string_agg(c.name, ',' ORDER BY c.name), '') AS collections_csv
-			FROM user_books ub
-			JOIN books b ON b.id = ub.book_id
-			LEFT JOIN collection_books cb
-			  ON cb.user_id = ub.user_id AND cb.book_id = ub.book_id
-			LEFT JOIN collections c
-			  ON c.id = cb.collection_id AND c.user_id = cb.user_id
-			WHERE ub.user_id = $1
-			GROUP BY b.id, b.title, b.author, ub.status
-			ORDER BY b.title;
-		`, userID)
-			if err != nil {
-				http.Error(w, "DB query error: "+err.Error(), http.StatusInternalServerError)
-				return
-			}
-			defer rows.Close()
-
-			result := make([]models.MyBookDTO, 0, 16)
-			for rows.Next() {
-				var dto models.MyBookDTO
-				var collectionsCSV string
-
-				if err := rows.Scan(&dto.BookID, &dto.GoogleID, &dto.Title, &dto.Author, &dto.CoverURL, &dto.Status, &collectionsCSV); err != nil {
-					http.Error(w, "DB scan error: "+err.Error(), http.StatusInternalServerError)
-					return
-				}
-				dto.Collections = utils.SplitCSV(collectionsCSV)
-				result = append(result, dto)
-			}
-			if err := rows.Err(); err != nil {
-				http.Error(w, "DB rows error: "+err.Error(), http.StatusInternalServerError)
-				return
-			}
-
-			utils.WriteJSON(w, result)
+// AddMyBook serves POST /api/me/books, saving the book and putting it on the
+// caller's shelf.
+func AddMyBook(lib *library.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, ok := authorize(w, r, auth.ScopeLibraryWrite)
+		if !ok {
 			return
+		}
 
-		case http.MethodPost:
-			var body AddMyBookRequest
-
-			if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
-				http.Error(w, "bad json", http.StatusBadRequest)
-				return
-			}
-			if body.GoogleID == "" {
-				body.GoogleID = body.ID
-			}
-
-			if body.GoogleID == "" || body.Title == "" {
-				http.Error(w, "id and title required", http.StatusBadRequest)
-				return
-			}
-			if body.Status == "" {
-				body.Status = "planned"
-			}
-
-			var bookID int
-			err := db.DBpool.QueryRow(r.Context(), `
-			INSERT INTO books (google_id, title, author, cover_url, description, published_year, page_count, age_rating)
-			VALUES ($1,$2,$3,$4,$5,$6,$7,$8)
-			ON CONFLICT (google_id) DO UPDATE SET
-			  title = EXCLUDED.title,
-			  author = EXCLUDED.author,
-			  cover_url = EXCLUDED.cover_url,
-			  description = EXCLUDED.description,
-			  published_year = EXCLUDED.published_year,
-			  page_count = EXCLUDED.page_count,
-			  age_rating = EXCLUDED.age_rating
-			RETURNING id;
-		`,
-				body.GoogleID,
-				body.Title,
-				body.Author,
-				body.CoverURL,
-				body.Description,
-				utils.NullIfZero(body.PublishedYear),
-				utils.NullIfZero(body.PageCount),
-				utils.MaturityToAge(body.Maturity),
-			).Scan(&bookID)
-			if err != nil {
-				http.Error(w, "DB insert book error: "+err.Error(), http.StatusInternalServerError)
-				return
-			}
-
-			for _, raw := range body.Categories {
-				parts := strings.Split(raw, "/")
-
-				for i := 0; i < len(parts) && i < 2; i++ {
-					g := strings.TrimSpace(parts[i])
-					if g == "" || g == "General" {
-						continue
-					}
-
-					var genreID int
-					err := db.DBpool.QueryRow(r.Context(), `
-      INSERT INTO genres (name)
-      VALUES ($1)
-      ON CONFLICT (name) DO UPDATE SET name = EXCLUDED.name
-      RETURNING id;
-    `, g).Scan(&genreID)
-					if err != nil {
-						http.Error(w, "DB insert genre error: "+err.Error(), http.StatusInternalServerError)
-						return
-					}
-
-					_, err = db.DBpool.Exec(r.Context(), `
-      INSERT INTO book_genres (book_id, genre_id)
-      VALUES ($1,$2)
-      ON CONFLICT DO NOTHING;
-    `, bookID, genreID)
-					if err != nil {
-						http.Error(w, "DB insert book_genres error: "+err.Error(), http.StatusInternalServerError)
-						return
-					}
-				}
-			}
-
-			_, err = db.DBpool.Exec(r.Context(), `
-  INSERT INTO user_books (user_id, book_id, status)
-  VALUES ($1,$2,$3)
-  ON CONFLICT (user_id, book_id) DO UPDATE SET status = EXCLUDED.status;
-`, userID, bookID, body.Status)
-
-			_, err = db.DBpool.Exec(r.Context(), `
-			INSERT INTO user_books (user_id, book_id, status)
-			VALUES ($1,$2,$3)
-			ON CONFLICT (user_id, book_id) DO UPDATE SET status = EXCLUDED.status;
-		`, userID, bookID, body.Status)
-			if err != nil {
-				http.Error(w, "DB insert user_books error: "+err.Error(), http.StatusInternalServerError)
-				return
-			}
-
-			w.Header().Set("Content-Type", "application/json; charset=utf-8")
-			utils.WriteJSON(w, map[string]any{
-				"ok":       true,
-				"bookId":   bookID,
-				"googleId": body.GoogleID,
-			})
+		var body AddMyBookRequest
+		if err := request.Decode(r, &body); err != nil {
+			respond.WriteError(w, r, err)
 			return
+		}
+		if body.GoogleID == "" {
+			body.GoogleID = body.ID
+		}
 
-		default:
-			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		bookID, err := lib.AddBook(r.Context(), userID, library.NewBook{
+			GoogleID:      body.GoogleID,
+			Title:         body.Title,
+			Author:        body.Author,
+			CoverURL:      body.CoverURL,
+			Description:   body.Description,
+			Categories:    body.Categories,
+			PublishedYear: body.PublishedYear,
+			PageCount:     body.PageCount,
+			Maturity:      body.Maturity,
+		}, body.Status)
+		if err != nil {
+			respond.WriteError(w, r, err)
 			return
 		}
+
+		respond.JSON(w, http.StatusOK, map[string]any{
+			"ok":       true,
+			"bookId":   bookID,
+			"googleId": body.GoogleID,
+		})
 	}
 }
 
-
 type UpdateStatusRequest struct {
-	GoogleID string `json:"googleId"`
-	BookID   int    `json:"bookId"`
-	Status   string `json:"status"`
+	GoogleID string `json:"googleId" validate:"max=64"`
+	BookID   int    `json:"bookId" validate:"min=1"`
+	Status   string `json:"status" validate:"required,oneof=planned reading finished dropped"`
 }
 
+func (b *UpdateStatusRequest) Validate(errs *request.Errors) {
+	if b.GoogleID == "" && b.BookID == 0 {
+		errs.Add("googleId", request.CodeRequired, "googleId or bookId is required")
+	}
+}
 
-func SetStatus(jwt *auth.JWT) http.HandlerFunc {
+// SetStatus serves PATCH /api/me/books/{googleId}. The older
+// PATCH /api/me/books/status takes googleId or bookId in the body instead.
+func SetStatus(lib *library.Service) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-
-		if r.Method == http.MethodOptions {
-			w.WriteHeader(http.StatusNoContent)
-			return
-		}
-
-		userID, ok := auth.MustAuth(r, jwt)
+		userID, ok := authorize(w, r, auth.ScopeLibraryWrite)
 		if !ok {
-			http.Error(w, "unauthorized", http.StatusUnauthorized)
 			return
 		}
 
-		if r.Method != http.MethodPatch {
-			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		// the path names the book on the new route; set it first so the
+		// body need not repeat it
+		googleID := r.PathValue("googleId")
+		body := UpdateStatusRequest{GoogleID: googleID}
+		if err := request.Decode(r, &body); err != nil {
+			respond.WriteError(w, r, err)
 			return
 		}
-
-		var body UpdateStatusRequest
-		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
-			http.Error(w, "bad json", http.StatusBadRequest)
-			return
+		if googleID != "" {
+			body.GoogleID, body.BookID = googleID, 0
 		}
 
-		if body.Status == "" {
-			http.Error(w, "status required", http.StatusBadRequest)
+		book := library.BookRef{ID: body.BookID, GoogleID: body.GoogleID}
+		if err := lib.SetStatus(r.Context(), userID, book, body.Status); err != nil {
+			respond.WriteError(w, r, libraryError(err))
 			return
 		}
-		if !utils.IsValidStatus(body.Status) {
-			http.Error(w, "invalid status", http.StatusBadRequest)
+
+		respond.JSON(w, http.StatusOK, map[string]any{"ok": true})
+	}
+}
+
+// RemoveMyBook serves DELETE /api/me/books/{googleId}?keepReview=, taking
+// the book off the caller's shelf and out of their collections. The
+// caller's review of the book is deleted too unless keepReview is true.
+func RemoveMyBook(lib *library.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, ok := authorize(w, r, auth.ScopeLibraryWrite)
+		if !ok {
 			return
 		}
 
-		bookID := body.BookID
-		if bookID == 0 {
-			if body.GoogleID == "" {
-				http.Error(w, "googleId or bookId required", http.StatusBadRequest)
-				return
-			}
-			if err := db.DBpool.QueryRow(r.Context(), `SELECT id FROM books WHERE google_id=$1`, body.GoogleID).Scan(&bookID); err != nil {
-				http.Error(w, "book not found", http.StatusBadRequest)
+		keepReview := false
+		if v := r.URL.Query().Get("keepReview"); v != "" {
+			var err error
+			if keepReview, err = strconv.ParseBool(v); err != nil {
+				respond.WriteError(w, r, respond.Field("keepReview", request.CodeInvalid, "keepReview must be true or false"))
 				return
 			}
 		}
 
-		cmd, err := db.DBpool.Exec(r.Context(), `
-		UPDATE user_books SET status=$3
-		WHERE user_id=$1 AND book_id=$2
-	`, userID, bookID, body.Status)
-		if err != nil {
-			http.Error(w, "DB update error: "+err.Error(), http.StatusInternalServerError)
-			return
-		}
-		if cmd.RowsAffected() == 0 {
-			http.Error(w, "book not in user's library", http.StatusBadRequest)
+		book := library.BookRef{GoogleID: r.PathValue("googleId")}
+		if err := lib.RemoveBook(r.Context(), userID, book, keepReview); err != nil {
+			respond.WriteError(w, r, libraryError(err))
 			return
 		}
 
-		w.Header().Set("Content-Type", "application/json; charset=utf-8")
-		utils.WriteJSON(w, map[string]any{"ok": true})
+		respond.JSON(w, http.StatusOK, map[string]any{"ok": true})
 	}
-}
\ No newline at end of file
+}
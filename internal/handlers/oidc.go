@@ -0,0 +1,123 @@
+package handlers
+
+import (
+	"bookpulse/internal/oidc"
+	"bookpulse/internal/request"
+	"bookpulse/internal/respond"
+	"bookpulse/internal/service/auth"
+	"errors"
+	"net/http"
+)
+
+type OIDCCallbackRequest struct {
+	Code  string `json:"code" validate:"required,max=2048"`
+	State string `json:"state" validate:"required,max=256"`
+}
+
+// OIDCStart serves GET /api/auth/oidc/{provider}/start, returning the
+// authorization URL to send the browser to.
+func OIDCStart(social *auth.SocialLogin) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		authURL, err := social.Start(r.Context(), r.PathValue("provider"), 0)
+		if err != nil {
+			respond.WriteError(w, r, oidcError(err))
+			return
+		}
+		respond.JSON(w, http.StatusOK, map[string]any{"authorizationUrl": authURL})
+	}
+}
+
+// OIDCCallback serves POST /api/auth/oidc/{provider}/callback with the code
+// and state the provider redirected back with.
+func OIDCCallback(social *auth.SocialLogin) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		provider := r.PathValue("provider")
+
+		var body OIDCCallbackRequest
+		if err := request.Decode(r, &body); err != nil {
+			respond.WriteError(w, r, err)
+			return
+		}
+
+		res, err := social.Callback(r.Context(), provider, body.Code, body.State, auth.ClientFromRequest(r))
+		var challenge *auth.TwoFactorRequiredError
+		if errors.As(err, &challenge) {
+			respond.JSON(w, http.StatusOK, map[string]any{
+				"twoFactorRequired": true,
+				"challengeToken":    challenge.ChallengeToken,
+				"expiresIn":         challenge.ExpiresIn,
+			})
+			return
+		}
+		if err != nil {
+			respond.WriteError(w, r, oidcError(err))
+			return
+		}
+
+		if res.Linked {
+			respond.JSON(w, http.StatusOK, map[string]any{"ok": true, "linked": true, "provider": provider})
+			return
+		}
+		respond.JSON(w, http.StatusOK, res.Auth)
+	}
+}
+
+// ListIdentities serves GET /api/me/identities: linked identities plus the
+// providers that can be linked.
+func ListIdentities(social *auth.SocialLogin) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID := caller(r).UserID
+
+		list, err := social.Identities(r.Context(), userID)
+		if err != nil {
+			respond.WriteError(w, r, respond.Internal(err))
+			return
+		}
+		respond.JSON(w, http.StatusOK, map[string]any{"identities": list, "providers": social.Providers()})
+	}
+}
+
+// LinkIdentity serves POST /api/me/identities/{provider}, starting a flow
+// that links the provider account on callback.
+func LinkIdentity(social *auth.SocialLogin) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID := caller(r).UserID
+
+		authURL, err := social.Start(r.Context(), r.PathValue("provider"), userID)
+		if err != nil {
+			respond.WriteError(w, r, oidcError(err))
+			return
+		}
+		respond.JSON(w, http.StatusOK, map[string]any{"authorizationUrl": authURL})
+	}
+}
+
+// UnlinkIdentity serves DELETE /api/me/identities/{provider}.
+func UnlinkIdentity(social *auth.SocialLogin) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID := caller(r).UserID
+
+		if err := social.Unlink(r.Context(), userID, r.PathValue("provider"), auth.ClientFromRequest(r)); err != nil {
+			respond.WriteError(w, r, oidcError(err))
+			return
+		}
+		respond.JSON(w, http.StatusOK, map[string]any{"ok": true})
+	}
+}
+
+// oidcError is authError for the social sign-in flows. Unknown errors there
+// are mostly the provider failing, so they answer 502 rather than 500.
+func oidcError(err error) error {
+	switch {
+	case errors.Is(err, oidc.ErrNonceMismatch):
+		return respond.BadRequest(CodeOIDCStateInvalid, err.Error())
+	case errors.Is(err, auth.ErrInvalidEmail):
+		return respond.BadRequest(CodeProviderError, "the provider returned an unusable email address")
+	}
+	if mapped := authError(err); mapped != err {
+		return mapped
+	}
+	e := respond.NewError(http.StatusBadGateway, CodeProviderError, "sign-in with provider failed")
+	e.Err = err
+	return e
+}
@@ -0,0 +1,52 @@
+package handlers
+
+import (
+	"bookpulse/internal/request"
+	"bookpulse/internal/respond"
+	"bookpulse/internal/service/auth"
+	"log"
+	"net/http"
+)
+
+type ForgotPasswordRequest struct {
+	Email string `json:"email"`
+}
+
+func ForgotPassword(authSvc *auth.ServicePGX) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var body ForgotPasswordRequest
+		if err := request.Decode(r, &body); err != nil {
+			respond.WriteError(w, r, err)
+			return
+		}
+
+		// same answer whether or not the email is registered
+		if err := authSvc.ForgotPassword(r.Context(), body.Email); err != nil {
+			log.Printf("FORGOT PASSWORD error: %v", err)
+		}
+
+		respond.JSON(w, http.StatusOK, map[string]any{"ok": true})
+	}
+}
+
+type ResetPasswordRequest struct {
+	Token    string `json:"token"`
+	Password string `json:"password"`
+}
+
+func ResetPassword(authSvc *auth.ServicePGX) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var body ResetPasswordRequest
+		if err := request.Decode(r, &body); err != nil {
+			respond.WriteError(w, r, err)
+			return
+		}
+
+		if err := authSvc.ResetPassword(r.Context(), body.Token, body.Password, auth.ClientFromRequest(r)); err != nil {
+			respond.WriteError(w, r, authError(err))
+			return
+		}
+
+		respond.JSON(w, http.StatusOK, map[string]any{"ok": true})
+	}
+}
@@ -1,32 +1,22 @@
 package handlers
 
 import (
+	"bookpulse/internal/request"
+	"bookpulse/internal/respond"
 	"bookpulse/internal/service/auth"
-	"bookpulse/internal/utils"
-	"encoding/json"
 	"net/http"
 )
 
 func Register(authSvc *auth.ServicePGX) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		if r.Method == http.MethodOptions {
-			w.WriteHeader(http.StatusNoContent)
-			return
-		}
-
-		if r.Method != http.MethodPost {
-			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
-			return
-		}
-
 		var body struct {
 			Email    string `json:"email"`
 			Password string `json:"password"`
-			Name     string `json:"name"`
+			Name     string `json:"name" validate:"max=100"`
 		}
 
-		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
-			http.Error(w, "bad json", http.StatusBadRequest)
+		if err := request.Decode(r, &body); err != nil {
+			respond.WriteError(w, r, err)
 			return
 		}
 
@@ -39,13 +29,13 @@ func Register(authSvc *auth.ServicePGX) http.HandlerFunc {
 			body.Email,
 			body.Password,
 			body.Name,
+			auth.ClientFromRequest(r),
 		)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
+			respond.WriteError(w, r, authError(err))
 			return
 		}
 
-		w.Header().Set("Content-Type", "application/json; charset=utf-8")
-		utils.WriteJSON(w, resp)
+		respond.JSON(w, http.StatusOK, resp)
 	}
 }
@@ -0,0 +1,70 @@
+package handlers
+
+import (
+	"bookpulse/internal/models"
+	"bookpulse/internal/repo"
+	"bookpulse/internal/respond"
+	"bookpulse/internal/router"
+	"bookpulse/internal/service/auth"
+	"net/http"
+)
+
+// ListSessions serves GET /api/me/sessions, the caller's open sessions.
+func ListSessions(authSvc *auth.ServicePGX) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		p := caller(r)
+
+		list, err := authSvc.Sessions(r.Context(), p.UserID)
+		if err != nil {
+			respond.WriteError(w, r, respond.Internal(err))
+			return
+		}
+
+		out := make([]models.SessionDTO, 0, len(list))
+		for _, s := range list {
+			out = append(out, models.SessionDTO{
+				ID:         s.ID,
+				UserAgent:  s.UserAgent,
+				IP:         s.IP,
+				CreatedAt:  s.CreatedAt,
+				LastSeenAt: s.LastSeenAt,
+				Current:    s.ID == p.SessionID,
+			})
+		}
+
+		respond.JSON(w, http.StatusOK, out)
+	}
+}
+
+// RevokeOtherSessions serves DELETE /api/me/sessions, signing out every
+// session but the current one.
+func RevokeOtherSessions(authSvc *auth.ServicePGX) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		p := caller(r)
+
+		n, err := authSvc.RevokeOtherSessions(r.Context(), p.UserID, p.SessionID, auth.ClientFromRequest(r))
+		if err != nil {
+			respond.WriteError(w, r, respond.Internal(err))
+			return
+		}
+		respond.JSON(w, http.StatusOK, map[string]any{"ok": true, "revoked": n})
+	}
+}
+
+// RevokeSession serves DELETE /api/me/sessions/{id}.
+func RevokeSession(authSvc *auth.ServicePGX) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		p := caller(r)
+
+		sessionID, err := router.Int64Param(r, "id")
+		if err != nil {
+			respond.WriteError(w, r, authError(repo.ErrSessionNotFound))
+			return
+		}
+		if err := authSvc.RevokeSession(r.Context(), p.UserID, sessionID, auth.ClientFromRequest(r)); err != nil {
+			respond.WriteError(w, r, authError(err))
+			return
+		}
+		respond.JSON(w, http.StatusOK, map[string]any{"ok": true})
+	}
+}
@@ -0,0 +1,117 @@
+package handlers
+
+import (
+	"bookpulse/internal/request"
+	"bookpulse/internal/respond"
+	"bookpulse/internal/service/auth"
+	"errors"
+	"net/http"
+)
+
+type LoginTwoFactorRequest struct {
+	ChallengeToken string `json:"challengeToken"`
+	Code           string `json:"code"`
+	RecoveryCode   string `json:"recoveryCode"`
+}
+
+// LoginTwoFactor is the second login step: the challenge token returned by
+// /api/auth/login plus a TOTP or recovery code.
+func LoginTwoFactor(authSvc *auth.ServicePGX) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var body LoginTwoFactorRequest
+		if err := request.Decode(r, &body); err != nil {
+			respond.WriteError(w, r, err)
+			return
+		}
+
+		resp, err := authSvc.LoginTwoFactor(r.Context(), body.ChallengeToken, body.Code, body.RecoveryCode, auth.ClientFromRequest(r))
+		if errors.Is(err, auth.ErrInvalidTwoFactorCode) {
+			// a wrong code fails the login, unlike in setup and disable
+			respond.WriteError(w, r, respond.NewError(http.StatusUnauthorized, CodeInvalidTwoFactor, err.Error()))
+			return
+		}
+		if err != nil {
+			respond.WriteError(w, r, authError(err))
+			return
+		}
+
+		respond.JSON(w, http.StatusOK, resp)
+	}
+}
+
+type TwoFactorCodeRequest struct {
+	Code string `json:"code"`
+}
+
+type DisableTwoFactorRequest struct {
+	Password     string `json:"password"`
+	Code         string `json:"code"`
+	RecoveryCode string `json:"recoveryCode"`
+}
+
+// TwoFactorStatus serves GET /api/me/2fa.
+func TwoFactorStatus(authSvc *auth.ServicePGX) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID := caller(r).UserID
+
+		st, err := authSvc.TwoFactorStatus(r.Context(), userID)
+		if err != nil {
+			respond.WriteError(w, r, respond.Internal(err))
+			return
+		}
+		respond.JSON(w, http.StatusOK, st)
+	}
+}
+
+// SetupTwoFactor serves POST /api/me/2fa/setup, returning a new pending
+// secret and its otpauth URL.
+func SetupTwoFactor(authSvc *auth.ServicePGX) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID := caller(r).UserID
+
+		setup, err := authSvc.BeginTwoFactorSetup(r.Context(), userID)
+		if err != nil {
+			respond.WriteError(w, r, authError(err))
+			return
+		}
+		respond.JSON(w, http.StatusOK, setup)
+	}
+}
+
+// ConfirmTwoFactor serves POST /api/me/2fa/confirm, enabling 2FA once the
+// first code checks out.
+func ConfirmTwoFactor(authSvc *auth.ServicePGX) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID := caller(r).UserID
+
+		var body TwoFactorCodeRequest
+		if err := request.Decode(r, &body); err != nil {
+			respond.WriteError(w, r, err)
+			return
+		}
+		codes, err := authSvc.ConfirmTwoFactor(r.Context(), userID, body.Code, auth.ClientFromRequest(r))
+		if err != nil {
+			respond.WriteError(w, r, authError(err))
+			return
+		}
+		respond.JSON(w, http.StatusOK, map[string]any{"ok": true, "recoveryCodes": codes})
+	}
+}
+
+// DisableTwoFactor serves POST /api/me/2fa/disable.
+func DisableTwoFactor(authSvc *auth.ServicePGX) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID := caller(r).UserID
+
+		var body DisableTwoFactorRequest
+		if err := request.Decode(r, &body); err != nil {
+			respond.WriteError(w, r, err)
+			return
+		}
+		if err := authSvc.DisableTwoFactor(r.Context(), userID, body.Password, body.Code, body.RecoveryCode, auth.ClientFromRequest(r)); err != nil {
+			respond.WriteError(w, r, authError(err))
+			return
+		}
+		respond.JSON(w, http.StatusOK, map[string]any{"ok": true})
+	}
+}
@@ -1,138 +1,74 @@
 package handlers
 
 import (
-	"bookpulse/internal/db"
+	"bookpulse/internal/request"
+	"bookpulse/internal/respond"
 	"bookpulse/internal/service/auth"
-	"bookpulse/internal/utils"
-	"encoding/json"
 	"net/http"
 	"strings"
-
-	"golang.org/x/crypto/bcrypt"
 )
 
-func CurrentUser(authSvc *auth.ServicePGX, jwt *auth.JWT) http.HandlerFunc {
+func CurrentUser(authSvc *auth.ServicePGX) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		if r.Method == http.MethodOptions {
-			w.WriteHeader(http.StatusNoContent)
-			return
-		}
-		if r.Method != http.MethodGet {
-			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
-			return
-		}
-
-		userID, ok := auth.MustAuth(r, jwt)
-		if !ok {
-			http.Error(w, "unauthorized", http.StatusUnauthorized)
-			return
-		}
+		userID := caller(r).UserID
 
 		me, err := authSvc.Me(r.Context(), userID)
 		if err != nil {
-			http.Error(w, "user not found", http.StatusUnauthorized)
+			respond.WriteError(w, r, authError(err))
 			return
 		}
 
-		w.Header().Set("Content-Type", "application/json; charset=utf-8")
-		utils.WriteJSON(w, me)
+		respond.JSON(w, http.StatusOK, me)
 	}
 }
 
 type UpdateProfileRequest struct {
-	Name string `json:"name"`
+	Name string `json:"name" validate:"required,max=100"`
 }
 
-func UpdateName(jwt *auth.JWT) http.HandlerFunc {
+func UpdateName(authSvc *auth.ServicePGX) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		if r.Method == http.MethodOptions {
-			w.WriteHeader(http.StatusNoContent)
-			return
-		}
-		if r.Method != http.MethodPatch {
-			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
-			return
-		}
-
-		userID, ok := auth.MustAuth(r, jwt)
-		if !ok {
-			http.Error(w, "unauthorized", http.StatusUnauthorized)
-			return
-		}
+		userID := caller(r).UserID
 
 		var body UpdateProfileRequest
-		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
-			http.Error(w, "bad json", http.StatusBadRequest)
+		if err := request.Decode(r, &body); err != nil {
+			respond.WriteError(w, r, err)
 			return
 		}
 
 		name := strings.TrimSpace(body.Name)
-		if name == "" {
-			http.Error(w, "name is required", http.StatusBadRequest)
-			return
-		}
 
-		_, err := db.DBpool.Exec(r.Context(),
-			`UPDATE users SET name=$2 WHERE id=$1`,
-			userID, name,
-		)
-		if err != nil {
-			http.Error(w, "DB update error: "+err.Error(), http.StatusInternalServerError)
+		if err := authSvc.UpdateName(r.Context(), userID, name, auth.ClientFromRequest(r)); err != nil {
+			respond.WriteError(w, r, authError(err))
 			return
 		}
 
-		utils.WriteJSON(w, map[string]any{"ok": true, "name": name})
+		respond.JSON(w, http.StatusOK, map[string]any{"ok": true, "name": name})
 	}
 }
 
 type UpdatePasswordRequest struct {
-	Password string `json:"password"`
+	CurrentPassword string `json:"currentPassword"`
+	Password        string `json:"password"`
 }
 
-func UpdatePassword(jwt *auth.JWT) http.HandlerFunc {
+func UpdatePassword(authSvc *auth.ServicePGX) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		if r.Method == http.MethodOptions {
-			w.WriteHeader(http.StatusNoContent)
-			return
-		}
-		if r.Method != http.MethodPatch {
-			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
-			return
-		}
-
-		userID, ok := auth.MustAuth(r, jwt)
-		if !ok {
-			http.Error(w, "unauthorized", http.StatusUnauthorized)
-			return
-		}
+		userID := caller(r).UserID
 
 		var body UpdatePasswordRequest
-		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
-			http.Error(w, "bad json", http.StatusBadRequest)
-			return
-		}
-
-		pass := strings.TrimSpace(body.Password)
-		if len(pass) < 6 {
-			http.Error(w, "password must be at least 6 chars", http.StatusBadRequest)
-			return
-		}
-
-		hash, err := bcrypt.GenerateFromPassword([]byte(pass), bcrypt.DefaultCost)
-		if err != nil {
-			http.Error(w, "hash error: "+err.Error(), http.StatusInternalServerError)
+		if err := request.Decode(r, &body); err != nil {
+			respond.WriteError(w, r, err)
 			return
 		}
 
-		_, err = db.DBpool.Exec(r.Context(),
-			`UPDATE users SET password_hash=$2 WHERE id=$1`,
-			userID, string(hash),
-		)
+		// all sessions are revoked, the caller gets a fresh token pair
+		resp, err := authSvc.ChangePassword(r.Context(), userID, body.CurrentPassword, body.Password, auth.ClientFromRequest(r))
 		if err != nil {
-			http.Error(w, "DB update error: "+err.Error(), http.StatusInternalServerError)
+			respond.WriteError(w, r, authError(err))
 			return
 		}
 
-		utils.WriteJSON(w, map[string]any{"ok": true})
+		respond.JSON(w, http.StatusOK, map[string]any{"ok": true, "auth": resp})
 	}
 }
@@ -0,0 +1,71 @@
+package mail
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// LogMailer is the local development mailer: it writes every message to the
+// log, and additionally to an .eml file in Dir when Dir is set. Links are
+// redacted in the log, since reset and verification links carry live
+// tokens; only the .eml file has them in full.
+type LogMailer struct {
+	Dir  string
+	From string
+
+	seq atomic.Int64
+}
+
+func NewLogMailer(dir, from string) *LogMailer {
+	return &LogMailer{Dir: dir, From: from}
+}
+
+func (m *LogMailer) Send(ctx context.Context, msg Message) error {
+	if m.Dir == "" {
+		log.Printf("MAIL to=%s subject=%q\n%s", msg.To, msg.Subject, redactLinks(msg.Text))
+		return nil
+	}
+	if err := os.MkdirAll(m.Dir, 0o755); err != nil {
+		return err
+	}
+
+	name := fmt.Sprintf("%s-%03d-%s.eml",
+		time.Now().Format("20060102T150405"), m.seq.Add(1)%1000, sanitize(msg.To))
+	path := filepath.Join(m.Dir, name)
+	if err := os.WriteFile(path, buildMessage(m.From, msg), 0o600); err != nil {
+		return err
+	}
+	log.Printf("MAIL to=%s subject=%q file=%s\n%s", msg.To, msg.Subject, path, redactLinks(msg.Text))
+	return nil
+}
+
+var linkPattern = regexp.MustCompile(`https?://\S+`)
+
+// redactLinks cuts the query and fragment off every link in text, where the
+// tokens are.
+func redactLinks(text string) string {
+	return linkPattern.ReplaceAllStringFunc(text, func(link string) string {
+		if i := strings.IndexAny(link, "?#"); i >= 0 {
+			return link[:i] + "?[redacted]"
+		}
+		return link
+	})
+}
+
+func sanitize(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '.', r == '-':
+			return r
+		default:
+			return '_'
+		}
+	}, s)
+}
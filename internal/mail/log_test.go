@@ -0,0 +1,60 @@
+package mail
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLogMailerRedactsLinks(t *testing.T) {
+	const link = "http://localhost:5173/reset-password?token=s3cr3t"
+	var logged bytes.Buffer
+	log.SetOutput(&logged)
+	t.Cleanup(func() { log.SetOutput(os.Stderr) })
+
+	dir := t.TempDir()
+	m := NewLogMailer(dir, "BookPulse <no-reply@bookpulse.local>")
+	msg := Message{To: "ann@example.com", Subject: "reset", Text: "Open this link:\n\n" + link + "\n"}
+	if err := m.Send(context.Background(), msg); err != nil {
+		t.Fatal(err)
+	}
+
+	if strings.Contains(logged.String(), "s3cr3t") {
+		t.Errorf("log holds the token:\n%s", logged.String())
+	}
+	if !strings.Contains(logged.String(), "http://localhost:5173/reset-password?[redacted]") {
+		t.Errorf("log lost the link:\n%s", logged.String())
+	}
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.eml"))
+	if err != nil || len(files) != 1 {
+		t.Fatalf("eml files = %v, %v; want one", files, err)
+	}
+	eml, err := os.ReadFile(files[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(eml, []byte(link)) {
+		t.Errorf("eml file lost the link:\n%s", eml)
+	}
+}
+
+func TestRedactLinks(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"no links here", "no links here"},
+		{"see https://bookpulse.app/about.", "see https://bookpulse.app/about."},
+		{"https://a.example/verify-email?token=abc", "https://a.example/verify-email?[redacted]"},
+		{"x http://a.example/p#token=abc y", "x http://a.example/p?[redacted] y"},
+	}
+	for _, tt := range tests {
+		if got := redactLinks(tt.in); got != tt.want {
+			t.Errorf("redactLinks(%q) = %q; want %q", tt.in, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,14 @@
+package mail
+
+import "context"
+
+type Message struct {
+	To      string
+	Subject string
+	Text    string
+}
+
+// Mailer delivers transactional emails (password reset, verification, ...).
+type Mailer interface {
+	Send(ctx context.Context, msg Message) error
+}
@@ -0,0 +1,66 @@
+package middleware
+
+import (
+	"bookpulse/internal/respond"
+	"bookpulse/internal/service/auth"
+	"net/http"
+)
+
+// Authenticate is for optional-auth routes: a valid bearer token puts its
+// auth.Principal in the request context, anything else passes through
+// anonymously. Handlers read the caller with auth.PrincipalFrom.
+func Authenticate(jwt *auth.JWT) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, r = principal(r, jwt)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// RequireAuth answers 401 unless the request carries a valid session JWT or
+// personal access token. Scope checks are left to the handler.
+func RequireAuth(jwt *auth.JWT) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			p, r := principal(r, jwt)
+			if p == nil {
+				respond.WriteError(w, r, respond.Unauthorized())
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// RequireSession is RequireAuth for account management (password, sessions,
+// tokens, 2FA): personal access tokens are refused with 403.
+func RequireSession(jwt *auth.JWT) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			p, r := principal(r, jwt)
+			if p == nil {
+				respond.WriteError(w, r, respond.Unauthorized())
+				return
+			}
+			if p.IsAccessToken() {
+				respond.WriteError(w, r, respond.Forbidden("session_required", "sign in to do this; access tokens are not accepted here"))
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// principal returns the caller already resolved by an outer middleware, or
+// authenticates the request and returns it with the principal attached.
+func principal(r *http.Request, jwt *auth.JWT) (*auth.Principal, *http.Request) {
+	if p, ok := auth.PrincipalFrom(r.Context()); ok {
+		return p, r
+	}
+	p, ok := auth.Authenticate(r, jwt)
+	if !ok {
+		return nil, r
+	}
+	return p, r.WithContext(auth.WithPrincipal(r.Context(), p))
+}
@@ -0,0 +1,70 @@
+package middleware
+
+import (
+	"bookpulse/internal/respond"
+	"bookpulse/internal/service/auth"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// RateLimit allows each client IP at most limit requests per window (fixed
+// windows, kept in memory) and answers 429 with Retry-After beyond that.
+// A limit of 0 disables it.
+func RateLimit(limit int, window time.Duration) func(http.Handler) http.Handler {
+	if limit <= 0 {
+		return func(next http.Handler) http.Handler { return next }
+	}
+	rl := &rateLimiter{limit: limit, window: window, clients: map[string]*rateWindow{}}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ok, retryAfter := rl.allow(auth.ClientFromRequest(r).IP, time.Now())
+			if !ok {
+				respond.WriteError(w, r, respond.TooManyRequests(respond.CodeRateLimited, "too many requests", retryAfter))
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+type rateWindow struct {
+	start time.Time
+	count int
+}
+
+type rateLimiter struct {
+	limit  int
+	window time.Duration
+
+	mu        sync.Mutex
+	clients   map[string]*rateWindow
+	lastSweep time.Time
+}
+
+func (rl *rateLimiter) allow(ip string, now time.Time) (bool, time.Duration) {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	// drop finished windows now and then so the map stays small
+	if now.Sub(rl.lastSweep) > rl.window {
+		for k, cw := range rl.clients {
+			if now.Sub(cw.start) >= rl.window {
+				delete(rl.clients, k)
+			}
+		}
+		rl.lastSweep = now
+	}
+
+	cw, ok := rl.clients[ip]
+	if !ok || now.Sub(cw.start) >= rl.window {
+		rl.clients[ip] = &rateWindow{start: now, count: 1}
+		return true, 0
+	}
+	if cw.count >= rl.limit {
+		return false, cw.start.Add(rl.window).Sub(now)
+	}
+	cw.count++
+	return true, 0
+}
@@ -0,0 +1,79 @@
+package middleware
+
+import (
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+// WithRealIP replaces r.RemoteAddr with the client address reported by a
+// reverse proxy, for requests that come from one of trustedProxies (IP
+// addresses or CIDR ranges). Each proxy appends the address it got the
+// request from to X-Forwarded-For, so the header is read from the right
+// and the first address that is not a trusted proxy is the client; entries
+// left of it were sent by the client itself. Requests from anywhere else
+// keep their RemoteAddr.
+func WithRealIP(next http.Handler, trustedProxies []string) http.Handler {
+	trusted := parsePrefixes(trustedProxies)
+	isTrusted := func(a netip.Addr) bool {
+		for _, p := range trusted {
+			if p.Contains(a) {
+				return true
+			}
+		}
+		return false
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if ip, ok := clientIP(r, isTrusted); ok {
+			r.RemoteAddr = net.JoinHostPort(ip.String(), "0")
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+func clientIP(r *http.Request, trusted func(netip.Addr) bool) (netip.Addr, bool) {
+	peer, err := netip.ParseAddrPort(r.RemoteAddr)
+	if err != nil || !trusted(peer.Addr().Unmap()) {
+		return netip.Addr{}, false
+	}
+
+	var hops []string
+	for _, v := range r.Header.Values("X-Forwarded-For") {
+		hops = append(hops, strings.Split(v, ",")...)
+	}
+	if len(hops) == 0 {
+		ip, err := netip.ParseAddr(strings.TrimSpace(r.Header.Get("X-Real-IP")))
+		return ip.Unmap(), err == nil
+	}
+
+	for i := len(hops) - 1; i >= 0; i-- {
+		ip, err := netip.ParseAddr(strings.TrimSpace(hops[i]))
+		if err != nil {
+			// a hop we cannot vouch for wrote garbage
+			return netip.Addr{}, false
+		}
+		ip = ip.Unmap()
+		// when every hop is a trusted proxy, the leftmost one is the client
+		if i == 0 || !trusted(ip) {
+			return ip, true
+		}
+	}
+	return netip.Addr{}, false
+}
+
+// parsePrefixes skips invalid entries; config validation reports them.
+func parsePrefixes(list []string) []netip.Prefix {
+	out := make([]netip.Prefix, 0, len(list))
+	for _, s := range list {
+		if p, err := netip.ParsePrefix(s); err == nil {
+			out = append(out, p.Masked())
+		} else if a, err := netip.ParseAddr(s); err == nil {
+			a = a.Unmap()
+			out = append(out, netip.PrefixFrom(a, a.BitLen()))
+		}
+	}
+	return out
+}
@@ -0,0 +1,33 @@
+package middleware
+
+import (
+	"bookpulse/internal/respond"
+	"bookpulse/internal/service/auth"
+	"net/http"
+)
+
+// RequireRole lets a request through only if it is authenticated and the
+// caller holds at least one of roles (higher roles include lower ones).
+// Personal access tokens never pass: they are not granted roles.
+func RequireRole(jwt *auth.JWT, roles ...string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			p, r := principal(r, jwt)
+			if p == nil {
+				respond.WriteError(w, r, respond.Unauthorized())
+				return
+			}
+			if p.IsAccessToken() {
+				respond.WriteError(w, r, respond.Forbidden(respond.CodeForbidden, "you do not have access to this"))
+				return
+			}
+			for _, role := range roles {
+				if auth.HasRole(p.Role, role) {
+					next.ServeHTTP(w, r)
+					return
+				}
+			}
+			respond.WriteError(w, r, respond.Forbidden(respond.CodeForbidden, "you do not have access to this"))
+		})
+	}
+}
@@ -6,4 +6,6 @@ type ReviewDto struct {
 	CreatedAt string `json:"createdAt"`
 	Rating    int    `json:"rating"`
 	Text      string `json:"text"`
+	// Mine marks the caller's own review.
+	Mine bool `json:"mine"`
 }
\ No newline at end of file
@@ -0,0 +1,12 @@
+package models
+
+import "time"
+
+type SessionDTO struct {
+	ID         int64     `json:"id"`
+	UserAgent  string    `json:"userAgent"`
+	IP         string    `json:"ip"`
+	CreatedAt  time.Time `json:"createdAt"`
+	LastSeenAt time.Time `json:"lastSeenAt"`
+	Current    bool      `json:"current"`
+}
@@ -0,0 +1,205 @@
+package oidc
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// JWK is a single JSON Web Key as published in a JWKS document.
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid,omitempty"`
+	Use string `json:"use,omitempty"`
+	Alg string `json:"alg,omitempty"`
+
+	// RSA
+	N string `json:"n,omitempty"`
+	E string `json:"e,omitempty"`
+
+	// EC / OKP
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+}
+
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// NewJWK encodes an RSA or Ed25519 public key as a signing JWK.
+func NewJWK(kid, alg string, pub crypto.PublicKey) (JWK, error) {
+	switch k := pub.(type) {
+	case *rsa.PublicKey:
+		return JWK{
+			Kty: "RSA", Kid: kid, Use: "sig", Alg: alg,
+			N: base64.RawURLEncoding.EncodeToString(k.N.Bytes()),
+			E: base64.RawURLEncoding.EncodeToString(big.NewInt(int64(k.E)).Bytes()),
+		}, nil
+	case ed25519.PublicKey:
+		return JWK{
+			Kty: "OKP", Kid: kid, Use: "sig", Alg: alg,
+			Crv: "Ed25519",
+			X:   base64.RawURLEncoding.EncodeToString(k),
+		}, nil
+	default:
+		return JWK{}, fmt.Errorf("jwk %s: unsupported key type %T", kid, pub)
+	}
+}
+
+// PublicKey decodes the key material.
+func (k JWK) PublicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := b64BigInt(k.N)
+		if err != nil {
+			return nil, fmt.Errorf("jwk %s: n: %w", k.Kid, err)
+		}
+		e, err := b64BigInt(k.E)
+		if err != nil {
+			return nil, fmt.Errorf("jwk %s: e: %w", k.Kid, err)
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("jwk %s: unsupported curve %q", k.Kid, k.Crv)
+		}
+		x, err := b64BigInt(k.X)
+		if err != nil {
+			return nil, fmt.Errorf("jwk %s: x: %w", k.Kid, err)
+		}
+		y, err := b64BigInt(k.Y)
+		if err != nil {
+			return nil, fmt.Errorf("jwk %s: y: %w", k.Kid, err)
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+
+	case "OKP":
+		if k.Crv != "Ed25519" {
+			return nil, fmt.Errorf("jwk %s: unsupported curve %q", k.Kid, k.Crv)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil || len(x) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("jwk %s: bad Ed25519 key", k.Kid)
+		}
+		return ed25519.PublicKey(x), nil
+
+	default:
+		return nil, fmt.Errorf("jwk %s: unsupported kty %q", k.Kid, k.Kty)
+	}
+}
+
+func b64BigInt(s string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	if len(b) == 0 {
+		return nil, errors.New("empty")
+	}
+	return new(big.Int).SetBytes(b), nil
+}
+
+// minRefetch limits how often an unknown kid triggers a JWKS download.
+const minRefetch = time.Minute
+
+// keyCache fetches a provider's JWKS and refreshes it when a token is signed
+// with a key id it has not seen yet (key rotation).
+type keyCache struct {
+	url    string
+	client *http.Client
+
+	mu        sync.Mutex
+	keys      map[string]crypto.PublicKey
+	fetchedAt time.Time
+}
+
+func (c *keyCache) get(ctx context.Context, kid string) (crypto.PublicKey, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if k, ok := c.lookup(kid); ok {
+		return k, nil
+	}
+	if !c.fetchedAt.IsZero() && time.Since(c.fetchedAt) < minRefetch {
+		return nil, fmt.Errorf("unknown signing key %q", kid)
+	}
+	if err := c.fetch(ctx); err != nil {
+		return nil, err
+	}
+	if k, ok := c.lookup(kid); ok {
+		return k, nil
+	}
+	return nil, fmt.Errorf("unknown signing key %q", kid)
+}
+
+func (c *keyCache) lookup(kid string) (crypto.PublicKey, bool) {
+	if kid == "" && len(c.keys) == 1 {
+		for _, k := range c.keys {
+			return k, true
+		}
+	}
+	k, ok := c.keys[kid]
+	return k, ok
+}
+
+func (c *keyCache) fetch(ctx context.Context) error {
+	var set JWKS
+	if err := getJSON(ctx, c.client, c.url, &set); err != nil {
+		return fmt.Errorf("jwks: %w", err)
+	}
+
+	keys := make(map[string]crypto.PublicKey, len(set.Keys))
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		pub, err := k.PublicKey()
+		if err != nil {
+			continue
+		}
+		keys[k.Kid] = pub
+	}
+	c.keys = keys
+	c.fetchedAt = time.Now()
+	return nil
+}
+
+func getJSON(ctx context.Context, client *http.Client, url string, v any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		return fmt.Errorf("GET %s: status %d", url, resp.StatusCode)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
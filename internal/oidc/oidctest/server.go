@@ -0,0 +1,190 @@
+// Package oidctest runs a local OpenID Connect provider for tests. It serves
+// discovery, a JWKS and a token endpoint that enforces the code + PKCE
+// exchange; the browser leg is replaced by Authorize.
+package oidctest
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"time"
+
+	"bookpulse/internal/oidc"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// User is the account the provider signs in as.
+type User struct {
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Name          string
+}
+
+type grant struct {
+	clientID    string
+	redirectURI string
+	challenge   string
+	nonce       string
+	user        User
+}
+
+// Server is a provider whose issuer is its URL.
+type Server struct {
+	*httptest.Server
+	ClientID string
+	KeyID    string
+	Key      ed25519.PrivateKey
+
+	mu     sync.Mutex
+	grants map[string]grant
+}
+
+// NewServer starts a provider that accepts clientID. Call Close when done.
+func NewServer(clientID string) *Server {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		panic(err)
+	}
+	s := &Server{ClientID: clientID, KeyID: "test-key", Key: key, grants: map[string]grant{}}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /.well-known/openid-configuration", s.discovery)
+	mux.HandleFunc("GET /jwks", s.jwks)
+	mux.HandleFunc("POST /token", s.token)
+	s.Server = httptest.NewServer(mux)
+	return s
+}
+
+// Config returns the client configuration for this provider.
+func (s *Server) Config(redirectURL string) oidc.Config {
+	return oidc.Config{Issuer: s.URL, ClientID: s.ClientID, RedirectURL: redirectURL}
+}
+
+// Authorize stands in for the browser visiting authURL and user consenting.
+// It returns the code and state the provider would redirect back with.
+func (s *Server) Authorize(authURL string, user User) (code, state string, err error) {
+	u, err := url.Parse(authURL)
+	if err != nil {
+		return "", "", err
+	}
+	q := u.Query()
+	if q.Get("response_type") != "code" || q.Get("code_challenge_method") != "S256" || q.Get("code_challenge") == "" {
+		return "", "", errors.New("oidctest: not a code + PKCE request")
+	}
+	if q.Get("client_id") != s.ClientID {
+		return "", "", errors.New("oidctest: unknown client")
+	}
+
+	code = rand.Text()
+	s.mu.Lock()
+	s.grants[code] = grant{
+		clientID:    q.Get("client_id"),
+		redirectURI: q.Get("redirect_uri"),
+		challenge:   q.Get("code_challenge"),
+		nonce:       q.Get("nonce"),
+		user:        user,
+	}
+	s.mu.Unlock()
+	return code, q.Get("state"), nil
+}
+
+// Claims returns the ID token claims the provider issues for user.
+func (s *Server) Claims(user User, nonce string) jwt.MapClaims {
+	now := time.Now()
+	return jwt.MapClaims{
+		"iss":            s.URL,
+		"aud":            s.ClientID,
+		"sub":            user.Subject,
+		"email":          user.Email,
+		"email_verified": user.EmailVerified,
+		"name":           user.Name,
+		"nonce":          nonce,
+		"iat":            now.Unix(),
+		"exp":            now.Add(time.Hour).Unix(),
+	}
+}
+
+// Sign signs claims with the provider key.
+func (s *Server) Sign(claims jwt.Claims) string {
+	return SignWith(s.Key, s.KeyID, claims)
+}
+
+// SignWith signs claims with any Ed25519 key, such as one the provider does
+// not publish.
+func SignWith(key ed25519.PrivateKey, kid string, claims jwt.Claims) string {
+	t := jwt.NewWithClaims(jwt.SigningMethodEdDSA, claims)
+	t.Header["kid"] = kid
+	raw, err := t.SignedString(key)
+	if err != nil {
+		panic(err)
+	}
+	return raw
+}
+
+func (s *Server) discovery(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, oidc.Discovery{
+		Issuer:                s.URL,
+		AuthorizationEndpoint: s.URL + "/authorize",
+		TokenEndpoint:         s.URL + "/token",
+		JWKSURI:               s.URL + "/jwks",
+	})
+}
+
+func (s *Server) jwks(w http.ResponseWriter, r *http.Request) {
+	k, err := oidc.NewJWK(s.KeyID, "EdDSA", s.Key.Public())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, oidc.JWKS{Keys: []oidc.JWK{k}})
+}
+
+// token redeems a code once, for the client and redirect URI it was issued
+// to and the verifier matching its challenge.
+func (s *Server) token(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		tokenError(w, "invalid_request")
+		return
+	}
+	if r.PostForm.Get("grant_type") != "authorization_code" {
+		tokenError(w, "unsupported_grant_type")
+		return
+	}
+
+	s.mu.Lock()
+	g, ok := s.grants[r.PostForm.Get("code")]
+	delete(s.grants, r.PostForm.Get("code"))
+	s.mu.Unlock()
+
+	switch {
+	case !ok,
+		r.PostForm.Get("client_id") != g.clientID,
+		r.PostForm.Get("redirect_uri") != g.redirectURI,
+		oidc.CodeChallenge(r.PostForm.Get("code_verifier")) != g.challenge:
+		tokenError(w, "invalid_grant")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]string{
+		"access_token": rand.Text(),
+		"token_type":   "Bearer",
+		"id_token":     s.Sign(s.Claims(g.user, g.nonce)),
+	})
+}
+
+func tokenError(w http.ResponseWriter, code string) {
+	writeJSON(w, http.StatusBadRequest, map[string]string{"error": code})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
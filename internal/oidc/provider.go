@@ -0,0 +1,229 @@
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+type Config struct {
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	Scopes       []string
+}
+
+// Discovery is the subset of the OpenID provider metadata we use.
+type Discovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// Provider runs the authorization code + PKCE flow against one OpenID
+// Connect issuer. Endpoints come from the discovery document, fetched on
+// first use.
+type Provider struct {
+	Name   string
+	cfg    Config
+	client *http.Client
+
+	mu   sync.Mutex
+	disc *Discovery
+	keys *keyCache
+}
+
+func NewProvider(name string, cfg Config, client *http.Client) *Provider {
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	if len(cfg.Scopes) == 0 {
+		cfg.Scopes = []string{"openid", "email", "profile"}
+	}
+	cfg.Issuer = strings.TrimRight(cfg.Issuer, "/")
+	return &Provider{Name: name, cfg: cfg, client: client}
+}
+
+func (p *Provider) discover(ctx context.Context) (*Discovery, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.disc != nil {
+		return p.disc, nil
+	}
+
+	var d Discovery
+	if err := getJSON(ctx, p.client, p.cfg.Issuer+"/.well-known/openid-configuration", &d); err != nil {
+		return nil, fmt.Errorf("oidc %s discovery: %w", p.Name, err)
+	}
+	if strings.TrimRight(d.Issuer, "/") != p.cfg.Issuer {
+		return nil, fmt.Errorf("oidc %s discovery: issuer mismatch %q", p.Name, d.Issuer)
+	}
+	if d.AuthorizationEndpoint == "" || d.TokenEndpoint == "" || d.JWKSURI == "" {
+		return nil, fmt.Errorf("oidc %s discovery: incomplete metadata", p.Name)
+	}
+
+	p.disc = &d
+	p.keys = &keyCache{url: d.JWKSURI, client: p.client}
+	return p.disc, nil
+}
+
+// AuthCodeURL builds the URL the browser is sent to.
+func (p *Provider) AuthCodeURL(ctx context.Context, state, nonce, codeVerifier string) (string, error) {
+	d, err := p.discover(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	q := url.Values{}
+	q.Set("response_type", "code")
+	q.Set("client_id", p.cfg.ClientID)
+	q.Set("redirect_uri", p.cfg.RedirectURL)
+	q.Set("scope", strings.Join(p.cfg.Scopes, " "))
+	q.Set("state", state)
+	q.Set("nonce", nonce)
+	q.Set("code_challenge", CodeChallenge(codeVerifier))
+	q.Set("code_challenge_method", "S256")
+
+	sep := "?"
+	if strings.Contains(d.AuthorizationEndpoint, "?") {
+		sep = "&"
+	}
+	return d.AuthorizationEndpoint + sep + q.Encode(), nil
+}
+
+type tokenResponse struct {
+	AccessToken string `json:"access_token"`
+	IDToken     string `json:"id_token"`
+	TokenType   string `json:"token_type"`
+	Error       string `json:"error"`
+	ErrorDesc   string `json:"error_description"`
+}
+
+// Exchange trades the authorization code for tokens and returns the verified
+// ID token claims.
+func (p *Provider) Exchange(ctx context.Context, code, codeVerifier, nonce string) (*IDClaims, error) {
+	d, err := p.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", p.cfg.RedirectURL)
+	form.Set("client_id", p.cfg.ClientID)
+	form.Set("code_verifier", codeVerifier)
+	if p.cfg.ClientSecret != "" {
+		form.Set("client_secret", p.cfg.ClientSecret)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("oidc %s token: %w", p.Name, err)
+	}
+	defer resp.Body.Close()
+
+	var tr tokenResponse
+	if err := json.NewDecoder(resp.Body).Decode(&tr); err != nil {
+		return nil, fmt.Errorf("oidc %s token: %w", p.Name, err)
+	}
+	if resp.StatusCode >= 400 || tr.Error != "" {
+		return nil, fmt.Errorf("oidc %s token: status %d %s %s", p.Name, resp.StatusCode, tr.Error, tr.ErrorDesc)
+	}
+	if tr.IDToken == "" {
+		return nil, fmt.Errorf("oidc %s token: no id_token in response", p.Name)
+	}
+
+	return p.VerifyIDToken(ctx, tr.IDToken, nonce)
+}
+
+// IDClaims are the ID token claims we rely on.
+type IDClaims struct {
+	Nonce         string `json:"nonce"`
+	Email         string `json:"email"`
+	EmailVerified any    `json:"email_verified"`
+	Name          string `json:"name"`
+	jwt.RegisteredClaims
+}
+
+// Verified reports email_verified, which some providers send as a string.
+func (c *IDClaims) Verified() bool {
+	switch v := c.EmailVerified.(type) {
+	case bool:
+		return v
+	case string:
+		return v == "true"
+	default:
+		return false
+	}
+}
+
+var ErrNonceMismatch = errors.New("oidc: nonce mismatch")
+
+// VerifyIDToken checks the signature against the provider JWKS and
+// validates issuer, audience, expiry and nonce.
+func (p *Provider) VerifyIDToken(ctx context.Context, raw, nonce string) (*IDClaims, error) {
+	if _, err := p.discover(ctx); err != nil {
+		return nil, err
+	}
+
+	var claims IDClaims
+	_, err := jwt.ParseWithClaims(raw, &claims, func(t *jwt.Token) (any, error) {
+		kid, _ := t.Header["kid"].(string)
+		return p.keys.get(ctx, kid)
+	},
+		jwt.WithValidMethods([]string{"RS256", "RS384", "RS512", "ES256", "ES384", "ES512", "EdDSA"}),
+		jwt.WithIssuer(p.cfg.Issuer),
+		jwt.WithAudience(p.cfg.ClientID),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+		jwt.WithLeeway(time.Minute),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("oidc %s id_token: %w", p.Name, err)
+	}
+	if claims.Subject == "" {
+		return nil, fmt.Errorf("oidc %s id_token: missing sub", p.Name)
+	}
+	if claims.Nonce != nonce {
+		return nil, ErrNonceMismatch
+	}
+	return &claims, nil
+}
+
+// RandomString returns a URL-safe random value for state, nonce and PKCE
+// verifiers.
+func RandomString() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// CodeChallenge is the S256 PKCE challenge for verifier.
+func CodeChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
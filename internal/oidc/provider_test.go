@@ -0,0 +1,116 @@
+package oidc_test
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
+	"testing"
+	"time"
+
+	"bookpulse/internal/oidc"
+	"bookpulse/internal/oidc/oidctest"
+)
+
+const redirectURL = "http://localhost/callback"
+
+var alice = oidctest.User{Subject: "alice-sub", Email: "alice@example.com", EmailVerified: true, Name: "Alice"}
+
+func newProvider(t *testing.T) (*oidc.Provider, *oidctest.Server) {
+	t.Helper()
+	srv := oidctest.NewServer("bookpulse")
+	t.Cleanup(srv.Close)
+	return oidc.NewProvider("mock", srv.Config(redirectURL), srv.Client()), srv
+}
+
+// authorize starts a flow and has alice consent to it.
+func authorize(t *testing.T, p *oidc.Provider, srv *oidctest.Server, verifier, nonce string) string {
+	t.Helper()
+	authURL, err := p.AuthCodeURL(context.Background(), "state", nonce, verifier)
+	if err != nil {
+		t.Fatal(err)
+	}
+	code, state, err := srv.Authorize(authURL, alice)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if state != "state" {
+		t.Fatalf("state = %q; want it passed through", state)
+	}
+	return code
+}
+
+func TestExchange(t *testing.T) {
+	p, srv := newProvider(t)
+	ctx := context.Background()
+
+	code := authorize(t, p, srv, "verifier", "nonce")
+	claims, err := p.Exchange(ctx, code, "verifier", "nonce")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if claims.Subject != alice.Subject || claims.Email != alice.Email || !claims.Verified() || claims.Name != alice.Name {
+		t.Errorf("claims = %+v; want alice", claims)
+	}
+
+	if _, err := p.Exchange(ctx, code, "verifier", "nonce"); err == nil {
+		t.Error("a code was redeemed twice")
+	}
+
+	code = authorize(t, p, srv, "verifier", "nonce")
+	if _, err := p.Exchange(ctx, code, "another verifier", "nonce"); err == nil {
+		t.Error("Exchange with the wrong PKCE verifier succeeded")
+	}
+
+	code = authorize(t, p, srv, "verifier", "nonce")
+	if _, err := p.Exchange(ctx, code, "verifier", "another nonce"); !errors.Is(err, oidc.ErrNonceMismatch) {
+		t.Errorf("Exchange with another nonce = %v; want ErrNonceMismatch", err)
+	}
+}
+
+func TestVerifyIDTokenRejects(t *testing.T) {
+	p, srv := newProvider(t)
+	ctx := context.Background()
+
+	if _, err := p.VerifyIDToken(ctx, srv.Sign(srv.Claims(alice, "n")), "n"); err != nil {
+		t.Fatalf("valid token rejected: %v", err)
+	}
+
+	_, otherKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	with := func(key string, value any) string {
+		c := srv.Claims(alice, "n")
+		if value == nil {
+			delete(c, key)
+		} else {
+			c[key] = value
+		}
+		return srv.Sign(c)
+	}
+
+	tests := []struct {
+		name  string
+		token string
+	}{
+		{"foreign key", oidctest.SignWith(otherKey, srv.KeyID, srv.Claims(alice, "n"))},
+		{"unknown kid", oidctest.SignWith(srv.Key, "other-key", srv.Claims(alice, "n"))},
+		{"other issuer", with("iss", "https://evil.example.com")},
+		{"other audience", with("aud", "someone-else")},
+		{"expired", with("exp", time.Now().Add(-time.Hour).Unix())},
+		{"no expiry", with("exp", nil)},
+		{"issued in the future", with("iat", time.Now().Add(time.Hour).Unix())},
+		{"no subject", with("sub", nil)},
+		{"garbage", "not.a.jwt"},
+	}
+	for _, tt := range tests {
+		if _, err := p.VerifyIDToken(ctx, tt.token, "n"); err == nil {
+			t.Errorf("%s: token accepted", tt.name)
+		}
+	}
+
+	if _, err := p.VerifyIDToken(ctx, srv.Sign(srv.Claims(alice, "n")), "other"); !errors.Is(err, oidc.ErrNonceMismatch) {
+		t.Errorf("other nonce = %v; want ErrNonceMismatch", err)
+	}
+}
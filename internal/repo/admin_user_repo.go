@@ -0,0 +1,317 @@
+package repo
+
+import (
+	"cmp"
+	"context"
+	"database/sql"
+	"errors"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// UserSummary is the admin view of an account.
+type UserSummary struct {
+	ID            int        `json:"id"`
+	Email         string     `json:"email"`
+	Name          string     `json:"name"`
+	Role          string     `json:"role"`
+	EmailVerified bool       `json:"emailVerified"`
+	CreatedAt     time.Time  `json:"createdAt"`
+	SuspendedAt   *time.Time `json:"suspendedAt"`
+	SuspendReason string     `json:"suspendReason,omitempty"`
+	LibrarySize   int        `json:"librarySize"`
+	ReviewCount   int        `json:"reviewCount"`
+}
+
+const userSummarySelect = `
+	SELECT u.id, u.email, u.name, u.role, u.email_verified_at IS NOT NULL, u.created_at,
+	       u.suspended_at, u.suspend_reason,
+	       (SELECT COUNT(*) FROM user_books ub WHERE ub.user_id = u.id)::int,
+	       (SELECT COUNT(*) FROM reviews rv WHERE rv.user_id = u.id)::int
+	FROM users u`
+
+func scanUserSummary(row pgx.Row) (*UserSummary, error) {
+	var s UserSummary
+	err := row.Scan(&s.ID, &s.Email, &s.Name, &s.Role, &s.EmailVerified, &s.CreatedAt,
+		&s.SuspendedAt, &s.SuspendReason, &s.LibrarySize, &s.ReviewCount)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &s, nil
+}
+
+// likePattern escapes q for a case-insensitive substring match.
+func likePattern(q string) string {
+	q = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(q)
+	return "%" + q + "%"
+}
+
+// SearchUsers matches q against email and name, newest accounts first.
+// An empty q lists everyone. It also returns the total number of matches.
+func (r *UserRepoPGX) SearchUsers(ctx context.Context, q string, limit, offset int) ([]UserSummary, int, error) {
+	pattern := likePattern(strings.TrimSpace(q))
+
+	var total int
+	if err := r.db.QueryRow(ctx, `
+		SELECT COUNT(*) FROM users
+		WHERE email ILIKE $1 OR name ILIKE $1;
+	`, pattern).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	rows, err := r.db.Query(ctx, userSummarySelect+`
+		WHERE u.email ILIKE $1 OR u.name ILIKE $1
+		ORDER BY u.created_at DESC, u.id DESC
+		LIMIT $2 OFFSET $3;
+	`, pattern, limit, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	out := make([]UserSummary, 0, limit)
+	for rows.Next() {
+		s, err := scanUserSummary(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		out = append(out, *s)
+	}
+	return out, total, rows.Err()
+}
+
+func (r *UserRepoPGX) Summary(ctx context.Context, id int) (*UserSummary, error) {
+	return scanUserSummary(r.db.QueryRow(ctx, userSummarySelect+` WHERE u.id = $1;`, id))
+}
+
+// SetSuspended suspends the user with reason, or lifts the suspension when
+// suspend is false. It returns false if there is no such user.
+func (r *UserRepoPGX) SetSuspended(ctx context.Context, id int, suspend bool, reason string) (bool, error) {
+	var cmd pgconn.CommandTag
+	var err error
+	if suspend {
+		cmd, err = r.db.Exec(ctx, `
+			UPDATE users SET suspended_at = COALESCE(suspended_at, now()), suspend_reason = $2
+			WHERE id = $1;
+		`, id, reason)
+	} else {
+		cmd, err = r.db.Exec(ctx, `
+			UPDATE users SET suspended_at = NULL, suspend_reason = ''
+			WHERE id = $1;
+		`, id)
+	}
+	if err != nil {
+		return false, err
+	}
+	return cmd.RowsAffected() > 0, nil
+}
+
+// ClearPassword makes the current password unusable, so the only way back
+// in is a reset link or a linked identity.
+func (r *UserRepoPGX) ClearPassword(ctx context.Context, id int) error {
+	_, err := r.db.Exec(ctx, `UPDATE users SET password_hash = '' WHERE id = $1`, id)
+	return err
+}
+
+// Delete removes the user; everything they own goes with it via ON DELETE
+// CASCADE. With anonymizeReviews their reviews are detached and kept.
+func (r *UserRepoPGX) Delete(ctx context.Context, id int, anonymizeReviews bool) (bool, error) {
+	var deleted bool
+	err := pgx.BeginFunc(ctx, r.db, func(tx pgx.Tx) error {
+		var err error
+		deleted, err = deleteUser(ctx, tx, id, anonymizeReviews)
+		return err
+	})
+	return deleted, err
+}
+
+func deleteUser(ctx context.Context, tx pgx.Tx, id int, anonymizeReviews bool) (bool, error) {
+	if anonymizeReviews {
+		if _, err := tx.Exec(ctx, `UPDATE reviews SET user_id = NULL WHERE user_id = $1`, id); err != nil {
+			return false, err
+		}
+	}
+	cmd, err := tx.Exec(ctx, `DELETE FROM users WHERE id = $1`, id)
+	if err != nil {
+		return false, err
+	}
+	return cmd.RowsAffected() > 0, nil
+}
+
+// userSummarySelectSQLite is userSummarySelect without the Postgres casts.
+const userSummarySelectSQLite = `
+	SELECT u.id, u.email, u.name, u.role, u.email_verified_at IS NOT NULL, u.created_at,
+	       u.suspended_at, u.suspend_reason,
+	       (SELECT COUNT(*) FROM user_books ub WHERE ub.user_id = u.id),
+	       (SELECT COUNT(*) FROM reviews rv WHERE rv.user_id = u.id)
+	FROM users u`
+
+// SearchUsers is UserRepoPGX.SearchUsers; SQLite's LIKE ignores case for
+// ASCII letters only.
+func (r *UserRepoSQLite) SearchUsers(ctx context.Context, q string, limit, offset int) ([]UserSummary, int, error) {
+	pattern := likePattern(strings.TrimSpace(q))
+
+	var total int
+	if err := r.db.QueryRowContext(ctx, `
+		SELECT COUNT(*) FROM users
+		WHERE email LIKE ?1 ESCAPE '\' OR name LIKE ?1 ESCAPE '\';
+	`, pattern).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	rows, err := r.db.QueryContext(ctx, userSummarySelectSQLite+`
+		WHERE u.email LIKE ?1 ESCAPE '\' OR u.name LIKE ?1 ESCAPE '\'
+		ORDER BY u.created_at DESC, u.id DESC
+		LIMIT ?2 OFFSET ?3;
+	`, pattern, limit, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	out := make([]UserSummary, 0, limit)
+	for rows.Next() {
+		s, err := scanUserSummary(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		out = append(out, *s)
+	}
+	return out, total, rows.Err()
+}
+
+func (r *UserRepoSQLite) Summary(ctx context.Context, id int) (*UserSummary, error) {
+	return scanUserSummary(r.db.QueryRowContext(ctx, userSummarySelectSQLite+` WHERE u.id = ?;`, id))
+}
+
+func (r *UserRepoSQLite) SetSuspended(ctx context.Context, id int, suspend bool, reason string) (bool, error) {
+	if suspend {
+		return changed(r.db.ExecContext(ctx, `
+			UPDATE users SET suspended_at = COALESCE(suspended_at, ?), suspend_reason = ?
+			WHERE id = ?;
+		`, sqliteNow(), reason, id))
+	}
+	return changed(r.db.ExecContext(ctx, `
+		UPDATE users SET suspended_at = NULL, suspend_reason = ''
+		WHERE id = ?;
+	`, id))
+}
+
+func (r *UserRepoSQLite) ClearPassword(ctx context.Context, id int) error {
+	_, err := r.db.ExecContext(ctx, `UPDATE users SET password_hash = '' WHERE id = ?`, id)
+	return err
+}
+
+func (r *UserRepoSQLite) Delete(ctx context.Context, id int, anonymizeReviews bool) (bool, error) {
+	var deleted bool
+	err := sqliteTx(ctx, r.db, func(tx *sql.Tx) error {
+		var err error
+		deleted, err = deleteUserSQLite(ctx, tx, id, anonymizeReviews)
+		return err
+	})
+	return deleted, err
+}
+
+func deleteUserSQLite(ctx context.Context, tx *sql.Tx, id int, anonymizeReviews bool) (bool, error) {
+	if anonymizeReviews {
+		if _, err := tx.ExecContext(ctx, `UPDATE reviews SET user_id = NULL WHERE user_id = ?`, id); err != nil {
+			return false, err
+		}
+	}
+	return changed(tx.ExecContext(ctx, `DELETE FROM users WHERE id = ?`, id))
+}
+
+func (r *UserMemory) SearchUsers(ctx context.Context, q string, limit, offset int) ([]UserSummary, int, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	q = strings.ToLower(strings.TrimSpace(q))
+	var matches []*memUser
+	for _, u := range r.db.users {
+		if strings.Contains(strings.ToLower(u.Email), q) || strings.Contains(strings.ToLower(u.Name), q) {
+			matches = append(matches, u)
+		}
+	}
+	slices.SortFunc(matches, func(a, b *memUser) int {
+		return cmp.Or(b.CreatedAt.Compare(a.CreatedAt), cmp.Compare(b.ID, a.ID))
+	})
+
+	out := make([]UserSummary, 0, limit)
+	for i := offset; i < len(matches) && len(out) < limit; i++ {
+		out = append(out, r.summary(matches[i]))
+	}
+	return out, len(matches), nil
+}
+
+func (r *UserMemory) Summary(ctx context.Context, id int) (*UserSummary, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	u := r.db.users[id]
+	if u == nil {
+		return nil, nil
+	}
+	s := r.summary(u)
+	return &s, nil
+}
+
+func (r *UserMemory) summary(u *memUser) UserSummary {
+	s := UserSummary{
+		ID:            u.ID,
+		Email:         u.Email,
+		Name:          u.Name,
+		Role:          u.Role,
+		EmailVerified: u.EmailVerifiedAt != nil,
+		CreatedAt:     u.CreatedAt,
+		SuspendedAt:   u.SuspendedAt,
+		SuspendReason: u.SuspendReason,
+	}
+	for k := range r.db.shelf {
+		if k.userID == u.ID {
+			s.LibrarySize++
+		}
+	}
+	for _, rv := range r.db.reviews {
+		if rv.UserID == u.ID {
+			s.ReviewCount++
+		}
+	}
+	return s
+}
+
+func (r *UserMemory) SetSuspended(ctx context.Context, id int, suspend bool, reason string) (bool, error) {
+	return r.update(id, func(u *memUser) {
+		if !suspend {
+			u.SuspendedAt, u.SuspendReason = nil, ""
+			return
+		}
+		if u.SuspendedAt == nil {
+			now := time.Now()
+			u.SuspendedAt = &now
+		}
+		u.SuspendReason = reason
+	}), nil
+}
+
+func (r *UserMemory) ClearPassword(ctx context.Context, id int) error {
+	r.update(id, func(u *memUser) { u.PasswordHash = "" })
+	return nil
+}
+
+func (r *UserMemory) Delete(ctx context.Context, id int, anonymizeReviews bool) (bool, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	if r.db.users[id] == nil {
+		return false, nil
+	}
+	r.db.deleteUser(id, anonymizeReviews)
+	return true, nil
+}
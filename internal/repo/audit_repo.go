@@ -0,0 +1,189 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"maps"
+	"slices"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// AuditEvent is one entry of the audit trail. ActorID is who acted (nil for
+// anonymous requests), UserID the account the event is about.
+type AuditEvent struct {
+	ID        int64          `json:"id"`
+	Type      string         `json:"type"`
+	ActorID   *int           `json:"actorId"`
+	UserID    *int           `json:"userId"`
+	IP        string         `json:"ip"`
+	UserAgent string         `json:"userAgent"`
+	Details   map[string]any `json:"details"`
+	CreatedAt time.Time      `json:"createdAt"`
+}
+
+// AuditRepo is the append-only audit trail.
+type AuditRepo interface {
+	Record(ctx context.Context, e AuditEvent) error
+	List(ctx context.Context, f AuditFilter) ([]AuditEvent, error)
+}
+
+type AuditRepoPGX struct {
+	db *pgxpool.Pool
+}
+
+func NewAuditRepoPGX(db *pgxpool.Pool) *AuditRepoPGX {
+	return &AuditRepoPGX{db: db}
+}
+
+func (r *AuditRepoPGX) Record(ctx context.Context, e AuditEvent) error {
+	if e.Details == nil {
+		e.Details = map[string]any{}
+	}
+	_, err := r.db.Exec(ctx, `
+		INSERT INTO audit_events (event_type, actor_id, user_id, ip, user_agent, details)
+		VALUES ($1, $2, $3, $4, $5, $6);
+	`, e.Type, e.ActorID, e.UserID, e.IP, e.UserAgent, e.Details)
+	return err
+}
+
+// AuditFilter narrows List. Zero fields match everything; BeforeID pages
+// backwards from an earlier result.
+type AuditFilter struct {
+	UserID   int
+	Types    []string
+	BeforeID int64
+	Limit    int
+}
+
+// List returns matching events, newest first.
+func (r *AuditRepoPGX) List(ctx context.Context, f AuditFilter) ([]AuditEvent, error) {
+	if f.Types == nil {
+		f.Types = []string{}
+	}
+	rows, err := r.db.Query(ctx, `
+		SELECT id, event_type, actor_id, user_id, ip, user_agent, details, created_at
+		FROM audit_events
+		WHERE ($1 = 0 OR user_id = $1)
+		  AND (cardinality($2::text[]) = 0 OR event_type = ANY($2))
+		  AND ($3 = 0 OR id < $3)
+		ORDER BY id DESC
+		LIMIT $4;
+	`, f.UserID, f.Types, f.BeforeID, f.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := make([]AuditEvent, 0, f.Limit)
+	for rows.Next() {
+		var e AuditEvent
+		if err := rows.Scan(&e.ID, &e.Type, &e.ActorID, &e.UserID, &e.IP, &e.UserAgent, &e.Details, &e.CreatedAt); err != nil {
+			return nil, err
+		}
+		out = append(out, e)
+	}
+	return out, rows.Err()
+}
+
+type AuditRepoSQLite struct {
+	db *sql.DB
+}
+
+func NewAuditRepoSQLite(db *sql.DB) *AuditRepoSQLite {
+	return &AuditRepoSQLite{db: db}
+}
+
+func (r *AuditRepoSQLite) Record(ctx context.Context, e AuditEvent) error {
+	if e.Details == nil {
+		e.Details = map[string]any{}
+	}
+	details, err := jsonText(e.Details)
+	if err != nil {
+		return err
+	}
+	_, err = r.db.ExecContext(ctx, `
+		INSERT INTO audit_events (event_type, actor_id, user_id, ip, user_agent, details, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?);
+	`, e.Type, e.ActorID, e.UserID, e.IP, e.UserAgent, details, sqliteNow())
+	return err
+}
+
+// List is AuditRepoPGX.List with the type filter passed as a JSON array.
+func (r *AuditRepoSQLite) List(ctx context.Context, f AuditFilter) ([]AuditEvent, error) {
+	if f.Types == nil {
+		f.Types = []string{}
+	}
+	types, err := jsonText(f.Types)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT id, event_type, actor_id, user_id, ip, user_agent, details, created_at
+		FROM audit_events
+		WHERE (?1 = 0 OR user_id = ?1)
+		  AND (json_array_length(?2) = 0 OR event_type IN (SELECT value FROM json_each(?2)))
+		  AND (?3 = 0 OR id < ?3)
+		ORDER BY id DESC
+		LIMIT ?4;
+	`, f.UserID, types, f.BeforeID, f.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := make([]AuditEvent, 0, f.Limit)
+	for rows.Next() {
+		var e AuditEvent
+		if err := rows.Scan(&e.ID, &e.Type, &e.ActorID, &e.UserID, &e.IP, &e.UserAgent, jsonColumn{&e.Details}, &e.CreatedAt); err != nil {
+			return nil, err
+		}
+		out = append(out, e)
+	}
+	return out, rows.Err()
+}
+
+type AuditMemory struct {
+	db *MemoryDB
+}
+
+func NewAuditMemory(db *MemoryDB) *AuditMemory {
+	return &AuditMemory{db: db}
+}
+
+func (r *AuditMemory) Record(ctx context.Context, e AuditEvent) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	r.db.lastAuditID++
+	e.ID = r.db.lastAuditID
+	e.Details = maps.Clone(e.Details)
+	if e.Details == nil {
+		e.Details = map[string]any{}
+	}
+	e.CreatedAt = time.Now()
+	r.db.audit = append(r.db.audit, e)
+	return nil
+}
+
+func (r *AuditMemory) List(ctx context.Context, f AuditFilter) ([]AuditEvent, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	out := make([]AuditEvent, 0, f.Limit)
+	for i := len(r.db.audit) - 1; i >= 0 && len(out) < f.Limit; i-- {
+		e := r.db.audit[i]
+		if f.UserID != 0 && (e.UserID == nil || *e.UserID != f.UserID) {
+			continue
+		}
+		if len(f.Types) > 0 && !slices.Contains(f.Types, e.Type) {
+			continue
+		}
+		if f.BeforeID != 0 && e.ID >= f.BeforeID {
+			continue
+		}
+		out = append(out, e)
+	}
+	return out, nil
+}
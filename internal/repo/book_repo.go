@@ -0,0 +1,210 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"slices"
+	"time"
+
+	"bookpulse/internal/utils"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+var ErrBookNotFound = errors.New("book not found")
+
+// Book is a catalogue entry, shared by every user who added it.
+type Book struct {
+	ID          int
+	GoogleID    string
+	Title       string
+	Author      string
+	CoverURL    string
+	Description string
+	// PublishedYear and PageCount are 0 when unknown.
+	PublishedYear int
+	PageCount     int
+	AgeRating     string
+	// Genres are added to those the book is already filed under.
+	Genres []string
+}
+
+// BookRepo looks up the catalogue. Books are written by LibraryRepo.Add,
+// together with the shelf entry of the user who added them.
+type BookRepo interface {
+	// IDByGoogleID returns ErrBookNotFound for books nobody has added.
+	IDByGoogleID(ctx context.Context, googleID string) (int, error)
+}
+
+type BookRepoPGX struct {
+	db *pgxpool.Pool
+}
+
+func NewBookRepoPGX(db *pgxpool.Pool) *BookRepoPGX {
+	return &BookRepoPGX{db: db}
+}
+
+func (r *BookRepoPGX) IDByGoogleID(ctx context.Context, googleID string) (int, error) {
+	var id int
+	err := r.db.QueryRow(ctx, `SELECT id FROM books WHERE google_id=$1`, googleID).Scan(&id)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return 0, ErrBookNotFound
+	}
+	return id, err
+}
+
+// saveBook stores b under its GoogleID, overwriting the details of a known
+// book, files it under b.Genres, creating missing genres, and returns its id.
+func saveBook(ctx context.Context, tx pgx.Tx, b Book) (int, error) {
+	var id int
+	err := tx.QueryRow(ctx, `
+		INSERT INTO books (google_id, title, author, cover_url, description, published_year, page_count, age_rating)
+		VALUES ($1,$2,$3,$4,$5,$6,$7,$8)
+		ON CONFLICT (google_id) DO UPDATE SET
+		  title = EXCLUDED.title,
+		  author = EXCLUDED.author,
+		  cover_url = EXCLUDED.cover_url,
+		  description = EXCLUDED.description,
+		  published_year = EXCLUDED.published_year,
+		  page_count = EXCLUDED.page_count,
+		  age_rating = EXCLUDED.age_rating
+		RETURNING id;
+	`,
+		b.GoogleID,
+		b.Title,
+		b.Author,
+		b.CoverURL,
+		b.Description,
+		utils.NullIfZero(b.PublishedYear),
+		utils.NullIfZero(b.PageCount),
+		b.AgeRating,
+	).Scan(&id)
+	if err != nil || len(b.Genres) == 0 {
+		return id, err
+	}
+
+	// one round trip for all genres; names must be unique, as ON CONFLICT
+	// DO UPDATE cannot touch a row twice
+	_, err = tx.Exec(ctx, `
+		WITH g AS (
+			INSERT INTO genres (name)
+			SELECT unnest($2::text[])
+			ON CONFLICT (name) DO UPDATE SET name = EXCLUDED.name
+			RETURNING id
+		)
+		INSERT INTO book_genres (book_id, genre_id)
+		SELECT $1, id FROM g
+		ON CONFLICT DO NOTHING;
+	`, id, b.Genres)
+	return id, err
+}
+
+type BookRepoSQLite struct {
+	db *sql.DB
+}
+
+func NewBookRepoSQLite(db *sql.DB) *BookRepoSQLite {
+	return &BookRepoSQLite{db: db}
+}
+
+func (r *BookRepoSQLite) IDByGoogleID(ctx context.Context, googleID string) (int, error) {
+	var id int
+	err := r.db.QueryRowContext(ctx, `SELECT id FROM books WHERE google_id = ?`, googleID).Scan(&id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, ErrBookNotFound
+	}
+	return id, err
+}
+
+func saveBookSQLite(ctx context.Context, tx *sql.Tx, b Book) (int, error) {
+	var id int
+	err := tx.QueryRowContext(ctx, `
+		INSERT INTO books (google_id, title, author, cover_url, description, published_year, page_count, age_rating, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (google_id) DO UPDATE SET
+		  title = excluded.title,
+		  author = excluded.author,
+		  cover_url = excluded.cover_url,
+		  description = excluded.description,
+		  published_year = excluded.published_year,
+		  page_count = excluded.page_count,
+		  age_rating = excluded.age_rating
+		RETURNING id;
+	`,
+		b.GoogleID,
+		b.Title,
+		b.Author,
+		b.CoverURL,
+		b.Description,
+		utils.NullIfZero(b.PublishedYear),
+		utils.NullIfZero(b.PageCount),
+		b.AgeRating,
+		sqliteNow(),
+	).Scan(&id)
+	if err != nil || len(b.Genres) == 0 {
+		return id, err
+	}
+
+	names, err := jsonText(b.Genres)
+	if err != nil {
+		return 0, err
+	}
+	// WHERE true keeps the parser from taking ON CONFLICT for a join
+	// constraint
+	if _, err := tx.ExecContext(ctx, `
+		INSERT INTO genres (name)
+		SELECT value FROM json_each(?) WHERE true
+		ON CONFLICT (name) DO NOTHING;
+	`, names); err != nil {
+		return 0, err
+	}
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO book_genres (book_id, genre_id)
+		SELECT ?, id FROM genres WHERE name IN (SELECT value FROM json_each(?))
+		ON CONFLICT DO NOTHING;
+	`, id, names)
+	return id, err
+}
+
+type BookMemory struct {
+	db *MemoryDB
+}
+
+func NewBookMemory(db *MemoryDB) *BookMemory {
+	return &BookMemory{db: db}
+}
+
+func (r *BookMemory) IDByGoogleID(ctx context.Context, googleID string) (int, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	if b := r.db.bookByGoogleID(googleID); b != nil {
+		return b.ID, nil
+	}
+	return 0, ErrBookNotFound
+}
+
+// saveBook is saveBook for the memory database; the caller holds mu.
+func (db *MemoryDB) saveBook(b Book) *memBook {
+	row := db.bookByGoogleID(b.GoogleID)
+	if row == nil {
+		db.lastBookID++
+		row = &memBook{ID: db.lastBookID, GoogleID: b.GoogleID, CreatedAt: time.Now()}
+		db.books[row.ID] = row
+	}
+	row.Title = b.Title
+	row.Author = b.Author
+	row.CoverURL = b.CoverURL
+	row.Description = b.Description
+	row.PublishedYear = b.PublishedYear
+	row.PageCount = b.PageCount
+	row.AgeRating = b.AgeRating
+	for _, g := range b.Genres {
+		if !slices.Contains(row.Genres, g) {
+			row.Genres = append(row.Genres, g)
+		}
+	}
+	return row
+}
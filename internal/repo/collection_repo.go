@@ -0,0 +1,435 @@
+package repo
+
+import (
+	"cmp"
+	"context"
+	"database/sql"
+	"errors"
+	"slices"
+	"time"
+
+	"bookpulse/internal/models"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+var (
+	ErrCollectionNotFound  = errors.New("collection not found")
+	ErrCollectionNameTaken = errors.New("a collection with this name already exists")
+	ErrNotInCollection     = errors.New("book not in collection")
+)
+
+// CollectionRepo keeps the named collections users sort their books into.
+// Every method is scoped to the owner; other users' collections do not
+// exist.
+type CollectionRepo interface {
+	// List returns the user's collections by name with their book counts.
+	List(ctx context.Context, userID int) ([]models.MyCollectionDTO, error)
+	// Upsert returns the id of the user's collection called name, creating
+	// it first if needed, and adds the books to it, in one transaction.
+	Upsert(ctx context.Context, userID int, name string, bookIDs []int) (int, error)
+	Exists(ctx context.Context, userID, collectionID int) (bool, error)
+	// AddBooks adds the books to the collection, all or none. Books already
+	// in it are skipped. Every book must be on the user's shelf.
+	AddBooks(ctx context.Context, userID, collectionID int, bookIDs []int) error
+	// Rename returns ErrCollectionNameTaken if the user has another
+	// collection called name.
+	Rename(ctx context.Context, userID, collectionID int, name string) error
+	// Delete removes the collection; its books stay on the shelf.
+	Delete(ctx context.Context, userID, collectionID int) error
+	// RemoveBook returns ErrNotInCollection if the book is not in the
+	// collection.
+	RemoveBook(ctx context.Context, userID, collectionID, bookID int) error
+}
+
+type CollectionRepoPGX struct {
+	db *pgxpool.Pool
+}
+
+func NewCollectionRepoPGX(db *pgxpool.Pool) *CollectionRepoPGX {
+	return &CollectionRepoPGX{db: db}
+}
+
+func (r *CollectionRepoPGX) List(ctx context.Context, userID int) ([]models.MyCollectionDTO, error) {
+	rows, err := r.db.Query(ctx, `
+		SELECT c.id, c.name, COUNT(cb.book_id) AS cnt
+		FROM collections c
+		LEFT JOIN collection_books cb
+		  ON cb.user_id = c.user_id AND cb.collection_id = c.id
+		WHERE c.user_id = $1
+		GROUP BY c.id, c.name
+		ORDER BY c.name;
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := make([]models.MyCollectionDTO, 0, 16)
+	for rows.Next() {
+		var dto models.MyCollectionDTO
+		if err := rows.Scan(&dto.ID, &dto.Name, &dto.Count); err != nil {
+			return nil, err
+		}
+		out = append(out, dto)
+	}
+	return out, rows.Err()
+}
+
+func (r *CollectionRepoPGX) Upsert(ctx context.Context, userID int, name string, bookIDs []int) (int, error) {
+	var id int
+	err := pgx.BeginFunc(ctx, r.db, func(tx pgx.Tx) error {
+		err := tx.QueryRow(ctx, `
+			INSERT INTO collections (user_id, name)
+			VALUES ($1,$2)
+			ON CONFLICT (user_id, name) DO UPDATE SET name = EXCLUDED.name
+			RETURNING id;
+		`, userID, name).Scan(&id)
+		if err != nil {
+			return err
+		}
+		return addCollectionBooks(ctx, tx, userID, id, bookIDs)
+	})
+	if err != nil {
+		return 0, err
+	}
+	return id, nil
+}
+
+func (r *CollectionRepoPGX) Exists(ctx context.Context, userID, collectionID int) (bool, error) {
+	var exists bool
+	err := r.db.QueryRow(ctx, `
+		SELECT EXISTS(SELECT 1 FROM collections WHERE id=$1 AND user_id=$2)
+	`, collectionID, userID).Scan(&exists)
+	return exists, err
+}
+
+func (r *CollectionRepoPGX) AddBooks(ctx context.Context, userID, collectionID int, bookIDs []int) error {
+	return pgx.BeginFunc(ctx, r.db, func(tx pgx.Tx) error {
+		return addCollectionBooks(ctx, tx, userID, collectionID, bookIDs)
+	})
+}
+
+func (r *CollectionRepoPGX) Rename(ctx context.Context, userID, collectionID int, name string) error {
+	cmd, err := r.db.Exec(ctx, `
+		UPDATE collections SET name=$3
+		WHERE id=$1 AND user_id=$2
+	`, collectionID, userID, name)
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+		return ErrCollectionNameTaken
+	}
+	if err != nil {
+		return err
+	}
+	if cmd.RowsAffected() == 0 {
+		return ErrCollectionNotFound
+	}
+	return nil
+}
+
+func (r *CollectionRepoPGX) Delete(ctx context.Context, userID, collectionID int) error {
+	cmd, err := r.db.Exec(ctx, `
+		DELETE FROM collections WHERE id=$1 AND user_id=$2
+	`, collectionID, userID)
+	if err != nil {
+		return err
+	}
+	if cmd.RowsAffected() == 0 {
+		return ErrCollectionNotFound
+	}
+	return nil
+}
+
+func (r *CollectionRepoPGX) RemoveBook(ctx context.Context, userID, collectionID, bookID int) error {
+	cmd, err := r.db.Exec(ctx, `
+		DELETE FROM collection_books
+		WHERE user_id=$1 AND collection_id=$2 AND book_id=$3
+	`, userID, collectionID, bookID)
+	if err != nil {
+		return err
+	}
+	if cmd.RowsAffected() == 0 {
+		return ErrNotInCollection
+	}
+	return nil
+}
+
+// addCollectionBooks inserts the books in one statement; the foreign key
+// to user_books rejects books that are not on the shelf.
+func addCollectionBooks(ctx context.Context, tx pgx.Tx, userID, collectionID int, bookIDs []int) error {
+	if len(bookIDs) == 0 {
+		return nil
+	}
+	_, err := tx.Exec(ctx, `
+		INSERT INTO collection_books (user_id, collection_id, book_id)
+		SELECT $1, $2, unnest($3::int[])
+		ON CONFLICT DO NOTHING
+	`, userID, collectionID, bookIDs)
+	return err
+}
+
+type CollectionRepoSQLite struct {
+	db *sql.DB
+}
+
+func NewCollectionRepoSQLite(db *sql.DB) *CollectionRepoSQLite {
+	return &CollectionRepoSQLite{db: db}
+}
+
+func (r *CollectionRepoSQLite) List(ctx context.Context, userID int) ([]models.MyCollectionDTO, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT c.id, c.name, COUNT(cb.book_id) AS cnt
+		FROM collections c
+		LEFT JOIN collection_books cb
+		  ON cb.user_id = c.user_id AND cb.collection_id = c.id
+		WHERE c.user_id = ?
+		GROUP BY c.id, c.name
+		ORDER BY c.name;
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := make([]models.MyCollectionDTO, 0, 16)
+	for rows.Next() {
+		var dto models.MyCollectionDTO
+		if err := rows.Scan(&dto.ID, &dto.Name, &dto.Count); err != nil {
+			return nil, err
+		}
+		out = append(out, dto)
+	}
+	return out, rows.Err()
+}
+
+func (r *CollectionRepoSQLite) Upsert(ctx context.Context, userID int, name string, bookIDs []int) (int, error) {
+	var id int
+	err := sqliteTx(ctx, r.db, func(tx *sql.Tx) error {
+		err := tx.QueryRowContext(ctx, `
+			INSERT INTO collections (user_id, name, created_at)
+			VALUES (?, ?, ?)
+			ON CONFLICT (user_id, name) DO UPDATE SET name = excluded.name
+			RETURNING id;
+		`, userID, name, sqliteNow()).Scan(&id)
+		if err != nil {
+			return err
+		}
+		return addCollectionBooksSQLite(ctx, tx, userID, id, bookIDs)
+	})
+	if err != nil {
+		return 0, err
+	}
+	return id, nil
+}
+
+func (r *CollectionRepoSQLite) Exists(ctx context.Context, userID, collectionID int) (bool, error) {
+	var exists bool
+	err := r.db.QueryRowContext(ctx, `
+		SELECT EXISTS(SELECT 1 FROM collections WHERE id = ? AND user_id = ?)
+	`, collectionID, userID).Scan(&exists)
+	return exists, err
+}
+
+func (r *CollectionRepoSQLite) AddBooks(ctx context.Context, userID, collectionID int, bookIDs []int) error {
+	return sqliteTx(ctx, r.db, func(tx *sql.Tx) error {
+		return addCollectionBooksSQLite(ctx, tx, userID, collectionID, bookIDs)
+	})
+}
+
+func (r *CollectionRepoSQLite) Rename(ctx context.Context, userID, collectionID int, name string) error {
+	ok, err := changed(r.db.ExecContext(ctx, `
+		UPDATE collections SET name = ?
+		WHERE id = ? AND user_id = ?
+	`, name, collectionID, userID))
+	if isSQLiteUniqueViolation(err) {
+		return ErrCollectionNameTaken
+	}
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrCollectionNotFound
+	}
+	return nil
+}
+
+func (r *CollectionRepoSQLite) Delete(ctx context.Context, userID, collectionID int) error {
+	ok, err := changed(r.db.ExecContext(ctx, `
+		DELETE FROM collections WHERE id = ? AND user_id = ?
+	`, collectionID, userID))
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrCollectionNotFound
+	}
+	return nil
+}
+
+func (r *CollectionRepoSQLite) RemoveBook(ctx context.Context, userID, collectionID, bookID int) error {
+	ok, err := changed(r.db.ExecContext(ctx, `
+		DELETE FROM collection_books
+		WHERE user_id = ? AND collection_id = ? AND book_id = ?
+	`, userID, collectionID, bookID))
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrNotInCollection
+	}
+	return nil
+}
+
+func addCollectionBooksSQLite(ctx context.Context, tx *sql.Tx, userID, collectionID int, bookIDs []int) error {
+	if len(bookIDs) == 0 {
+		return nil
+	}
+	ids, err := jsonText(bookIDs)
+	if err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO collection_books (user_id, collection_id, book_id, created_at)
+		SELECT ?, ?, value, ? FROM json_each(?) WHERE true
+		ON CONFLICT DO NOTHING
+	`, userID, collectionID, sqliteNow(), ids)
+	return err
+}
+
+type CollectionMemory struct {
+	db *MemoryDB
+}
+
+func NewCollectionMemory(db *MemoryDB) *CollectionMemory {
+	return &CollectionMemory{db: db}
+}
+
+func (r *CollectionMemory) List(ctx context.Context, userID int) ([]models.MyCollectionDTO, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	out := make([]models.MyCollectionDTO, 0, 16)
+	for _, c := range r.db.collections {
+		if c.UserID == userID {
+			out = append(out, models.MyCollectionDTO{ID: c.ID, Name: c.Name, Count: len(c.BookIDs)})
+		}
+	}
+	slices.SortFunc(out, func(a, b models.MyCollectionDTO) int { return cmp.Compare(a.Name, b.Name) })
+	return out, nil
+}
+
+func (r *CollectionMemory) Upsert(ctx context.Context, userID int, name string, bookIDs []int) (int, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	if err := r.checkShelved(userID, bookIDs); err != nil {
+		return 0, err
+	}
+	for _, c := range r.db.collections {
+		if c.UserID == userID && c.Name == name {
+			c.addBooks(bookIDs)
+			return c.ID, nil
+		}
+	}
+	r.db.lastCollectionID++
+	c := &memCollection{ID: r.db.lastCollectionID, UserID: userID, Name: name, CreatedAt: time.Now()}
+	c.addBooks(bookIDs)
+	r.db.collections[c.ID] = c
+	return c.ID, nil
+}
+
+func (r *CollectionMemory) Exists(ctx context.Context, userID, collectionID int) (bool, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	return r.db.collection(userID, collectionID) != nil, nil
+}
+
+func (r *CollectionMemory) AddBooks(ctx context.Context, userID, collectionID int, bookIDs []int) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	c := r.db.collection(userID, collectionID)
+	if c == nil {
+		return ErrCollectionNotFound
+	}
+	if err := r.checkShelved(userID, bookIDs); err != nil {
+		return err
+	}
+	c.addBooks(bookIDs)
+	return nil
+}
+
+func (r *CollectionMemory) Rename(ctx context.Context, userID, collectionID int, name string) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	c := r.db.collection(userID, collectionID)
+	if c == nil {
+		return ErrCollectionNotFound
+	}
+	for _, other := range r.db.collections {
+		if other.UserID == userID && other.Name == name && other.ID != c.ID {
+			return ErrCollectionNameTaken
+		}
+	}
+	c.Name = name
+	return nil
+}
+
+func (r *CollectionMemory) Delete(ctx context.Context, userID, collectionID int) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	if r.db.collection(userID, collectionID) == nil {
+		return ErrCollectionNotFound
+	}
+	delete(r.db.collections, collectionID)
+	return nil
+}
+
+func (r *CollectionMemory) RemoveBook(ctx context.Context, userID, collectionID, bookID int) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	c := r.db.collection(userID, collectionID)
+	if c == nil {
+		return ErrCollectionNotFound
+	}
+	if !c.removeBook(bookID) {
+		return ErrNotInCollection
+	}
+	return nil
+}
+
+// checkShelved stands in for the collection_books foreign key, before
+// anything is written.
+func (r *CollectionMemory) checkShelved(userID int, bookIDs []int) error {
+	for _, id := range bookIDs {
+		if r.db.shelf[shelfKey{userID, id}] == nil {
+			return ErrNotInLibrary
+		}
+	}
+	return nil
+}
+
+func (c *memCollection) addBooks(bookIDs []int) {
+	for _, id := range bookIDs {
+		if !slices.Contains(c.BookIDs, id) {
+			c.BookIDs = append(c.BookIDs, id)
+		}
+	}
+}
+
+// removeBook reports whether the book was in the collection.
+func (c *memCollection) removeBook(bookID int) bool {
+	i := slices.Index(c.BookIDs, bookID)
+	if i < 0 {
+		return false
+	}
+	c.BookIDs = slices.Delete(c.BookIDs, i, i+1)
+	return true
+}
@@ -0,0 +1,449 @@
+package repo
+
+import (
+	"cmp"
+	"context"
+	"database/sql"
+	"errors"
+	"slices"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// UserExport is everything stored about one user, for GDPR data export.
+type UserExport struct {
+	ExportedAt     time.Time          `json:"exportedAt"`
+	Profile        ExportProfile      `json:"profile"`
+	Library        []ExportBook       `json:"library"`
+	Collections    []ExportCollection `json:"collections"`
+	Reviews        []ExportReview     `json:"reviews"`
+	Sessions       []ExportSession    `json:"sessions"`
+	Identities     []Identity         `json:"identities"`
+	AccessTokens   []AccessToken      `json:"accessTokens"`
+	SecurityEvents []AuditEvent       `json:"securityEvents"`
+}
+
+type ExportProfile struct {
+	ID                  int        `json:"id"`
+	Email               string     `json:"email"`
+	Name                string     `json:"name"`
+	Role                string     `json:"role"`
+	CreatedAt           time.Time  `json:"createdAt"`
+	EmailVerifiedAt     *time.Time `json:"emailVerifiedAt"`
+	TwoFactorEnabledAt  *time.Time `json:"twoFactorEnabledAt"`
+	SuspendedAt         *time.Time `json:"suspendedAt"`
+	DeletionRequestedAt *time.Time `json:"deletionRequestedAt"`
+}
+
+type ExportBook struct {
+	GoogleID string    `json:"googleId"`
+	Title    string    `json:"title"`
+	Author   string    `json:"author"`
+	Status   string    `json:"status"`
+	AddedAt  time.Time `json:"addedAt"`
+}
+
+type ExportCollection struct {
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"createdAt"`
+	Books     []string  `json:"books"`
+}
+
+type ExportReview struct {
+	GoogleID  string    `json:"googleId"`
+	Title     string    `json:"title"`
+	Rating    int       `json:"rating"`
+	Text      string    `json:"text"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+type ExportSession struct {
+	UserAgent  string     `json:"userAgent"`
+	IP         string     `json:"ip"`
+	CreatedAt  time.Time  `json:"createdAt"`
+	LastSeenAt time.Time  `json:"lastSeenAt"`
+	RevokedAt  *time.Time `json:"revokedAt"`
+}
+
+// ExportRepo gathers a user's data for export.
+type ExportRepo interface {
+	Collect(ctx context.Context, userID int) (*UserExport, error)
+}
+
+type ExportRepoPGX struct {
+	db *pgxpool.Pool
+}
+
+func NewExportRepoPGX(db *pgxpool.Pool) *ExportRepoPGX {
+	return &ExportRepoPGX{db: db}
+}
+
+// Collect reads the user's data in one repeatable-read snapshot. It returns
+// nil if the user does not exist.
+func (r *ExportRepoPGX) Collect(ctx context.Context, userID int) (*UserExport, error) {
+	out := &UserExport{ExportedAt: time.Now().UTC()}
+	found := true
+
+	err := pgx.BeginTxFunc(ctx, r.db, pgx.TxOptions{IsoLevel: pgx.RepeatableRead, AccessMode: pgx.ReadOnly}, func(tx pgx.Tx) error {
+		p := &out.Profile
+		err := tx.QueryRow(ctx, `
+			SELECT id, email, name, role, created_at, email_verified_at, totp_enabled_at,
+			       suspended_at, deletion_requested_at
+			FROM users WHERE id = $1;
+		`, userID).Scan(&p.ID, &p.Email, &p.Name, &p.Role, &p.CreatedAt, &p.EmailVerifiedAt,
+			&p.TwoFactorEnabledAt, &p.SuspendedAt, &p.DeletionRequestedAt)
+		if errors.Is(err, pgx.ErrNoRows) {
+			found = false
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		if out.Library, err = collectRows(ctx, tx, `
+			SELECT b.google_id, b.title, b.author, ub.status, ub.created_at
+			FROM user_books ub JOIN books b ON b.id = ub.book_id
+			WHERE ub.user_id = $1
+			ORDER BY ub.created_at;
+		`, userID, func(row pgx.Rows, b *ExportBook) error {
+			return row.Scan(&b.GoogleID, &b.Title, &b.Author, &b.Status, &b.AddedAt)
+		}); err != nil {
+			return err
+		}
+
+		if out.Collections, err = collectRows(ctx, tx, `
+			SELECT c.name, c.created_at,
+			       COALESCE(array_agg(b.google_id ORDER BY b.google_id) FILTER (WHERE b.id IS NOT NULL), '{}')
+			FROM collections c
+			LEFT JOIN collection_books cb ON cb.collection_id = c.id
+			LEFT JOIN books b ON b.id = cb.book_id
+			WHERE c.user_id = $1
+			GROUP BY c.id
+			ORDER BY c.name;
+		`, userID, func(row pgx.Rows, c *ExportCollection) error {
+			return row.Scan(&c.Name, &c.CreatedAt, &c.Books)
+		}); err != nil {
+			return err
+		}
+
+		if out.Reviews, err = collectRows(ctx, tx, `
+			SELECT b.google_id, b.title, r.rating, r.text, r.created_at
+			FROM reviews r JOIN books b ON b.id = r.book_id
+			WHERE r.user_id = $1
+			ORDER BY r.created_at;
+		`, userID, func(row pgx.Rows, rv *ExportReview) error {
+			return row.Scan(&rv.GoogleID, &rv.Title, &rv.Rating, &rv.Text, &rv.CreatedAt)
+		}); err != nil {
+			return err
+		}
+
+		if out.Sessions, err = collectRows(ctx, tx, `
+			SELECT user_agent, ip, created_at, last_seen_at, revoked_at
+			FROM sessions WHERE user_id = $1
+			ORDER BY created_at;
+		`, userID, func(row pgx.Rows, s *ExportSession) error {
+			return row.Scan(&s.UserAgent, &s.IP, &s.CreatedAt, &s.LastSeenAt, &s.RevokedAt)
+		}); err != nil {
+			return err
+		}
+
+		if out.Identities, err = collectRows(ctx, tx, `
+			SELECT provider, email, created_at FROM user_identities
+			WHERE user_id = $1
+			ORDER BY provider;
+		`, userID, func(row pgx.Rows, i *Identity) error {
+			return row.Scan(&i.Provider, &i.Email, &i.CreatedAt)
+		}); err != nil {
+			return err
+		}
+
+		if out.AccessTokens, err = collectRows(ctx, tx, `
+			SELECT `+accessTokenColumns+` FROM personal_access_tokens
+			WHERE user_id = $1
+			ORDER BY created_at;
+		`, userID, func(row pgx.Rows, t *AccessToken) error {
+			return row.Scan(&t.ID, &t.UserID, &t.Name, &t.Scopes, &t.CreatedAt, &t.LastUsedAt, &t.ExpiresAt)
+		}); err != nil {
+			return err
+		}
+
+		out.SecurityEvents, err = collectRows(ctx, tx, `
+			SELECT id, event_type, actor_id, user_id, ip, user_agent, details, created_at
+			FROM audit_events WHERE user_id = $1
+			ORDER BY created_at;
+		`, userID, func(row pgx.Rows, e *AuditEvent) error {
+			return row.Scan(&e.ID, &e.Type, &e.ActorID, &e.UserID, &e.IP, &e.UserAgent, &e.Details, &e.CreatedAt)
+		})
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, nil
+	}
+	return out, nil
+}
+
+func collectRows[T any](ctx context.Context, tx pgx.Tx, sql string, userID int, scan func(pgx.Rows, *T) error) ([]T, error) {
+	rows, err := tx.Query(ctx, sql, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := make([]T, 0, 8)
+	for rows.Next() {
+		var v T
+		if err := scan(rows, &v); err != nil {
+			return nil, err
+		}
+		out = append(out, v)
+	}
+	return out, rows.Err()
+}
+
+type ExportRepoSQLite struct {
+	db *sql.DB
+}
+
+func NewExportRepoSQLite(db *sql.DB) *ExportRepoSQLite {
+	return &ExportRepoSQLite{db: db}
+}
+
+// Collect reads the user's data in one transaction. It returns nil if the
+// user does not exist.
+func (r *ExportRepoSQLite) Collect(ctx context.Context, userID int) (*UserExport, error) {
+	out := &UserExport{ExportedAt: time.Now().UTC()}
+	found := true
+
+	err := sqliteTx(ctx, r.db, func(tx *sql.Tx) error {
+		p := &out.Profile
+		err := tx.QueryRowContext(ctx, `
+			SELECT id, email, name, role, created_at, email_verified_at, totp_enabled_at,
+			       suspended_at, deletion_requested_at
+			FROM users WHERE id = ?;
+		`, userID).Scan(&p.ID, &p.Email, &p.Name, &p.Role, &p.CreatedAt, &p.EmailVerifiedAt,
+			&p.TwoFactorEnabledAt, &p.SuspendedAt, &p.DeletionRequestedAt)
+		if errors.Is(err, sql.ErrNoRows) {
+			found = false
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		if out.Library, err = collectRowsSQLite(ctx, tx, `
+			SELECT b.google_id, b.title, b.author, ub.status, ub.created_at
+			FROM user_books ub JOIN books b ON b.id = ub.book_id
+			WHERE ub.user_id = ?
+			ORDER BY ub.created_at;
+		`, userID, func(row *sql.Rows, b *ExportBook) error {
+			return row.Scan(&b.GoogleID, &b.Title, &b.Author, &b.Status, &b.AddedAt)
+		}); err != nil {
+			return err
+		}
+
+		if out.Collections, err = collectRowsSQLite(ctx, tx, `
+			SELECT c.name, c.created_at,
+			       json_group_array(b.google_id ORDER BY b.google_id) FILTER (WHERE b.id IS NOT NULL)
+			FROM collections c
+			LEFT JOIN collection_books cb ON cb.collection_id = c.id
+			LEFT JOIN books b ON b.id = cb.book_id
+			WHERE c.user_id = ?
+			GROUP BY c.id
+			ORDER BY c.name;
+		`, userID, func(row *sql.Rows, c *ExportCollection) error {
+			return row.Scan(&c.Name, &c.CreatedAt, jsonColumn{&c.Books})
+		}); err != nil {
+			return err
+		}
+
+		if out.Reviews, err = collectRowsSQLite(ctx, tx, `
+			SELECT b.google_id, b.title, r.rating, r.text, r.created_at
+			FROM reviews r JOIN books b ON b.id = r.book_id
+			WHERE r.user_id = ?
+			ORDER BY r.created_at;
+		`, userID, func(row *sql.Rows, rv *ExportReview) error {
+			return row.Scan(&rv.GoogleID, &rv.Title, &rv.Rating, &rv.Text, &rv.CreatedAt)
+		}); err != nil {
+			return err
+		}
+
+		if out.Sessions, err = collectRowsSQLite(ctx, tx, `
+			SELECT user_agent, ip, created_at, last_seen_at, revoked_at
+			FROM sessions WHERE user_id = ?
+			ORDER BY created_at;
+		`, userID, func(row *sql.Rows, s *ExportSession) error {
+			return row.Scan(&s.UserAgent, &s.IP, &s.CreatedAt, &s.LastSeenAt, &s.RevokedAt)
+		}); err != nil {
+			return err
+		}
+
+		if out.Identities, err = collectRowsSQLite(ctx, tx, `
+			SELECT provider, email, created_at FROM user_identities
+			WHERE user_id = ?
+			ORDER BY provider;
+		`, userID, func(row *sql.Rows, i *Identity) error {
+			return row.Scan(&i.Provider, &i.Email, &i.CreatedAt)
+		}); err != nil {
+			return err
+		}
+
+		if out.AccessTokens, err = collectRowsSQLite(ctx, tx, `
+			SELECT `+accessTokenColumns+` FROM personal_access_tokens
+			WHERE user_id = ?
+			ORDER BY created_at;
+		`, userID, func(row *sql.Rows, t *AccessToken) error {
+			return row.Scan(&t.ID, &t.UserID, &t.Name, jsonColumn{&t.Scopes}, &t.CreatedAt, &t.LastUsedAt, &t.ExpiresAt)
+		}); err != nil {
+			return err
+		}
+
+		out.SecurityEvents, err = collectRowsSQLite(ctx, tx, `
+			SELECT id, event_type, actor_id, user_id, ip, user_agent, details, created_at
+			FROM audit_events WHERE user_id = ?
+			ORDER BY created_at;
+		`, userID, func(row *sql.Rows, e *AuditEvent) error {
+			return row.Scan(&e.ID, &e.Type, &e.ActorID, &e.UserID, &e.IP, &e.UserAgent, jsonColumn{&e.Details}, &e.CreatedAt)
+		})
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, nil
+	}
+	return out, nil
+}
+
+func collectRowsSQLite[T any](ctx context.Context, tx *sql.Tx, query string, userID int, scan func(*sql.Rows, *T) error) ([]T, error) {
+	rows, err := tx.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := make([]T, 0, 8)
+	for rows.Next() {
+		var v T
+		if err := scan(rows, &v); err != nil {
+			return nil, err
+		}
+		out = append(out, v)
+	}
+	return out, rows.Err()
+}
+
+type ExportMemory struct {
+	db *MemoryDB
+}
+
+func NewExportMemory(db *MemoryDB) *ExportMemory {
+	return &ExportMemory{db: db}
+}
+
+func (r *ExportMemory) Collect(ctx context.Context, userID int) (*UserExport, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	u := r.db.users[userID]
+	if u == nil {
+		return nil, nil
+	}
+	out := &UserExport{
+		ExportedAt: time.Now().UTC(),
+		Profile: ExportProfile{
+			ID:                  u.ID,
+			Email:               u.Email,
+			Name:                u.Name,
+			Role:                u.Role,
+			CreatedAt:           u.CreatedAt,
+			EmailVerifiedAt:     u.EmailVerifiedAt,
+			TwoFactorEnabledAt:  u.TOTPEnabledAt,
+			SuspendedAt:         u.SuspendedAt,
+			DeletionRequestedAt: u.DeletionRequestedAt,
+		},
+		Library:        make([]ExportBook, 0, 8),
+		Collections:    make([]ExportCollection, 0, 8),
+		Reviews:        make([]ExportReview, 0, 8),
+		Sessions:       make([]ExportSession, 0, 8),
+		Identities:     r.db.identitiesOf(userID),
+		AccessTokens:   make([]AccessToken, 0, 8),
+		SecurityEvents: make([]AuditEvent, 0, 8),
+	}
+
+	for k, e := range r.db.shelf {
+		if k.userID != userID {
+			continue
+		}
+		b := r.db.books[k.bookID]
+		out.Library = append(out.Library, ExportBook{
+			GoogleID: b.GoogleID,
+			Title:    b.Title,
+			Author:   b.Author,
+			Status:   e.Status,
+			AddedAt:  e.CreatedAt,
+		})
+	}
+	slices.SortFunc(out.Library, func(a, b ExportBook) int { return a.AddedAt.Compare(b.AddedAt) })
+
+	for _, c := range r.db.collections {
+		if c.UserID != userID {
+			continue
+		}
+		books := make([]string, 0, len(c.BookIDs))
+		for _, id := range c.BookIDs {
+			books = append(books, r.db.books[id].GoogleID)
+		}
+		slices.Sort(books)
+		out.Collections = append(out.Collections, ExportCollection{Name: c.Name, CreatedAt: c.CreatedAt, Books: books})
+	}
+	slices.SortFunc(out.Collections, func(a, b ExportCollection) int { return cmp.Compare(a.Name, b.Name) })
+
+	for _, rv := range r.db.reviews {
+		if rv.UserID != userID {
+			continue
+		}
+		b := r.db.books[rv.BookID]
+		out.Reviews = append(out.Reviews, ExportReview{
+			GoogleID:  b.GoogleID,
+			Title:     b.Title,
+			Rating:    rv.Rating,
+			Text:      rv.Text,
+			CreatedAt: rv.CreatedAt,
+		})
+	}
+	slices.SortFunc(out.Reviews, func(a, b ExportReview) int { return a.CreatedAt.Compare(b.CreatedAt) })
+
+	for _, s := range r.db.sessions {
+		if s.UserID != userID {
+			continue
+		}
+		out.Sessions = append(out.Sessions, ExportSession{
+			UserAgent:  s.UserAgent,
+			IP:         s.IP,
+			CreatedAt:  s.CreatedAt,
+			LastSeenAt: s.LastSeenAt,
+			RevokedAt:  s.RevokedAt,
+		})
+	}
+	slices.SortFunc(out.Sessions, func(a, b ExportSession) int { return a.CreatedAt.Compare(b.CreatedAt) })
+
+	for _, t := range r.db.accessTokens {
+		if t.UserID == userID {
+			out.AccessTokens = append(out.AccessTokens, t.AccessToken)
+		}
+	}
+	slices.SortFunc(out.AccessTokens, func(a, b AccessToken) int { return a.CreatedAt.Compare(b.CreatedAt) })
+
+	for _, e := range r.db.audit {
+		if e.UserID != nil && *e.UserID == userID {
+			out.SecurityEvents = append(out.SecurityEvents, e)
+		}
+	}
+	return out, nil
+}
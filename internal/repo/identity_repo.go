@@ -0,0 +1,339 @@
+package repo
+
+import (
+	"cmp"
+	"context"
+	"database/sql"
+	"errors"
+	"slices"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+var (
+	ErrIdentityTaken    = errors.New("identity already linked to another account")
+	ErrIdentityNotFound = errors.New("identity not found")
+	ErrOIDCStateInvalid = errors.New("login state invalid or expired")
+)
+
+type Identity struct {
+	Provider  string    `json:"provider"`
+	Email     string    `json:"email"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// OIDCState is what we remember between redirecting to the provider and the
+// callback. LinkUserID is set when an existing account is linking.
+type OIDCState struct {
+	Provider     string
+	CodeVerifier string
+	Nonce        string
+	LinkUserID   *int
+}
+
+// IdentityRepo links accounts to external (OIDC) identities and keeps the
+// short-lived login states of the redirect flow.
+type IdentityRepo interface {
+	FindUserID(ctx context.Context, provider, subject string) (int, error)
+	Link(ctx context.Context, userID int, provider, subject, email string) error
+	Unlink(ctx context.Context, userID int, provider string) error
+	ListForUser(ctx context.Context, userID int) ([]Identity, error)
+	SaveState(ctx context.Context, stateHash string, st OIDCState, expiresAt time.Time) error
+	ConsumeState(ctx context.Context, stateHash string) (*OIDCState, error)
+}
+
+type IdentityRepoPGX struct {
+	db *pgxpool.Pool
+}
+
+func NewIdentityRepoPGX(db *pgxpool.Pool) *IdentityRepoPGX {
+	return &IdentityRepoPGX{db: db}
+}
+
+// FindUserID returns the user linked to provider/subject, or 0.
+func (r *IdentityRepoPGX) FindUserID(ctx context.Context, provider, subject string) (int, error) {
+	var userID int
+	err := r.db.QueryRow(ctx, `
+		SELECT user_id FROM user_identities WHERE provider = $1 AND subject = $2;
+	`, provider, subject).Scan(&userID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return 0, nil
+		}
+		return 0, err
+	}
+	return userID, nil
+}
+
+func (r *IdentityRepoPGX) Link(ctx context.Context, userID int, provider, subject, email string) error {
+	_, err := r.db.Exec(ctx, `
+		INSERT INTO user_identities (user_id, provider, subject, email)
+		VALUES ($1, $2, $3, $4);
+	`, userID, provider, subject, email)
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+		return ErrIdentityTaken
+	}
+	return err
+}
+
+func (r *IdentityRepoPGX) Unlink(ctx context.Context, userID int, provider string) error {
+	cmd, err := r.db.Exec(ctx, `
+		DELETE FROM user_identities WHERE user_id = $1 AND provider = $2;
+	`, userID, provider)
+	if err != nil {
+		return err
+	}
+	if cmd.RowsAffected() == 0 {
+		return ErrIdentityNotFound
+	}
+	return nil
+}
+
+func (r *IdentityRepoPGX) ListForUser(ctx context.Context, userID int) ([]Identity, error) {
+	rows, err := r.db.Query(ctx, `
+		SELECT provider, email, created_at FROM user_identities
+		WHERE user_id = $1
+		ORDER BY provider;
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := make([]Identity, 0, 2)
+	for rows.Next() {
+		var i Identity
+		if err := rows.Scan(&i.Provider, &i.Email, &i.CreatedAt); err != nil {
+			return nil, err
+		}
+		out = append(out, i)
+	}
+	return out, rows.Err()
+}
+
+func (r *IdentityRepoPGX) SaveState(ctx context.Context, stateHash string, st OIDCState, expiresAt time.Time) error {
+	_, err := r.db.Exec(ctx, `
+		INSERT INTO oidc_login_states (state_hash, provider, code_verifier, nonce, link_user_id, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6);
+	`, stateHash, st.Provider, st.CodeVerifier, st.Nonce, st.LinkUserID, expiresAt)
+	if err != nil {
+		return err
+	}
+	_, _ = r.db.Exec(ctx, `DELETE FROM oidc_login_states WHERE expires_at < now()`)
+	return nil
+}
+
+// ConsumeState returns and deletes the state, so each callback works once.
+func (r *IdentityRepoPGX) ConsumeState(ctx context.Context, stateHash string) (*OIDCState, error) {
+	var st OIDCState
+	err := r.db.QueryRow(ctx, `
+		DELETE FROM oidc_login_states
+		WHERE state_hash = $1 AND expires_at > now()
+		RETURNING provider, code_verifier, nonce, link_user_id;
+	`, stateHash).Scan(&st.Provider, &st.CodeVerifier, &st.Nonce, &st.LinkUserID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrOIDCStateInvalid
+		}
+		return nil, err
+	}
+	return &st, nil
+}
+
+type IdentityRepoSQLite struct {
+	db *sql.DB
+}
+
+func NewIdentityRepoSQLite(db *sql.DB) *IdentityRepoSQLite {
+	return &IdentityRepoSQLite{db: db}
+}
+
+func (r *IdentityRepoSQLite) FindUserID(ctx context.Context, provider, subject string) (int, error) {
+	var userID int
+	err := r.db.QueryRowContext(ctx, `
+		SELECT user_id FROM user_identities WHERE provider = ? AND subject = ?;
+	`, provider, subject).Scan(&userID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, nil
+		}
+		return 0, err
+	}
+	return userID, nil
+}
+
+func (r *IdentityRepoSQLite) Link(ctx context.Context, userID int, provider, subject, email string) error {
+	_, err := r.db.ExecContext(ctx, `
+		INSERT INTO user_identities (user_id, provider, subject, email, created_at)
+		VALUES (?, ?, ?, ?, ?);
+	`, userID, provider, subject, email, sqliteNow())
+	if isSQLiteUniqueViolation(err) {
+		return ErrIdentityTaken
+	}
+	return err
+}
+
+func (r *IdentityRepoSQLite) Unlink(ctx context.Context, userID int, provider string) error {
+	ok, err := changed(r.db.ExecContext(ctx, `
+		DELETE FROM user_identities WHERE user_id = ? AND provider = ?;
+	`, userID, provider))
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrIdentityNotFound
+	}
+	return nil
+}
+
+func (r *IdentityRepoSQLite) ListForUser(ctx context.Context, userID int) ([]Identity, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT provider, email, created_at FROM user_identities
+		WHERE user_id = ?
+		ORDER BY provider;
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := make([]Identity, 0, 2)
+	for rows.Next() {
+		var i Identity
+		if err := rows.Scan(&i.Provider, &i.Email, &i.CreatedAt); err != nil {
+			return nil, err
+		}
+		out = append(out, i)
+	}
+	return out, rows.Err()
+}
+
+func (r *IdentityRepoSQLite) SaveState(ctx context.Context, stateHash string, st OIDCState, expiresAt time.Time) error {
+	now := sqliteNow()
+	_, err := r.db.ExecContext(ctx, `
+		INSERT INTO oidc_login_states (state_hash, provider, code_verifier, nonce, link_user_id, created_at, expires_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?);
+	`, stateHash, st.Provider, st.CodeVerifier, st.Nonce, st.LinkUserID, now, expiresAt.UTC())
+	if err != nil {
+		return err
+	}
+	_, _ = r.db.ExecContext(ctx, `DELETE FROM oidc_login_states WHERE expires_at < ?`, now)
+	return nil
+}
+
+func (r *IdentityRepoSQLite) ConsumeState(ctx context.Context, stateHash string) (*OIDCState, error) {
+	var st OIDCState
+	err := r.db.QueryRowContext(ctx, `
+		DELETE FROM oidc_login_states
+		WHERE state_hash = ? AND expires_at > ?
+		RETURNING provider, code_verifier, nonce, link_user_id;
+	`, stateHash, sqliteNow()).Scan(&st.Provider, &st.CodeVerifier, &st.Nonce, &st.LinkUserID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrOIDCStateInvalid
+		}
+		return nil, err
+	}
+	return &st, nil
+}
+
+type IdentityMemory struct {
+	db *MemoryDB
+}
+
+func NewIdentityMemory(db *MemoryDB) *IdentityMemory {
+	return &IdentityMemory{db: db}
+}
+
+func (r *IdentityMemory) FindUserID(ctx context.Context, provider, subject string) (int, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	for _, i := range r.db.identities {
+		if i.Provider == provider && i.Subject == subject {
+			return i.UserID, nil
+		}
+	}
+	return 0, nil
+}
+
+func (r *IdentityMemory) Link(ctx context.Context, userID int, provider, subject, email string) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	for _, i := range r.db.identities {
+		if i.Provider == provider && (i.Subject == subject || i.UserID == userID) {
+			return ErrIdentityTaken
+		}
+	}
+	r.db.identities = append(r.db.identities, &memIdentity{
+		UserID:    userID,
+		Provider:  provider,
+		Subject:   subject,
+		Email:     email,
+		CreatedAt: time.Now(),
+	})
+	return nil
+}
+
+func (r *IdentityMemory) Unlink(ctx context.Context, userID int, provider string) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	n := len(r.db.identities)
+	r.db.identities = slices.DeleteFunc(r.db.identities, func(i *memIdentity) bool {
+		return i.UserID == userID && i.Provider == provider
+	})
+	if len(r.db.identities) == n {
+		return ErrIdentityNotFound
+	}
+	return nil
+}
+
+func (r *IdentityMemory) ListForUser(ctx context.Context, userID int) ([]Identity, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	return r.db.identitiesOf(userID), nil
+}
+
+func (db *MemoryDB) identitiesOf(userID int) []Identity {
+	out := make([]Identity, 0, 2)
+	for _, i := range db.identities {
+		if i.UserID == userID {
+			out = append(out, Identity{Provider: i.Provider, Email: i.Email, CreatedAt: i.CreatedAt})
+		}
+	}
+	slices.SortFunc(out, func(a, b Identity) int { return cmp.Compare(a.Provider, b.Provider) })
+	return out
+}
+
+func (r *IdentityMemory) SaveState(ctx context.Context, stateHash string, st OIDCState, expiresAt time.Time) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	now := time.Now()
+	for hash, old := range r.db.oidcStates {
+		if old.ExpiresAt.Before(now) {
+			delete(r.db.oidcStates, hash)
+		}
+	}
+	r.db.oidcStates[stateHash] = &memOIDCState{OIDCState: st, ExpiresAt: expiresAt}
+	return nil
+}
+
+func (r *IdentityMemory) ConsumeState(ctx context.Context, stateHash string) (*OIDCState, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	st := r.db.oidcStates[stateHash]
+	if st == nil || !st.ExpiresAt.After(time.Now()) {
+		return nil, ErrOIDCStateInvalid
+	}
+	delete(r.db.oidcStates, stateHash)
+	return &st.OIDCState, nil
+}
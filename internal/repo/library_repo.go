@@ -0,0 +1,358 @@
+package repo
+
+import (
+	"cmp"
+	"context"
+	"database/sql"
+	"errors"
+	"slices"
+	"time"
+
+	"bookpulse/internal/models"
+	"bookpulse/internal/utils"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+var ErrNotInLibrary = errors.New("book not in library")
+
+// LibraryRepo keeps each user's shelf: the books they added and their
+// reading status.
+type LibraryRepo interface {
+	// List returns the user's books by title, each with the names of the
+	// collections it is in.
+	List(ctx context.Context, userID int) ([]models.MyBookDTO, error)
+	// Add saves b to the catalogue and puts it on the user's shelf with
+	// status, or sets its status if it is already there, in one
+	// transaction. It returns the book id.
+	Add(ctx context.Context, userID int, b Book, status string) (int, error)
+	// SetStatus returns ErrNotInLibrary if the user has not added the book.
+	SetStatus(ctx context.Context, userID, bookID int, status string) error
+	// Remove takes the book off the user's shelf and out of their
+	// collections, and deletes their review of it unless keepReview is set.
+	// It returns ErrNotInLibrary if the user has not added the book.
+	Remove(ctx context.Context, userID, bookID int, keepReview bool) error
+	Contains(ctx context.Context, userID, bookID int) (bool, error)
+}
+
+type LibraryRepoPGX struct {
+	db *pgxpool.Pool
+}
+
+func NewLibraryRepoPGX(db *pgxpool.Pool) *LibraryRepoPGX {
+	return &LibraryRepoPGX{db: db}
+}
+
+func (r *LibraryRepoPGX) List(ctx context.Context, userID int) ([]models.MyBookDTO, error) {
+	rows, err := r.db.Query(ctx, `
+		SELECT
+		b.id,
+		b.google_id,
+		b.title,
+		b.author,
+		b.cover_url,
+		ub.status,
+		COALESCE(string_agg(c.name, ',' ORDER BY c.name), '') AS collections_csv
+		FROM user_books ub
+		JOIN books b ON b.id = ub.book_id
+		LEFT JOIN collection_books cb
+		  ON cb.user_id = ub.user_id AND cb.book_id = ub.book_id
+		LEFT JOIN collections c
+		  ON c.id = cb.collection_id AND c.user_id = cb.user_id
+		WHERE ub.user_id = $1
+		GROUP BY b.id, b.title, b.author, ub.status
+		ORDER BY b.title;
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := make([]models.MyBookDTO, 0, 16)
+	for rows.Next() {
+		var dto models.MyBookDTO
+		var collectionsCSV string
+		if err := rows.Scan(&dto.BookID, &dto.GoogleID, &dto.Title, &dto.Author, &dto.CoverURL, &dto.Status, &collectionsCSV); err != nil {
+			return nil, err
+		}
+		dto.Collections = utils.SplitCSV(collectionsCSV)
+		out = append(out, dto)
+	}
+	return out, rows.Err()
+}
+
+func (r *LibraryRepoPGX) Add(ctx context.Context, userID int, b Book, status string) (int, error) {
+	var bookID int
+	err := pgx.BeginFunc(ctx, r.db, func(tx pgx.Tx) error {
+		var err error
+		if bookID, err = saveBook(ctx, tx, b); err != nil {
+			return err
+		}
+		_, err = tx.Exec(ctx, `
+			INSERT INTO user_books (user_id, book_id, status)
+			VALUES ($1,$2,$3)
+			ON CONFLICT (user_id, book_id) DO UPDATE SET status = EXCLUDED.status;
+		`, userID, bookID, status)
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+	return bookID, nil
+}
+
+func (r *LibraryRepoPGX) SetStatus(ctx context.Context, userID, bookID int, status string) error {
+	cmd, err := r.db.Exec(ctx, `
+		UPDATE user_books SET status=$3
+		WHERE user_id=$1 AND book_id=$2
+	`, userID, bookID, status)
+	if err != nil {
+		return err
+	}
+	if cmd.RowsAffected() == 0 {
+		return ErrNotInLibrary
+	}
+	return nil
+}
+
+// Remove relies on ON DELETE CASCADE to clear collection_books.
+func (r *LibraryRepoPGX) Remove(ctx context.Context, userID, bookID int, keepReview bool) error {
+	return pgx.BeginFunc(ctx, r.db, func(tx pgx.Tx) error {
+		cmd, err := tx.Exec(ctx, `
+			DELETE FROM user_books WHERE user_id=$1 AND book_id=$2
+		`, userID, bookID)
+		if err != nil {
+			return err
+		}
+		if cmd.RowsAffected() == 0 {
+			return ErrNotInLibrary
+		}
+		if keepReview {
+			return nil
+		}
+		_, err = tx.Exec(ctx, `DELETE FROM reviews WHERE user_id=$1 AND book_id=$2`, userID, bookID)
+		return err
+	})
+}
+
+func (r *LibraryRepoPGX) Contains(ctx context.Context, userID, bookID int) (bool, error) {
+	var in bool
+	err := r.db.QueryRow(ctx, `
+		SELECT EXISTS(SELECT 1 FROM user_books WHERE user_id=$1 AND book_id=$2)
+	`, userID, bookID).Scan(&in)
+	return in, err
+}
+
+type LibraryRepoSQLite struct {
+	db *sql.DB
+}
+
+func NewLibraryRepoSQLite(db *sql.DB) *LibraryRepoSQLite {
+	return &LibraryRepoSQLite{db: db}
+}
+
+// List is LibraryRepoPGX.List with group_concat in place of string_agg.
+func (r *LibraryRepoSQLite) List(ctx context.Context, userID int) ([]models.MyBookDTO, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT
+		b.id,
+		b.google_id,
+		b.title,
+		b.author,
+		b.cover_url,
+		ub.status,
+		COALESCE(group_concat(c.name, ',' ORDER BY c.name), '') AS collections_csv
+		FROM user_books ub
+		JOIN books b ON b.id = ub.book_id
+		LEFT JOIN collection_books cb
+		  ON cb.user_id = ub.user_id AND cb.book_id = ub.book_id
+		LEFT JOIN collections c
+		  ON c.id = cb.collection_id AND c.user_id = cb.user_id
+		WHERE ub.user_id = ?
+		GROUP BY b.id, b.title, b.author, ub.status
+		ORDER BY b.title;
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := make([]models.MyBookDTO, 0, 16)
+	for rows.Next() {
+		var dto models.MyBookDTO
+		var collectionsCSV string
+		if err := rows.Scan(&dto.BookID, &dto.GoogleID, &dto.Title, &dto.Author, &dto.CoverURL, &dto.Status, &collectionsCSV); err != nil {
+			return nil, err
+		}
+		dto.Collections = utils.SplitCSV(collectionsCSV)
+		out = append(out, dto)
+	}
+	return out, rows.Err()
+}
+
+func (r *LibraryRepoSQLite) Add(ctx context.Context, userID int, b Book, status string) (int, error) {
+	var bookID int
+	err := sqliteTx(ctx, r.db, func(tx *sql.Tx) error {
+		var err error
+		if bookID, err = saveBookSQLite(ctx, tx, b); err != nil {
+			return err
+		}
+		_, err = tx.ExecContext(ctx, `
+			INSERT INTO user_books (user_id, book_id, status, created_at)
+			VALUES (?, ?, ?, ?)
+			ON CONFLICT (user_id, book_id) DO UPDATE SET status = excluded.status;
+		`, userID, bookID, status, sqliteNow())
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+	return bookID, nil
+}
+
+func (r *LibraryRepoSQLite) SetStatus(ctx context.Context, userID, bookID int, status string) error {
+	ok, err := changed(r.db.ExecContext(ctx, `
+		UPDATE user_books SET status = ?
+		WHERE user_id = ? AND book_id = ?
+	`, status, userID, bookID))
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrNotInLibrary
+	}
+	return nil
+}
+
+func (r *LibraryRepoSQLite) Remove(ctx context.Context, userID, bookID int, keepReview bool) error {
+	return sqliteTx(ctx, r.db, func(tx *sql.Tx) error {
+		ok, err := changed(tx.ExecContext(ctx, `
+			DELETE FROM user_books WHERE user_id = ? AND book_id = ?
+		`, userID, bookID))
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return ErrNotInLibrary
+		}
+		if keepReview {
+			return nil
+		}
+		_, err = tx.ExecContext(ctx, `DELETE FROM reviews WHERE user_id = ? AND book_id = ?`, userID, bookID)
+		return err
+	})
+}
+
+func (r *LibraryRepoSQLite) Contains(ctx context.Context, userID, bookID int) (bool, error) {
+	var in bool
+	err := r.db.QueryRowContext(ctx, `
+		SELECT EXISTS(SELECT 1 FROM user_books WHERE user_id = ? AND book_id = ?)
+	`, userID, bookID).Scan(&in)
+	return in, err
+}
+
+type LibraryMemory struct {
+	db *MemoryDB
+}
+
+func NewLibraryMemory(db *MemoryDB) *LibraryMemory {
+	return &LibraryMemory{db: db}
+}
+
+func (r *LibraryMemory) List(ctx context.Context, userID int) ([]models.MyBookDTO, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	out := make([]models.MyBookDTO, 0, 16)
+	for k, e := range r.db.shelf {
+		if k.userID != userID {
+			continue
+		}
+		b := r.db.books[k.bookID]
+		out = append(out, models.MyBookDTO{
+			BookID:      b.ID,
+			GoogleID:    b.GoogleID,
+			Title:       b.Title,
+			Author:      b.Author,
+			CoverURL:    b.CoverURL,
+			Status:      e.Status,
+			Collections: r.db.collectionsOf(userID, b.ID),
+		})
+	}
+	slices.SortFunc(out, func(a, b models.MyBookDTO) int {
+		return cmp.Or(cmp.Compare(a.Title, b.Title), cmp.Compare(a.BookID, b.BookID))
+	})
+	return out, nil
+}
+
+// collectionsOf returns the names of the user's collections the book is in,
+// sorted.
+func (db *MemoryDB) collectionsOf(userID, bookID int) []string {
+	names := []string{}
+	for _, c := range db.collections {
+		if c.UserID == userID && slices.Contains(c.BookIDs, bookID) {
+			names = append(names, c.Name)
+		}
+	}
+	slices.Sort(names)
+	return names
+}
+
+func (r *LibraryMemory) Add(ctx context.Context, userID int, b Book, status string) (int, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	bookID := r.db.saveBook(b).ID
+	k := shelfKey{userID, bookID}
+	if e := r.db.shelf[k]; e != nil {
+		e.Status = status
+		return bookID, nil
+	}
+	r.db.shelf[k] = &memShelfEntry{UserID: userID, BookID: bookID, Status: status, CreatedAt: time.Now()}
+	return bookID, nil
+}
+
+func (r *LibraryMemory) SetStatus(ctx context.Context, userID, bookID int, status string) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	e := r.db.shelf[shelfKey{userID, bookID}]
+	if e == nil {
+		return ErrNotInLibrary
+	}
+	e.Status = status
+	return nil
+}
+
+func (r *LibraryMemory) Remove(ctx context.Context, userID, bookID int, keepReview bool) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	k := shelfKey{userID, bookID}
+	if r.db.shelf[k] == nil {
+		return ErrNotInLibrary
+	}
+	delete(r.db.shelf, k)
+	for _, c := range r.db.collections {
+		if c.UserID == userID {
+			c.removeBook(bookID)
+		}
+	}
+	if keepReview {
+		return nil
+	}
+	for rid, rv := range r.db.reviews {
+		if rv.UserID == userID && rv.BookID == bookID {
+			delete(r.db.reviews, rid)
+		}
+	}
+	return nil
+}
+
+func (r *LibraryMemory) Contains(ctx context.Context, userID, bookID int) (bool, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	return r.db.shelf[shelfKey{userID, bookID}] != nil, nil
+}
@@ -0,0 +1,156 @@
+package repo
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// LoginAttemptStore keeps failed login counters per key (account or IP).
+// Counters start over once window has passed since the last failure.
+type LoginAttemptStore interface {
+	RecordFailure(ctx context.Context, key string, window time.Duration) (int, error)
+	Lock(ctx context.Context, key string, until time.Time) error
+	LockedUntil(ctx context.Context, key string) (time.Time, error)
+	Reset(ctx context.Context, key string) error
+}
+
+type LoginAttemptRepoPGX struct {
+	db *pgxpool.Pool
+}
+
+func NewLoginAttemptRepoPGX(db *pgxpool.Pool) *LoginAttemptRepoPGX {
+	return &LoginAttemptRepoPGX{db: db}
+}
+
+func (r *LoginAttemptRepoPGX) RecordFailure(ctx context.Context, key string, window time.Duration) (int, error) {
+	var failures int
+	err := r.db.QueryRow(ctx, `
+		INSERT INTO login_attempts (key, failures, last_failure_at)
+		VALUES ($1, 1, now())
+		ON CONFLICT (key) DO UPDATE SET
+		  failures = CASE
+		    WHEN login_attempts.last_failure_at < $2 THEN 1
+		    ELSE login_attempts.failures + 1
+		  END,
+		  last_failure_at = now()
+		RETURNING failures;
+	`, key, time.Now().Add(-window)).Scan(&failures)
+	if err != nil {
+		return 0, err
+	}
+
+	// keep the table small without a separate cleanup job
+	if rand.IntN(100) == 0 {
+		_, _ = r.db.Exec(ctx, `
+			DELETE FROM login_attempts
+			WHERE last_failure_at < $1 AND (locked_until IS NULL OR locked_until < now());
+		`, time.Now().Add(-window))
+	}
+	return failures, nil
+}
+
+func (r *LoginAttemptRepoPGX) Lock(ctx context.Context, key string, until time.Time) error {
+	_, err := r.db.Exec(ctx, `UPDATE login_attempts SET locked_until = $2 WHERE key = $1`, key, until)
+	return err
+}
+
+func (r *LoginAttemptRepoPGX) LockedUntil(ctx context.Context, key string) (time.Time, error) {
+	var until *time.Time
+	err := r.db.QueryRow(ctx, `SELECT locked_until FROM login_attempts WHERE key = $1`, key).Scan(&until)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return time.Time{}, nil
+		}
+		return time.Time{}, err
+	}
+	if until == nil {
+		return time.Time{}, nil
+	}
+	return *until, nil
+}
+
+func (r *LoginAttemptRepoPGX) Reset(ctx context.Context, key string) error {
+	_, err := r.db.Exec(ctx, `DELETE FROM login_attempts WHERE key = $1`, key)
+	return err
+}
+
+// LoginAttemptMemory is a single-instance LoginAttemptStore.
+type LoginAttemptMemory struct {
+	mu      sync.Mutex
+	entries map[string]*loginAttempt
+}
+
+type loginAttempt struct {
+	failures    int
+	lastFailure time.Time
+	lockedUntil time.Time
+}
+
+func NewLoginAttemptMemory() *LoginAttemptMemory {
+	return &LoginAttemptMemory{entries: map[string]*loginAttempt{}}
+}
+
+func (m *LoginAttemptMemory) RecordFailure(ctx context.Context, key string, window time.Duration) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	if len(m.entries) > 10000 {
+		m.pruneLocked(now, window)
+	}
+
+	e := m.entries[key]
+	if e == nil || now.Sub(e.lastFailure) > window {
+		e = &loginAttempt{lockedUntil: lockedUntilOf(e)}
+		m.entries[key] = e
+	}
+	e.failures++
+	e.lastFailure = now
+	return e.failures, nil
+}
+
+func (m *LoginAttemptMemory) Lock(ctx context.Context, key string, until time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if e := m.entries[key]; e != nil {
+		e.lockedUntil = until
+	}
+	return nil
+}
+
+func (m *LoginAttemptMemory) LockedUntil(ctx context.Context, key string) (time.Time, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return lockedUntilOf(m.entries[key]), nil
+}
+
+func (m *LoginAttemptMemory) Reset(ctx context.Context, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.entries, key)
+	return nil
+}
+
+func (m *LoginAttemptMemory) pruneLocked(now time.Time, window time.Duration) {
+	for k, e := range m.entries {
+		if now.Sub(e.lastFailure) > window && now.After(e.lockedUntil) {
+			delete(m.entries, k)
+		}
+	}
+}
+
+func lockedUntilOf(e *loginAttempt) time.Time {
+	if e == nil {
+		return time.Time{}
+	}
+	return e.lockedUntil
+}
@@ -0,0 +1,385 @@
+package repo
+
+import (
+	"bytes"
+	"cmp"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MemoryDB is the in-process database behind the *Memory repositories. It
+// holds one mutex-guarded copy of every table, so the repositories can
+// answer the same joins, cascades and ON CONFLICT rules as their Postgres
+// counterparts. It is meant for a single instance: frontend development,
+// handler tests and demos.
+type MemoryDB struct {
+	mu sync.Mutex
+
+	users       map[int]*memUser
+	books       map[int]*memBook
+	shelf       map[shelfKey]*memShelfEntry
+	collections map[int]*memCollection
+	reviews     map[int]*memReview
+
+	sessions      map[int64]*memSession
+	refreshTokens map[string]*memRefreshToken
+	resets        map[string]*memReset
+	accessTokens  map[int64]*memAccessToken
+	identities    []*memIdentity
+	oidcStates    map[string]*memOIDCState
+	audit         []AuditEvent
+
+	lastUserID, lastBookID, lastCollectionID, lastReviewID int
+	lastSessionID, lastAccessTokenID, lastAuditID          int64
+
+	// saved is the last snapshot written, so unchanged data is not saved
+	// again.
+	saved []byte
+}
+
+func NewMemoryDB() *MemoryDB {
+	return &MemoryDB{
+		users:         map[int]*memUser{},
+		books:         map[int]*memBook{},
+		shelf:         map[shelfKey]*memShelfEntry{},
+		collections:   map[int]*memCollection{},
+		reviews:       map[int]*memReview{},
+		sessions:      map[int64]*memSession{},
+		refreshTokens: map[string]*memRefreshToken{},
+		resets:        map[string]*memReset{},
+		accessTokens:  map[int64]*memAccessToken{},
+		oidcStates:    map[string]*memOIDCState{},
+	}
+}
+
+type memUser struct {
+	ID                  int               `json:"id"`
+	Email               string            `json:"email"`
+	Name                string            `json:"name"`
+	PasswordHash        string            `json:"passwordHash"`
+	CreatedAt           time.Time         `json:"createdAt"`
+	EmailVerifiedAt     *time.Time        `json:"emailVerifiedAt"`
+	VerificationSentAt  *time.Time        `json:"verificationSentAt"`
+	TOTPSecret          *string           `json:"totpSecret"`
+	TOTPEnabledAt       *time.Time        `json:"totpEnabledAt"`
+	TOTPLastCounter     *int64            `json:"totpLastCounter"`
+	RecoveryCodes       []memRecoveryCode `json:"recoveryCodes"`
+	Role                string            `json:"role"`
+	SuspendedAt         *time.Time        `json:"suspendedAt"`
+	SuspendReason       string            `json:"suspendReason"`
+	DeletionRequestedAt *time.Time        `json:"deletionRequestedAt"`
+}
+
+type memRecoveryCode struct {
+	Hash   string     `json:"hash"`
+	UsedAt *time.Time `json:"usedAt"`
+}
+
+func (u *memUser) user() *User {
+	return &User{
+		ID:                  u.ID,
+		Email:               u.Email,
+		Name:                u.Name,
+		PasswordHash:        u.PasswordHash,
+		EmailVerifiedAt:     u.EmailVerifiedAt,
+		TOTPEnabledAt:       u.TOTPEnabledAt,
+		Role:                u.Role,
+		SuspendedAt:         u.SuspendedAt,
+		DeletionRequestedAt: u.DeletionRequestedAt,
+	}
+}
+
+// memBook keeps genre names on the book; genre ids never leave the
+// database, so the genres table is not modelled.
+type memBook struct {
+	ID            int       `json:"id"`
+	GoogleID      string    `json:"googleId"`
+	Title         string    `json:"title"`
+	Author        string    `json:"author"`
+	CoverURL      string    `json:"coverUrl"`
+	Description   string    `json:"description"`
+	PublishedYear int       `json:"publishedYear"`
+	PageCount     int       `json:"pageCount"`
+	AgeRating     string    `json:"ageRating"`
+	Genres        []string  `json:"genres"`
+	CreatedAt     time.Time `json:"createdAt"`
+}
+
+type shelfKey struct {
+	userID, bookID int
+}
+
+type memShelfEntry struct {
+	UserID    int       `json:"userId"`
+	BookID    int       `json:"bookId"`
+	Status    string    `json:"status"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+type memCollection struct {
+	ID        int       `json:"id"`
+	UserID    int       `json:"userId"`
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"createdAt"`
+	BookIDs   []int     `json:"bookIds"`
+}
+
+type memReview struct {
+	ID int `json:"id"`
+	// UserID is 0 once the review is anonymised.
+	UserID    int       `json:"userId"`
+	BookID    int       `json:"bookId"`
+	Rating    int       `json:"rating"`
+	Text      string    `json:"text"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+type memSession struct {
+	Session
+	RevokedAt    *time.Time
+	RevokeReason string
+}
+
+type memRefreshToken struct {
+	SessionID int64
+	ExpiresAt time.Time
+	UsedAt    *time.Time
+}
+
+type memReset struct {
+	UserID    int
+	ExpiresAt time.Time
+	UsedAt    *time.Time
+}
+
+type memAccessToken struct {
+	AccessToken
+	Hash      string
+	RevokedAt *time.Time
+}
+
+type memIdentity struct {
+	UserID    int
+	Provider  string
+	Subject   string
+	Email     string
+	CreatedAt time.Time
+}
+
+type memOIDCState struct {
+	OIDCState
+	ExpiresAt time.Time
+}
+
+// userByEmail ignores case, like the lower(email) index in Postgres.
+func (db *MemoryDB) userByEmail(email string) *memUser {
+	for _, u := range db.users {
+		if strings.EqualFold(u.Email, email) {
+			return u
+		}
+	}
+	return nil
+}
+
+func (db *MemoryDB) bookByGoogleID(googleID string) *memBook {
+	for _, b := range db.books {
+		if b.GoogleID == googleID {
+			return b
+		}
+	}
+	return nil
+}
+
+func (db *MemoryDB) collection(userID, collectionID int) *memCollection {
+	if c := db.collections[collectionID]; c != nil && c.UserID == userID {
+		return c
+	}
+	return nil
+}
+
+// deleteUser removes the user and, like the ON DELETE CASCADE foreign keys,
+// everything they own. Audit events are kept.
+func (db *MemoryDB) deleteUser(id int, anonymizeReviews bool) {
+	delete(db.users, id)
+
+	for k := range db.shelf {
+		if k.userID == id {
+			delete(db.shelf, k)
+		}
+	}
+	for cid, c := range db.collections {
+		if c.UserID == id {
+			delete(db.collections, cid)
+		}
+	}
+	for rid, rv := range db.reviews {
+		if rv.UserID != id {
+			continue
+		}
+		if anonymizeReviews {
+			rv.UserID = 0
+		} else {
+			delete(db.reviews, rid)
+		}
+	}
+	for sid, s := range db.sessions {
+		if s.UserID == id {
+			delete(db.sessions, sid)
+		}
+	}
+	for hash, t := range db.refreshTokens {
+		if db.sessions[t.SessionID] == nil {
+			delete(db.refreshTokens, hash)
+		}
+	}
+	for hash, rs := range db.resets {
+		if rs.UserID == id {
+			delete(db.resets, hash)
+		}
+	}
+	for tid, t := range db.accessTokens {
+		if t.UserID == id {
+			delete(db.accessTokens, tid)
+		}
+	}
+	kept := db.identities[:0]
+	for _, i := range db.identities {
+		if i.UserID != id {
+			kept = append(kept, i)
+		}
+	}
+	db.identities = kept
+	for hash, st := range db.oidcStates {
+		if st.LinkUserID != nil && *st.LinkUserID == id {
+			delete(db.oidcStates, hash)
+		}
+	}
+}
+
+// memorySnapshot is the file format of MemoryDB.Save. Only the lasting data
+// is kept: sessions, tokens and other short-lived auth state are not, so
+// users sign in again after a restart.
+type memorySnapshot struct {
+	Users       []*memUser       `json:"users"`
+	Books       []*memBook       `json:"books"`
+	Library     []*memShelfEntry `json:"library"`
+	Collections []*memCollection `json:"collections"`
+	Reviews     []*memReview     `json:"reviews"`
+}
+
+// Load replaces the data with the snapshot at path. A missing file leaves
+// the database empty.
+func (db *MemoryDB) Load(path string) error {
+	b, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var snap memorySnapshot
+	if err := json.Unmarshal(b, &snap); err != nil {
+		return err
+	}
+
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	db.users = map[int]*memUser{}
+	db.books = map[int]*memBook{}
+	db.shelf = map[shelfKey]*memShelfEntry{}
+	db.collections = map[int]*memCollection{}
+	db.reviews = map[int]*memReview{}
+	for _, u := range snap.Users {
+		db.users[u.ID] = u
+		db.lastUserID = max(db.lastUserID, u.ID)
+	}
+	for _, bk := range snap.Books {
+		db.books[bk.ID] = bk
+		db.lastBookID = max(db.lastBookID, bk.ID)
+	}
+	for _, e := range snap.Library {
+		db.shelf[shelfKey{e.UserID, e.BookID}] = e
+	}
+	for _, c := range snap.Collections {
+		db.collections[c.ID] = c
+		db.lastCollectionID = max(db.lastCollectionID, c.ID)
+	}
+	for _, rv := range snap.Reviews {
+		db.reviews[rv.ID] = rv
+		db.lastReviewID = max(db.lastReviewID, rv.ID)
+	}
+	db.saved = b
+	return nil
+}
+
+// Save writes a snapshot to path, unless nothing changed since the last
+// Load or Save. The file is replaced atomically.
+func (db *MemoryDB) Save(path string) error {
+	db.mu.Lock()
+	snap := memorySnapshot{
+		Users:       sortedByID(db.users, func(u *memUser) int { return u.ID }),
+		Books:       sortedByID(db.books, func(b *memBook) int { return b.ID }),
+		Library:     make([]*memShelfEntry, 0, len(db.shelf)),
+		Collections: sortedByID(db.collections, func(c *memCollection) int { return c.ID }),
+		Reviews:     sortedByID(db.reviews, func(rv *memReview) int { return rv.ID }),
+	}
+	for _, e := range db.shelf {
+		snap.Library = append(snap.Library, e)
+	}
+	slices.SortFunc(snap.Library, func(a, b *memShelfEntry) int {
+		return cmp.Or(cmp.Compare(a.UserID, b.UserID), cmp.Compare(a.BookID, b.BookID))
+	})
+	b, err := json.MarshalIndent(snap, "", "  ")
+	db.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	if bytes.Equal(b, db.lastSaved()) {
+		return nil
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(b); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return err
+	}
+
+	db.mu.Lock()
+	db.saved = b
+	db.mu.Unlock()
+	return nil
+}
+
+func (db *MemoryDB) lastSaved() []byte {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	return db.saved
+}
+
+// sortedByID lists a table in primary key order.
+func sortedByID[K comparable, V any](table map[K]V, id func(V) int) []V {
+	out := make([]V, 0, len(table))
+	for _, v := range table {
+		out = append(out, v)
+	}
+	slices.SortFunc(out, func(a, b V) int { return cmp.Compare(id(a), id(b)) })
+	return out
+}
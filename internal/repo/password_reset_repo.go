@@ -0,0 +1,192 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+var ErrResetTokenInvalid = errors.New("reset token invalid or expired")
+
+// PasswordResetRepo keeps the single-use tokens of emailed reset links.
+type PasswordResetRepo interface {
+	Create(ctx context.Context, userID int, tokenHash string, expiresAt time.Time) error
+	Consume(ctx context.Context, tokenHash string) (int, error)
+	Peek(ctx context.Context, tokenHash string) (int, error)
+}
+
+type PasswordResetRepoPGX struct {
+	db *pgxpool.Pool
+}
+
+func NewPasswordResetRepoPGX(db *pgxpool.Pool) *PasswordResetRepoPGX {
+	return &PasswordResetRepoPGX{db: db}
+}
+
+// Create stores a new reset token and invalidates any older unused ones, so
+// only the latest emailed link works.
+func (r *PasswordResetRepoPGX) Create(ctx context.Context, userID int, tokenHash string, expiresAt time.Time) error {
+	return pgx.BeginFunc(ctx, r.db, func(tx pgx.Tx) error {
+		if _, err := tx.Exec(ctx, `
+			UPDATE password_reset_tokens SET used_at = now()
+			WHERE user_id = $1 AND used_at IS NULL;
+		`, userID); err != nil {
+			return err
+		}
+		_, err := tx.Exec(ctx, `
+			INSERT INTO password_reset_tokens (user_id, token_hash, expires_at)
+			VALUES ($1, $2, $3);
+		`, userID, tokenHash, expiresAt)
+		return err
+	})
+}
+
+// Consume marks the token as used and returns its user. It succeeds at most
+// once per token.
+func (r *PasswordResetRepoPGX) Consume(ctx context.Context, tokenHash string) (int, error) {
+	var userID int
+	err := r.db.QueryRow(ctx, `
+		UPDATE password_reset_tokens SET used_at = now()
+		WHERE token_hash = $1 AND used_at IS NULL AND expires_at > now()
+		RETURNING user_id;
+	`, tokenHash).Scan(&userID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return 0, ErrResetTokenInvalid
+		}
+		return 0, err
+	}
+	return userID, nil
+}
+
+// Peek returns the user of a live token without using it up, so the new
+// password can be validated before the token is spent.
+func (r *PasswordResetRepoPGX) Peek(ctx context.Context, tokenHash string) (int, error) {
+	var userID int
+	err := r.db.QueryRow(ctx, `
+		SELECT user_id FROM password_reset_tokens
+		WHERE token_hash = $1 AND used_at IS NULL AND expires_at > now();
+	`, tokenHash).Scan(&userID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return 0, ErrResetTokenInvalid
+		}
+		return 0, err
+	}
+	return userID, nil
+}
+
+type PasswordResetRepoSQLite struct {
+	db *sql.DB
+}
+
+func NewPasswordResetRepoSQLite(db *sql.DB) *PasswordResetRepoSQLite {
+	return &PasswordResetRepoSQLite{db: db}
+}
+
+func (r *PasswordResetRepoSQLite) Create(ctx context.Context, userID int, tokenHash string, expiresAt time.Time) error {
+	now := sqliteNow()
+	return sqliteTx(ctx, r.db, func(tx *sql.Tx) error {
+		if _, err := tx.ExecContext(ctx, `
+			UPDATE password_reset_tokens SET used_at = ?
+			WHERE user_id = ? AND used_at IS NULL;
+		`, now, userID); err != nil {
+			return err
+		}
+		_, err := tx.ExecContext(ctx, `
+			INSERT INTO password_reset_tokens (user_id, token_hash, created_at, expires_at)
+			VALUES (?, ?, ?, ?);
+		`, userID, tokenHash, now, expiresAt.UTC())
+		return err
+	})
+}
+
+func (r *PasswordResetRepoSQLite) Consume(ctx context.Context, tokenHash string) (int, error) {
+	var userID int
+	now := sqliteNow()
+	err := r.db.QueryRowContext(ctx, `
+		UPDATE password_reset_tokens SET used_at = ?1
+		WHERE token_hash = ?2 AND used_at IS NULL AND expires_at > ?1
+		RETURNING user_id;
+	`, now, tokenHash).Scan(&userID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, ErrResetTokenInvalid
+		}
+		return 0, err
+	}
+	return userID, nil
+}
+
+func (r *PasswordResetRepoSQLite) Peek(ctx context.Context, tokenHash string) (int, error) {
+	var userID int
+	err := r.db.QueryRowContext(ctx, `
+		SELECT user_id FROM password_reset_tokens
+		WHERE token_hash = ? AND used_at IS NULL AND expires_at > ?;
+	`, tokenHash, sqliteNow()).Scan(&userID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, ErrResetTokenInvalid
+		}
+		return 0, err
+	}
+	return userID, nil
+}
+
+type PasswordResetMemory struct {
+	db *MemoryDB
+}
+
+func NewPasswordResetMemory(db *MemoryDB) *PasswordResetMemory {
+	return &PasswordResetMemory{db: db}
+}
+
+func (r *PasswordResetMemory) Create(ctx context.Context, userID int, tokenHash string, expiresAt time.Time) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	now := time.Now()
+	for _, rs := range r.db.resets {
+		if rs.UserID == userID && rs.UsedAt == nil {
+			rs.UsedAt = &now
+		}
+	}
+	r.db.resets[tokenHash] = &memReset{UserID: userID, ExpiresAt: expiresAt}
+	return nil
+}
+
+func (r *PasswordResetMemory) Consume(ctx context.Context, tokenHash string) (int, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	rs, err := r.live(tokenHash)
+	if err != nil {
+		return 0, err
+	}
+	now := time.Now()
+	rs.UsedAt = &now
+	return rs.UserID, nil
+}
+
+func (r *PasswordResetMemory) Peek(ctx context.Context, tokenHash string) (int, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	rs, err := r.live(tokenHash)
+	if err != nil {
+		return 0, err
+	}
+	return rs.UserID, nil
+}
+
+func (r *PasswordResetMemory) live(tokenHash string) (*memReset, error) {
+	rs := r.db.resets[tokenHash]
+	if rs == nil || rs.UsedAt != nil || !rs.ExpiresAt.After(time.Now()) {
+		return nil, ErrResetTokenInvalid
+	}
+	return rs, nil
+}
@@ -0,0 +1,435 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"bookpulse/internal/db"
+	"bookpulse/internal/models"
+)
+
+// stores is one driver's set of repositories, sharing a database.
+type stores struct {
+	users       UserRepo
+	library     LibraryRepo
+	collections CollectionRepo
+	reviews     ReviewRepo
+	stats       StatsRepo
+	sessions    SessionRepo
+
+	// sqlDB is the SQLite database, for rows no repository reads back;
+	// nil for memory.
+	sqlDB *sql.DB
+}
+
+// eachDriver runs test against a fresh memory database and a fresh,
+// migrated SQLite file, so both drivers are held to the same contract.
+// Postgres is left out: it needs a server.
+func eachDriver(t *testing.T, test func(t *testing.T, s stores)) {
+	t.Run("memory", func(t *testing.T) {
+		m := NewMemoryDB()
+		test(t, stores{
+			users:       NewUserMemory(m),
+			library:     NewLibraryMemory(m),
+			collections: NewCollectionMemory(m),
+			reviews:     NewReviewMemory(m),
+			stats:       NewStatsMemory(m),
+			sessions:    NewSessionMemory(m),
+		})
+	})
+	t.Run("sqlite", func(t *testing.T) {
+		sqlDB := openSQLite(t)
+		test(t, stores{
+			users:       NewUserRepoSQLite(sqlDB),
+			library:     NewLibraryRepoSQLite(sqlDB),
+			collections: NewCollectionRepoSQLite(sqlDB),
+			reviews:     NewReviewRepoSQLite(sqlDB),
+			stats:       NewStatsRepoSQLite(sqlDB),
+			sessions:    NewSessionRepoSQLite(sqlDB),
+			sqlDB:       sqlDB,
+		})
+	})
+}
+
+func openSQLite(t *testing.T) *sql.DB {
+	t.Helper()
+	sqlDB, err := db.OpenSQLite(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { sqlDB.Close() })
+	if err := db.MigrateSQLite(context.Background(), sqlDB); err != nil {
+		t.Fatal(err)
+	}
+	return sqlDB
+}
+
+func newUser(t *testing.T, s stores, email string) int {
+	t.Helper()
+	u, err := s.users.Create(context.Background(), email, "", "hash")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return u.ID
+}
+
+func addBook(t *testing.T, s stores, userID int, googleID, status string, genres ...string) int {
+	t.Helper()
+	b := Book{GoogleID: googleID, Title: googleID, Author: "Author", Genres: genres}
+	id, err := s.library.Add(context.Background(), userID, b, status)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return id
+}
+
+func TestLibraryRemoveKeepReview(t *testing.T) {
+	eachDriver(t, func(t *testing.T, s stores) {
+		ctx := context.Background()
+		userID := newUser(t, s, "ann@example.com")
+		kept := addBook(t, s, userID, "kept", "finished")
+		dropped := addBook(t, s, userID, "dropped", "finished")
+		for _, id := range []int{kept, dropped} {
+			if _, err := s.reviews.Upsert(ctx, userID, id, 4, "fine"); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if _, err := s.collections.Upsert(ctx, userID, "Shelf", []int{kept, dropped}); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := s.library.Remove(ctx, userID, kept, true); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.library.Remove(ctx, userID, dropped, false); err != nil {
+			t.Fatal(err)
+		}
+
+		if rs, err := s.reviews.ListForBook(ctx, kept, userID); err != nil || len(rs) != 1 {
+			t.Errorf("review of kept book = %v, %v; want it kept", rs, err)
+		}
+		if rs, err := s.reviews.ListForBook(ctx, dropped, userID); err != nil || len(rs) != 0 {
+			t.Errorf("review of dropped book = %v, %v; want it deleted", rs, err)
+		}
+		if in, err := s.library.Contains(ctx, userID, kept); err != nil || in {
+			t.Errorf("Contains = %v, %v; want the book off the shelf", in, err)
+		}
+		cs, err := s.collections.List(ctx, userID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(cs) != 1 || cs[0].Count != 0 {
+			t.Errorf("collections = %+v; want Shelf emptied", cs)
+		}
+
+		if err := s.library.Remove(ctx, userID, kept, true); !errors.Is(err, ErrNotInLibrary) {
+			t.Errorf("second Remove = %v; want ErrNotInLibrary", err)
+		}
+	})
+}
+
+func TestLibraryAddTwice(t *testing.T) {
+	eachDriver(t, func(t *testing.T, s stores) {
+		ctx := context.Background()
+		ann := newUser(t, s, "ann@example.com")
+		first := addBook(t, s, ann, "dune", "reading", "Fantasy")
+		again := addBook(t, s, ann, "dune", "finished", "Fantasy", "Drama")
+		if again != first {
+			t.Errorf("second Add returned book %d; want %d", again, first)
+		}
+
+		books, err := s.library.List(ctx, ann)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(books) != 1 || books[0].Status != "finished" {
+			t.Errorf("shelf = %+v; want one finished book", books)
+		}
+		got, err := s.stats.FinishedByGenre(ctx, ann)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := []models.GenreStatDto{{Genre: "Drama", Cnt: 1}, {Genre: "Fantasy", Cnt: 1}}
+		if !slices.Equal(got, want) {
+			t.Errorf("genres = %+v; want %+v", got, want)
+		}
+	})
+}
+
+// TestLibraryAddRollsBack needs a constraint that fails after the book is
+// saved, which only the SQL drivers have.
+func TestLibraryAddRollsBack(t *testing.T) {
+	sqlDB := openSQLite(t)
+	ctx := context.Background()
+	library := NewLibraryRepoSQLite(sqlDB)
+	books := NewBookRepoSQLite(sqlDB)
+
+	b := Book{GoogleID: "ghost", Title: "Ghost", Genres: []string{"Horror"}}
+	if _, err := library.Add(ctx, 42, b, "reading"); err == nil {
+		t.Fatal("Add for an unknown user succeeded")
+	}
+	if _, err := books.IDByGoogleID(ctx, "ghost"); !errors.Is(err, ErrBookNotFound) {
+		t.Errorf("IDByGoogleID after a failed Add = %v; want ErrBookNotFound", err)
+	}
+	var genres int
+	if err := sqlDB.QueryRowContext(ctx, `SELECT COUNT(*) FROM genres`).Scan(&genres); err != nil {
+		t.Fatal(err)
+	}
+	if genres != 0 {
+		t.Errorf("%d genres left behind by a failed Add", genres)
+	}
+}
+
+func TestCollectionRenameConflict(t *testing.T) {
+	eachDriver(t, func(t *testing.T, s stores) {
+		ctx := context.Background()
+		ann := newUser(t, s, "ann@example.com")
+		bob := newUser(t, s, "bob@example.com")
+		fav, err := s.collections.Upsert(ctx, ann, "Favourites", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := s.collections.Upsert(ctx, ann, "Later", nil); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := s.collections.Upsert(ctx, bob, "Best", nil); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := s.collections.Rename(ctx, ann, fav, "Later"); !errors.Is(err, ErrCollectionNameTaken) {
+			t.Errorf("Rename to a taken name = %v; want ErrCollectionNameTaken", err)
+		}
+		if err := s.collections.Rename(ctx, ann, fav, "Favourites"); err != nil {
+			t.Errorf("Rename to its own name = %v", err)
+		}
+		if err := s.collections.Rename(ctx, ann, fav, "Best"); err != nil {
+			t.Errorf("Rename to another user's name = %v", err)
+		}
+		if err := s.collections.Rename(ctx, bob, fav, "Mine"); !errors.Is(err, ErrCollectionNotFound) {
+			t.Errorf("Rename of another user's collection = %v; want ErrCollectionNotFound", err)
+		}
+	})
+}
+
+func TestCollectionAddBooksAllOrNothing(t *testing.T) {
+	eachDriver(t, func(t *testing.T, s stores) {
+		ctx := context.Background()
+		ann := newUser(t, s, "ann@example.com")
+		bob := newUser(t, s, "bob@example.com")
+		mine := addBook(t, s, ann, "mine", "reading")
+		theirs := addBook(t, s, bob, "theirs", "reading")
+
+		id, err := s.collections.Upsert(ctx, ann, "Shelf", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := s.collections.AddBooks(ctx, ann, id, []int{mine, theirs}); err == nil {
+			t.Error("AddBooks with a book off the shelf succeeded")
+		}
+		if _, err := s.collections.Upsert(ctx, ann, "New", []int{mine, theirs}); err == nil {
+			t.Error("Upsert with a book off the shelf succeeded")
+		}
+
+		cs, err := s.collections.List(ctx, ann)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(cs) != 1 || cs[0].Name != "Shelf" || cs[0].Count != 0 {
+			t.Errorf("collections = %+v; want only Shelf, empty", cs)
+		}
+
+		if err := s.collections.AddBooks(ctx, ann, id, []int{mine, mine}); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.collections.AddBooks(ctx, ann, id, []int{mine}); err != nil {
+			t.Errorf("adding a book twice = %v", err)
+		}
+		if cs, _ := s.collections.List(ctx, ann); len(cs) != 1 || cs[0].Count != 1 {
+			t.Errorf("collections = %+v; want Shelf with one book", cs)
+		}
+	})
+}
+
+func TestStatsOrdering(t *testing.T) {
+	eachDriver(t, func(t *testing.T, s stores) {
+		ctx := context.Background()
+		ann := newUser(t, s, "ann@example.com")
+		addBook(t, s, ann, "b1", "finished", "Fantasy", "Poetry")
+		addBook(t, s, ann, "b2", "finished", "Fantasy", "Drama")
+		addBook(t, s, ann, "b3", "finished", "Science")
+		addBook(t, s, ann, "b4", "reading", "Poetry")
+
+		got, err := s.stats.FinishedByGenre(ctx, ann)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := []models.GenreStatDto{
+			{Genre: "Fantasy", Cnt: 2},
+			{Genre: "Drama", Cnt: 1},
+			{Genre: "Poetry", Cnt: 1},
+			{Genre: "Science", Cnt: 1},
+		}
+		if !slices.Equal(got, want) {
+			t.Errorf("FinishedByGenre = %+v; want %+v", got, want)
+		}
+
+		months, err := s.stats.AddedByMonth(ctx, ann, 3)
+		if err != nil {
+			t.Fatal(err)
+		}
+		now := time.Now().UTC().Format("2006-01")
+		if len(months) != 1 || months[0] != (models.MonthStatDto{Month: now, Cnt: 4}) {
+			t.Errorf("AddedByMonth = %+v; want 4 books in %s", months, now)
+		}
+	})
+}
+
+func TestSessionRotateReuse(t *testing.T) {
+	eachDriver(t, func(t *testing.T, s stores) {
+		ctx := context.Background()
+		ann := newUser(t, s, "ann@example.com")
+		exp := time.Now().Add(time.Hour)
+		sess, err := s.sessions.Create(ctx, ann, "test", "127.0.0.1", "r1", exp)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := s.sessions.Rotate(ctx, "r1", "r2", exp); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := s.sessions.Rotate(ctx, "r1", "r3", exp); !errors.Is(err, ErrRefreshTokenReused) {
+			t.Fatalf("reusing a rotated token = %v; want ErrRefreshTokenReused", err)
+		}
+		if active, err := s.sessions.IsActive(ctx, sess.ID); err != nil || active {
+			t.Errorf("IsActive after reuse = %v, %v; want the session revoked", active, err)
+		}
+		if _, err := s.sessions.Rotate(ctx, "r2", "r4", exp); !errors.Is(err, ErrSessionNotFound) {
+			t.Errorf("rotating the current token of a revoked session = %v; want ErrSessionNotFound", err)
+		}
+	})
+}
+
+func TestUserEmailIgnoresCase(t *testing.T) {
+	eachDriver(t, func(t *testing.T, s stores) {
+		ctx := context.Background()
+		id := newUser(t, s, "alice@example.com")
+
+		u, err := s.users.FindByEmail(ctx, "Alice@Example.COM")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if u == nil || u.ID != id {
+			t.Fatalf("FindByEmail with other case = %+v; want user %d", u, id)
+		}
+		if _, err := s.users.Create(ctx, "ALICE@example.com", "", "hash"); err == nil {
+			t.Error("Create with an address differing by case only succeeded")
+		}
+		if ok, err := s.users.MarkEmailVerified(ctx, id, "Alice@example.com"); err != nil || !ok {
+			t.Errorf("MarkEmailVerified with other case = %v, %v; want true", ok, err)
+		}
+	})
+}
+
+func TestUserPurge(t *testing.T) {
+	eachDriver(t, func(t *testing.T, s stores) {
+		for _, anonymize := range []bool{false, true} {
+			ctx := context.Background()
+			ann := newUser(t, s, fmt.Sprintf("ann-%t@example.com", anonymize))
+			book := addBook(t, s, ann, fmt.Sprintf("dune-%t", anonymize), "finished")
+			if _, err := s.reviews.Upsert(ctx, ann, book, 5, "great"); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := s.collections.Upsert(ctx, ann, "Shelf", []int{book}); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := s.users.RequestDeletion(ctx, ann); err != nil {
+				t.Fatal(err)
+			}
+
+			// requested after the cutoff: still in its grace period
+			if ok, err := s.users.Purge(ctx, ann, time.Now().Add(-time.Hour), anonymize); err != nil || ok {
+				t.Fatalf("Purge before the grace period ended = %v, %v; want false", ok, err)
+			}
+			if in, err := s.library.Contains(ctx, ann, book); err != nil || !in {
+				t.Fatalf("Contains after an early Purge = %v, %v; want the shelf kept", in, err)
+			}
+
+			if ok, err := s.users.Purge(ctx, ann, time.Now().Add(time.Minute), anonymize); err != nil || !ok {
+				t.Fatalf("Purge = %v, %v; want true", ok, err)
+			}
+			if u, err := s.users.FindByID(ctx, ann); err != nil || u != nil {
+				t.Errorf("anonymize=%t: FindByID = %+v, %v; want the user gone", anonymize, u, err)
+			}
+			if in, err := s.library.Contains(ctx, ann, book); err != nil || in {
+				t.Errorf("anonymize=%t: Contains = %v, %v; want the shelf emptied", anonymize, in, err)
+			}
+			if cs, err := s.collections.List(ctx, ann); err != nil || len(cs) != 0 {
+				t.Errorf("anonymize=%t: collections = %+v, %v; want none", anonymize, cs, err)
+			}
+			if s.sqlDB != nil {
+				for _, table := range []string{"user_books", "collections", "collection_books"} {
+					var n int
+					if err := s.sqlDB.QueryRowContext(ctx, "SELECT COUNT(*) FROM "+table).Scan(&n); err != nil {
+						t.Fatal(err)
+					}
+					if n != 0 {
+						t.Errorf("anonymize=%t: %d rows left in %s", anonymize, n, table)
+					}
+				}
+			}
+
+			rs, err := s.reviews.ListForBook(ctx, book, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			switch {
+			case !anonymize && len(rs) != 0:
+				t.Errorf("reviews = %+v; want deleted", rs)
+			case anonymize && (len(rs) != 1 || rs[0].UserName != "Deleted user" || rs[0].Text != "great"):
+				t.Errorf("reviews = %+v; want one by \"Deleted user\"", rs)
+			}
+
+			if ok, err := s.users.Purge(ctx, ann, time.Now().Add(time.Minute), anonymize); err != nil || ok {
+				t.Errorf("anonymize=%t: second Purge = %v, %v; want false", anonymize, ok, err)
+			}
+		}
+	})
+}
+
+func TestUserCancelDeletion(t *testing.T) {
+	eachDriver(t, func(t *testing.T, s stores) {
+		ctx := context.Background()
+		ann := newUser(t, s, "ann@example.com")
+		book := addBook(t, s, ann, "dune", "reading")
+		if _, err := s.users.RequestDeletion(ctx, ann); err != nil {
+			t.Fatal(err)
+		}
+		cutoff := time.Now().Add(time.Minute)
+		if ids, err := s.users.DueForPurge(ctx, cutoff, 10); err != nil || !slices.Equal(ids, []int{ann}) {
+			t.Fatalf("DueForPurge = %v, %v; want [%d]", ids, err, ann)
+		}
+
+		// a cancel landing between DueForPurge and Purge wins
+		if ok, err := s.users.CancelDeletion(ctx, ann); err != nil || !ok {
+			t.Fatalf("CancelDeletion = %v, %v; want true", ok, err)
+		}
+		if ok, err := s.users.Purge(ctx, ann, cutoff, false); err != nil || ok {
+			t.Errorf("Purge after CancelDeletion = %v, %v; want false", ok, err)
+		}
+		if u, err := s.users.FindByID(ctx, ann); err != nil || u == nil {
+			t.Errorf("FindByID = %+v, %v; want the user kept", u, err)
+		}
+		if in, err := s.library.Contains(ctx, ann, book); err != nil || !in {
+			t.Errorf("Contains = %v, %v; want the shelf kept", in, err)
+		}
+		if ok, err := s.users.CancelDeletion(ctx, ann); err != nil || ok {
+			t.Errorf("second CancelDeletion = %v, %v; want false", ok, err)
+		}
+	})
+}
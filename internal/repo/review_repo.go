@@ -0,0 +1,267 @@
+package repo
+
+import (
+	"cmp"
+	"context"
+	"database/sql"
+	"errors"
+	"slices"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+var ErrReviewNotFound = errors.New("review not found")
+
+type Review struct {
+	ID int
+	// UserName falls back to the email, or "Deleted user" once the author's
+	// account is gone.
+	UserName  string
+	CreatedAt time.Time
+	Rating    int
+	Text      string
+	// Mine is set when the review is by the user the list was loaded for.
+	Mine bool
+}
+
+// DeletedReview says whose review of which book was deleted.
+type DeletedReview struct {
+	// AuthorID is 0 for the anonymised review of a deleted account.
+	AuthorID int
+	BookID   int
+}
+
+// ReviewRepo keeps reviews, at most one per user and book.
+type ReviewRepo interface {
+	// ListForBook returns the reviews of a book, newest first. viewerID marks
+	// the viewer's own review; 0 for anonymous viewers.
+	ListForBook(ctx context.Context, bookID, viewerID int) ([]Review, error)
+	// Upsert creates the user's review of the book or replaces it.
+	Upsert(ctx context.Context, userID, bookID, rating int, text string) (*Review, error)
+	// Delete returns ErrReviewNotFound for unknown ids.
+	Delete(ctx context.Context, reviewID int) (*DeletedReview, error)
+}
+
+type ReviewRepoPGX struct {
+	db *pgxpool.Pool
+}
+
+func NewReviewRepoPGX(db *pgxpool.Pool) *ReviewRepoPGX {
+	return &ReviewRepoPGX{db: db}
+}
+
+func (r *ReviewRepoPGX) ListForBook(ctx context.Context, bookID, viewerID int) ([]Review, error) {
+	rows, err := r.db.Query(ctx, `
+		SELECT r.id,
+		       COALESCE(NULLIF(u.name,''), u.email, 'Deleted user') AS user_name,
+		       r.created_at,
+		       r.rating,
+		       r.text,
+		       COALESCE(r.user_id = $2, false) AS mine
+		FROM reviews r
+		LEFT JOIN users u ON u.id = r.user_id
+		WHERE r.book_id = $1
+		ORDER BY r.created_at DESC;
+	`, bookID, viewerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := make([]Review, 0, 16)
+	for rows.Next() {
+		var rv Review
+		if err := rows.Scan(&rv.ID, &rv.UserName, &rv.CreatedAt, &rv.Rating, &rv.Text, &rv.Mine); err != nil {
+			return nil, err
+		}
+		out = append(out, rv)
+	}
+	return out, rows.Err()
+}
+
+func (r *ReviewRepoPGX) Upsert(ctx context.Context, userID, bookID, rating int, text string) (*Review, error) {
+	rv := Review{Rating: rating, Text: text, Mine: true}
+	err := r.db.QueryRow(ctx, `
+		INSERT INTO reviews (user_id, book_id, rating, text, created_at)
+		VALUES ($1,$2,$3,$4, now())
+		ON CONFLICT (user_id, book_id)
+		DO UPDATE SET rating = EXCLUDED.rating,
+		              text   = EXCLUDED.text,
+		              created_at = now()
+		RETURNING id, created_at;
+	`, userID, bookID, rating, text).Scan(&rv.ID, &rv.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	_ = r.db.QueryRow(ctx, `
+		SELECT COALESCE(NULLIF(name,''), email, 'User') FROM users WHERE id=$1
+	`, userID).Scan(&rv.UserName)
+	return &rv, nil
+}
+
+func (r *ReviewRepoPGX) Delete(ctx context.Context, reviewID int) (*DeletedReview, error) {
+	var d DeletedReview
+	err := r.db.QueryRow(ctx, `
+		DELETE FROM reviews WHERE id = $1
+		RETURNING COALESCE(user_id, 0), book_id;
+	`, reviewID).Scan(&d.AuthorID, &d.BookID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrReviewNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &d, nil
+}
+
+type ReviewRepoSQLite struct {
+	db *sql.DB
+}
+
+func NewReviewRepoSQLite(db *sql.DB) *ReviewRepoSQLite {
+	return &ReviewRepoSQLite{db: db}
+}
+
+func (r *ReviewRepoSQLite) ListForBook(ctx context.Context, bookID, viewerID int) ([]Review, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT r.id,
+		       COALESCE(NULLIF(u.name,''), u.email, 'Deleted user') AS user_name,
+		       r.created_at,
+		       r.rating,
+		       r.text,
+		       COALESCE(r.user_id = ?2, 0) AS mine
+		FROM reviews r
+		LEFT JOIN users u ON u.id = r.user_id
+		WHERE r.book_id = ?1
+		ORDER BY r.created_at DESC;
+	`, bookID, viewerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := make([]Review, 0, 16)
+	for rows.Next() {
+		var rv Review
+		if err := rows.Scan(&rv.ID, &rv.UserName, &rv.CreatedAt, &rv.Rating, &rv.Text, &rv.Mine); err != nil {
+			return nil, err
+		}
+		out = append(out, rv)
+	}
+	return out, rows.Err()
+}
+
+func (r *ReviewRepoSQLite) Upsert(ctx context.Context, userID, bookID, rating int, text string) (*Review, error) {
+	rv := Review{Rating: rating, Text: text, Mine: true}
+	err := r.db.QueryRowContext(ctx, `
+		INSERT INTO reviews (user_id, book_id, rating, text, created_at)
+		VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT (user_id, book_id)
+		DO UPDATE SET rating = excluded.rating,
+		              text   = excluded.text,
+		              created_at = excluded.created_at
+		RETURNING id, created_at;
+	`, userID, bookID, rating, text, sqliteNow()).Scan(&rv.ID, &rv.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	_ = r.db.QueryRowContext(ctx, `
+		SELECT COALESCE(NULLIF(name,''), email, 'User') FROM users WHERE id = ?
+	`, userID).Scan(&rv.UserName)
+	return &rv, nil
+}
+
+func (r *ReviewRepoSQLite) Delete(ctx context.Context, reviewID int) (*DeletedReview, error) {
+	var d DeletedReview
+	err := r.db.QueryRowContext(ctx, `
+		DELETE FROM reviews WHERE id = ?
+		RETURNING COALESCE(user_id, 0), book_id;
+	`, reviewID).Scan(&d.AuthorID, &d.BookID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrReviewNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &d, nil
+}
+
+type ReviewMemory struct {
+	db *MemoryDB
+}
+
+func NewReviewMemory(db *MemoryDB) *ReviewMemory {
+	return &ReviewMemory{db: db}
+}
+
+func (r *ReviewMemory) ListForBook(ctx context.Context, bookID, viewerID int) ([]Review, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	out := make([]Review, 0, 16)
+	for _, rv := range r.db.reviews {
+		if rv.BookID == bookID {
+			out = append(out, r.review(rv, "Deleted user", viewerID))
+		}
+	}
+	slices.SortFunc(out, func(a, b Review) int {
+		return cmp.Or(b.CreatedAt.Compare(a.CreatedAt), cmp.Compare(b.ID, a.ID))
+	})
+	return out, nil
+}
+
+// review joins rv with its author; fallback names authors that are gone.
+func (r *ReviewMemory) review(rv *memReview, fallback string, viewerID int) Review {
+	out := Review{
+		ID:        rv.ID,
+		UserName:  fallback,
+		CreatedAt: rv.CreatedAt,
+		Rating:    rv.Rating,
+		Text:      rv.Text,
+		Mine:      rv.UserID != 0 && rv.UserID == viewerID,
+	}
+	if u := r.db.users[rv.UserID]; u != nil {
+		out.UserName = cmp.Or(u.Name, u.Email)
+	}
+	return out
+}
+
+func (r *ReviewMemory) Upsert(ctx context.Context, userID, bookID, rating int, text string) (*Review, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	if r.db.books[bookID] == nil {
+		return nil, ErrBookNotFound
+	}
+	var rv *memReview
+	for _, old := range r.db.reviews {
+		if old.UserID == userID && old.BookID == bookID {
+			rv = old
+			break
+		}
+	}
+	if rv == nil {
+		r.db.lastReviewID++
+		rv = &memReview{ID: r.db.lastReviewID, UserID: userID, BookID: bookID}
+		r.db.reviews[rv.ID] = rv
+	}
+	rv.Rating, rv.Text, rv.CreatedAt = rating, text, time.Now()
+
+	out := r.review(rv, "User", userID)
+	return &out, nil
+}
+
+func (r *ReviewMemory) Delete(ctx context.Context, reviewID int) (*DeletedReview, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	rv := r.db.reviews[reviewID]
+	if rv == nil {
+		return nil, ErrReviewNotFound
+	}
+	delete(r.db.reviews, reviewID)
+	return &DeletedReview{AuthorID: rv.UserID, BookID: rv.BookID}, nil
+}
@@ -0,0 +1,586 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"slices"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+var (
+	ErrSessionNotFound     = errors.New("session not found")
+	ErrRefreshTokenReused  = errors.New("refresh token reused")
+	ErrRefreshTokenExpired = errors.New("refresh token expired")
+)
+
+type Session struct {
+	ID         int64     `json:"id"`
+	UserID     int       `json:"-"`
+	UserAgent  string    `json:"userAgent"`
+	IP         string    `json:"ip"`
+	CreatedAt  time.Time `json:"createdAt"`
+	LastSeenAt time.Time `json:"lastSeenAt"`
+	ExpiresAt  time.Time `json:"expiresAt"`
+}
+
+// SessionRepo keeps login sessions and their chain of refresh tokens.
+type SessionRepo interface {
+	Create(ctx context.Context, userID int, userAgent, ip, refreshHash string, expiresAt time.Time) (*Session, error)
+	Rotate(ctx context.Context, oldHash, newHash string, expiresAt time.Time) (*Session, error)
+	FindByRefreshHash(ctx context.Context, hash string) (*Session, error)
+	IsActive(ctx context.Context, sessionID int64) (bool, error)
+	ListActive(ctx context.Context, userID int) ([]Session, error)
+	Revoke(ctx context.Context, sessionID int64, userID int, reason string) error
+	RevokeAllForUser(ctx context.Context, userID int, reason string) error
+	RevokeOthers(ctx context.Context, userID int, keepID int64, reason string) (int64, error)
+}
+
+type SessionRepoPGX struct {
+	db *pgxpool.Pool
+}
+
+func NewSessionRepoPGX(db *pgxpool.Pool) *SessionRepoPGX {
+	return &SessionRepoPGX{db: db}
+}
+
+// Create opens a session together with its first refresh token.
+func (r *SessionRepoPGX) Create(ctx context.Context, userID int, userAgent, ip, refreshHash string, expiresAt time.Time) (*Session, error) {
+	var s Session
+	err := pgx.BeginFunc(ctx, r.db, func(tx pgx.Tx) error {
+		err := tx.QueryRow(ctx, `
+			INSERT INTO sessions (user_id, user_agent, ip, expires_at)
+			VALUES ($1, $2, $3, $4)
+			RETURNING id, user_id, user_agent, ip, created_at, last_seen_at, expires_at;
+		`, userID, userAgent, ip, expiresAt).Scan(
+			&s.ID, &s.UserID, &s.UserAgent, &s.IP, &s.CreatedAt, &s.LastSeenAt, &s.ExpiresAt,
+		)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.Exec(ctx, `
+			INSERT INTO refresh_tokens (session_id, token_hash, expires_at)
+			VALUES ($1, $2, $3);
+		`, s.ID, refreshHash, expiresAt)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// Rotate exchanges the refresh token identified by oldHash for newHash and
+// slides the session expiry. Presenting an already used token revokes the
+// whole session and returns ErrRefreshTokenReused.
+func (r *SessionRepoPGX) Rotate(ctx context.Context, oldHash, newHash string, expiresAt time.Time) (*Session, error) {
+	var s Session
+	var reused bool
+
+	err := pgx.BeginFunc(ctx, r.db, func(tx pgx.Tx) error {
+		var tokenID int64
+		var usedAt, revokedAt *time.Time
+		var tokenExpiresAt time.Time
+
+		err := tx.QueryRow(ctx, `
+			SELECT rt.id, rt.used_at, rt.expires_at, s.id, s.user_id, s.revoked_at
+			FROM refresh_tokens rt
+			JOIN sessions s ON s.id = rt.session_id
+			WHERE rt.token_hash = $1
+			FOR UPDATE OF rt, s;
+		`, oldHash).Scan(&tokenID, &usedAt, &tokenExpiresAt, &s.ID, &s.UserID, &revokedAt)
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return ErrSessionNotFound
+			}
+			return err
+		}
+
+		if revokedAt != nil {
+			return ErrSessionNotFound
+		}
+
+		if usedAt != nil {
+			_, err := tx.Exec(ctx, `
+				UPDATE sessions SET revoked_at = now(), revoke_reason = 'refresh_token_reuse'
+				WHERE id = $1 AND revoked_at IS NULL;
+			`, s.ID)
+			if err != nil {
+				return err
+			}
+			reused = true
+			return nil
+		}
+
+		if time.Now().After(tokenExpiresAt) {
+			return ErrRefreshTokenExpired
+		}
+
+		if _, err := tx.Exec(ctx, `UPDATE refresh_tokens SET used_at = now() WHERE id = $1`, tokenID); err != nil {
+			return err
+		}
+		if _, err := tx.Exec(ctx, `
+			INSERT INTO refresh_tokens (session_id, token_hash, expires_at)
+			VALUES ($1, $2, $3);
+		`, s.ID, newHash, expiresAt); err != nil {
+			return err
+		}
+
+		return tx.QueryRow(ctx, `
+			UPDATE sessions SET last_seen_at = now(), expires_at = $2
+			WHERE id = $1
+			RETURNING user_agent, ip, created_at, last_seen_at, expires_at;
+		`, s.ID, expiresAt).Scan(&s.UserAgent, &s.IP, &s.CreatedAt, &s.LastSeenAt, &s.ExpiresAt)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if reused {
+		return nil, ErrRefreshTokenReused
+	}
+	return &s, nil
+}
+
+// FindByRefreshHash returns the session a refresh token belongs to, whether
+// or not that token has already been rotated.
+func (r *SessionRepoPGX) FindByRefreshHash(ctx context.Context, hash string) (*Session, error) {
+	var s Session
+	err := r.db.QueryRow(ctx, `
+		SELECT s.id, s.user_id, s.user_agent, s.ip, s.created_at, s.last_seen_at, s.expires_at
+		FROM refresh_tokens rt
+		JOIN sessions s ON s.id = rt.session_id
+		WHERE rt.token_hash = $1 AND s.revoked_at IS NULL;
+	`, hash).Scan(&s.ID, &s.UserID, &s.UserAgent, &s.IP, &s.CreatedAt, &s.LastSeenAt, &s.ExpiresAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &s, nil
+}
+
+// IsActive reports whether the session is open and its user not suspended,
+// and bumps its last_seen_at, at most once a minute.
+func (r *SessionRepoPGX) IsActive(ctx context.Context, sessionID int64) (bool, error) {
+	var active bool
+	err := r.db.QueryRow(ctx, `
+		WITH s AS (
+			SELECT s.id, s.last_seen_at FROM sessions s
+			JOIN users u ON u.id = s.user_id
+			WHERE s.id = $1 AND s.revoked_at IS NULL AND s.expires_at > now()
+			  AND u.suspended_at IS NULL
+		), touched AS (
+			UPDATE sessions SET last_seen_at = now()
+			WHERE id IN (SELECT id FROM s WHERE last_seen_at < now() - interval '1 minute')
+		)
+		SELECT EXISTS(SELECT 1 FROM s);
+	`, sessionID).Scan(&active)
+	return active, err
+}
+
+func (r *SessionRepoPGX) ListActive(ctx context.Context, userID int) ([]Session, error) {
+	rows, err := r.db.Query(ctx, `
+		SELECT id, user_id, user_agent, ip, created_at, last_seen_at, expires_at
+		FROM sessions
+		WHERE user_id = $1 AND revoked_at IS NULL AND expires_at > now()
+		ORDER BY last_seen_at DESC;
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := make([]Session, 0, 4)
+	for rows.Next() {
+		var s Session
+		if err := rows.Scan(&s.ID, &s.UserID, &s.UserAgent, &s.IP, &s.CreatedAt, &s.LastSeenAt, &s.ExpiresAt); err != nil {
+			return nil, err
+		}
+		out = append(out, s)
+	}
+	return out, rows.Err()
+}
+
+func (r *SessionRepoPGX) Revoke(ctx context.Context, sessionID int64, userID int, reason string) error {
+	cmd, err := r.db.Exec(ctx, `
+		UPDATE sessions SET revoked_at = now(), revoke_reason = $3
+		WHERE id = $1 AND user_id = $2 AND revoked_at IS NULL;
+	`, sessionID, userID, reason)
+	if err != nil {
+		return err
+	}
+	if cmd.RowsAffected() == 0 {
+		return ErrSessionNotFound
+	}
+	return nil
+}
+
+func (r *SessionRepoPGX) RevokeAllForUser(ctx context.Context, userID int, reason string) error {
+	_, err := r.db.Exec(ctx, `
+		UPDATE sessions SET revoked_at = now(), revoke_reason = $2
+		WHERE user_id = $1 AND revoked_at IS NULL;
+	`, userID, reason)
+	return err
+}
+
+// RevokeOthers revokes every open session of the user except keepID.
+func (r *SessionRepoPGX) RevokeOthers(ctx context.Context, userID int, keepID int64, reason string) (int64, error) {
+	cmd, err := r.db.Exec(ctx, `
+		UPDATE sessions SET revoked_at = now(), revoke_reason = $3
+		WHERE user_id = $1 AND id <> $2 AND revoked_at IS NULL;
+	`, userID, keepID, reason)
+	if err != nil {
+		return 0, err
+	}
+	return cmd.RowsAffected(), nil
+}
+
+type SessionRepoSQLite struct {
+	db *sql.DB
+}
+
+func NewSessionRepoSQLite(db *sql.DB) *SessionRepoSQLite {
+	return &SessionRepoSQLite{db: db}
+}
+
+func (r *SessionRepoSQLite) Create(ctx context.Context, userID int, userAgent, ip, refreshHash string, expiresAt time.Time) (*Session, error) {
+	var s Session
+	now := sqliteNow()
+	err := sqliteTx(ctx, r.db, func(tx *sql.Tx) error {
+		err := tx.QueryRowContext(ctx, `
+			INSERT INTO sessions (user_id, user_agent, ip, created_at, last_seen_at, expires_at)
+			VALUES (?, ?, ?, ?, ?, ?)
+			RETURNING id, user_id, user_agent, ip, created_at, last_seen_at, expires_at;
+		`, userID, userAgent, ip, now, now, expiresAt.UTC()).Scan(
+			&s.ID, &s.UserID, &s.UserAgent, &s.IP, &s.CreatedAt, &s.LastSeenAt, &s.ExpiresAt,
+		)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.ExecContext(ctx, `
+			INSERT INTO refresh_tokens (session_id, token_hash, created_at, expires_at)
+			VALUES (?, ?, ?, ?);
+		`, s.ID, refreshHash, now, expiresAt.UTC())
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+func (r *SessionRepoSQLite) Rotate(ctx context.Context, oldHash, newHash string, expiresAt time.Time) (*Session, error) {
+	var s Session
+	var reused bool
+
+	err := sqliteTx(ctx, r.db, func(tx *sql.Tx) error {
+		var tokenID int64
+		var usedAt, revokedAt *time.Time
+		var tokenExpiresAt time.Time
+
+		err := tx.QueryRowContext(ctx, `
+			SELECT rt.id, rt.used_at, rt.expires_at, s.id, s.user_id, s.revoked_at
+			FROM refresh_tokens rt
+			JOIN sessions s ON s.id = rt.session_id
+			WHERE rt.token_hash = ?;
+		`, oldHash).Scan(&tokenID, &usedAt, &tokenExpiresAt, &s.ID, &s.UserID, &revokedAt)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return ErrSessionNotFound
+			}
+			return err
+		}
+
+		if revokedAt != nil {
+			return ErrSessionNotFound
+		}
+
+		now := sqliteNow()
+		if usedAt != nil {
+			_, err := tx.ExecContext(ctx, `
+				UPDATE sessions SET revoked_at = ?, revoke_reason = 'refresh_token_reuse'
+				WHERE id = ? AND revoked_at IS NULL;
+			`, now, s.ID)
+			if err != nil {
+				return err
+			}
+			reused = true
+			return nil
+		}
+
+		if now.After(tokenExpiresAt) {
+			return ErrRefreshTokenExpired
+		}
+
+		if _, err := tx.ExecContext(ctx, `UPDATE refresh_tokens SET used_at = ? WHERE id = ?`, now, tokenID); err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, `
+			INSERT INTO refresh_tokens (session_id, token_hash, created_at, expires_at)
+			VALUES (?, ?, ?, ?);
+		`, s.ID, newHash, now, expiresAt.UTC()); err != nil {
+			return err
+		}
+
+		return tx.QueryRowContext(ctx, `
+			UPDATE sessions SET last_seen_at = ?, expires_at = ?
+			WHERE id = ?
+			RETURNING user_agent, ip, created_at, last_seen_at, expires_at;
+		`, now, expiresAt.UTC(), s.ID).Scan(&s.UserAgent, &s.IP, &s.CreatedAt, &s.LastSeenAt, &s.ExpiresAt)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if reused {
+		return nil, ErrRefreshTokenReused
+	}
+	return &s, nil
+}
+
+func (r *SessionRepoSQLite) FindByRefreshHash(ctx context.Context, hash string) (*Session, error) {
+	var s Session
+	err := r.db.QueryRowContext(ctx, `
+		SELECT s.id, s.user_id, s.user_agent, s.ip, s.created_at, s.last_seen_at, s.expires_at
+		FROM refresh_tokens rt
+		JOIN sessions s ON s.id = rt.session_id
+		WHERE rt.token_hash = ? AND s.revoked_at IS NULL;
+	`, hash).Scan(&s.ID, &s.UserID, &s.UserAgent, &s.IP, &s.CreatedAt, &s.LastSeenAt, &s.ExpiresAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &s, nil
+}
+
+func (r *SessionRepoSQLite) IsActive(ctx context.Context, sessionID int64) (bool, error) {
+	now := sqliteNow()
+	var lastSeenAt time.Time
+	err := r.db.QueryRowContext(ctx, `
+		SELECT s.last_seen_at FROM sessions s
+		JOIN users u ON u.id = s.user_id
+		WHERE s.id = ? AND s.revoked_at IS NULL AND s.expires_at > ?
+		  AND u.suspended_at IS NULL;
+	`, sessionID, now).Scan(&lastSeenAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	if lastSeenAt.Before(now.Add(-time.Minute)) {
+		_, err := r.db.ExecContext(ctx, `UPDATE sessions SET last_seen_at = ? WHERE id = ?`, now, sessionID)
+		if err != nil {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+func (r *SessionRepoSQLite) ListActive(ctx context.Context, userID int) ([]Session, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT id, user_id, user_agent, ip, created_at, last_seen_at, expires_at
+		FROM sessions
+		WHERE user_id = ? AND revoked_at IS NULL AND expires_at > ?
+		ORDER BY last_seen_at DESC;
+	`, userID, sqliteNow())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := make([]Session, 0, 4)
+	for rows.Next() {
+		var s Session
+		if err := rows.Scan(&s.ID, &s.UserID, &s.UserAgent, &s.IP, &s.CreatedAt, &s.LastSeenAt, &s.ExpiresAt); err != nil {
+			return nil, err
+		}
+		out = append(out, s)
+	}
+	return out, rows.Err()
+}
+
+func (r *SessionRepoSQLite) Revoke(ctx context.Context, sessionID int64, userID int, reason string) error {
+	ok, err := changed(r.db.ExecContext(ctx, `
+		UPDATE sessions SET revoked_at = ?, revoke_reason = ?
+		WHERE id = ? AND user_id = ? AND revoked_at IS NULL;
+	`, sqliteNow(), reason, sessionID, userID))
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrSessionNotFound
+	}
+	return nil
+}
+
+func (r *SessionRepoSQLite) RevokeAllForUser(ctx context.Context, userID int, reason string) error {
+	_, err := r.db.ExecContext(ctx, `
+		UPDATE sessions SET revoked_at = ?, revoke_reason = ?
+		WHERE user_id = ? AND revoked_at IS NULL;
+	`, sqliteNow(), reason, userID)
+	return err
+}
+
+func (r *SessionRepoSQLite) RevokeOthers(ctx context.Context, userID int, keepID int64, reason string) (int64, error) {
+	res, err := r.db.ExecContext(ctx, `
+		UPDATE sessions SET revoked_at = ?, revoke_reason = ?
+		WHERE user_id = ? AND id <> ? AND revoked_at IS NULL;
+	`, sqliteNow(), reason, userID, keepID)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+type SessionMemory struct {
+	db *MemoryDB
+}
+
+func NewSessionMemory(db *MemoryDB) *SessionMemory {
+	return &SessionMemory{db: db}
+}
+
+func (r *SessionMemory) Create(ctx context.Context, userID int, userAgent, ip, refreshHash string, expiresAt time.Time) (*Session, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	now := time.Now()
+	r.db.lastSessionID++
+	s := &memSession{Session: Session{
+		ID:         r.db.lastSessionID,
+		UserID:     userID,
+		UserAgent:  userAgent,
+		IP:         ip,
+		CreatedAt:  now,
+		LastSeenAt: now,
+		ExpiresAt:  expiresAt,
+	}}
+	r.db.sessions[s.ID] = s
+	r.db.refreshTokens[refreshHash] = &memRefreshToken{SessionID: s.ID, ExpiresAt: expiresAt}
+	out := s.Session
+	return &out, nil
+}
+
+func (r *SessionMemory) Rotate(ctx context.Context, oldHash, newHash string, expiresAt time.Time) (*Session, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	t := r.db.refreshTokens[oldHash]
+	if t == nil {
+		return nil, ErrSessionNotFound
+	}
+	s := r.db.sessions[t.SessionID]
+	if s == nil || s.RevokedAt != nil {
+		return nil, ErrSessionNotFound
+	}
+
+	now := time.Now()
+	if t.UsedAt != nil {
+		s.RevokedAt, s.RevokeReason = &now, "refresh_token_reuse"
+		return nil, ErrRefreshTokenReused
+	}
+	if now.After(t.ExpiresAt) {
+		return nil, ErrRefreshTokenExpired
+	}
+
+	t.UsedAt = &now
+	r.db.refreshTokens[newHash] = &memRefreshToken{SessionID: s.ID, ExpiresAt: expiresAt}
+	s.LastSeenAt, s.ExpiresAt = now, expiresAt
+	out := s.Session
+	return &out, nil
+}
+
+func (r *SessionMemory) FindByRefreshHash(ctx context.Context, hash string) (*Session, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	t := r.db.refreshTokens[hash]
+	if t == nil {
+		return nil, nil
+	}
+	s := r.db.sessions[t.SessionID]
+	if s == nil || s.RevokedAt != nil {
+		return nil, nil
+	}
+	out := s.Session
+	return &out, nil
+}
+
+func (r *SessionMemory) IsActive(ctx context.Context, sessionID int64) (bool, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	now := time.Now()
+	s := r.db.sessions[sessionID]
+	if s == nil || !r.active(s, now) {
+		return false, nil
+	}
+	if u := r.db.users[s.UserID]; u == nil || u.SuspendedAt != nil {
+		return false, nil
+	}
+	if s.LastSeenAt.Before(now.Add(-time.Minute)) {
+		s.LastSeenAt = now
+	}
+	return true, nil
+}
+
+func (r *SessionMemory) active(s *memSession, now time.Time) bool {
+	return s.RevokedAt == nil && s.ExpiresAt.After(now)
+}
+
+func (r *SessionMemory) ListActive(ctx context.Context, userID int) ([]Session, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	now := time.Now()
+	out := make([]Session, 0, 4)
+	for _, s := range r.db.sessions {
+		if s.UserID == userID && r.active(s, now) {
+			out = append(out, s.Session)
+		}
+	}
+	slices.SortFunc(out, func(a, b Session) int { return b.LastSeenAt.Compare(a.LastSeenAt) })
+	return out, nil
+}
+
+// revoke revokes the open sessions of userID that match and returns how
+// many it revoked.
+func (r *SessionMemory) revoke(userID int, reason string, match func(*memSession) bool) int64 {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	now := time.Now()
+	var n int64
+	for _, s := range r.db.sessions {
+		if s.UserID == userID && s.RevokedAt == nil && match(s) {
+			s.RevokedAt, s.RevokeReason = &now, reason
+			n++
+		}
+	}
+	return n
+}
+
+func (r *SessionMemory) Revoke(ctx context.Context, sessionID int64, userID int, reason string) error {
+	if r.revoke(userID, reason, func(s *memSession) bool { return s.ID == sessionID }) == 0 {
+		return ErrSessionNotFound
+	}
+	return nil
+}
+
+func (r *SessionMemory) RevokeAllForUser(ctx context.Context, userID int, reason string) error {
+	r.revoke(userID, reason, func(*memSession) bool { return true })
+	return nil
+}
+
+func (r *SessionMemory) RevokeOthers(ctx context.Context, userID int, keepID int64, reason string) (int64, error) {
+	return r.revoke(userID, reason, func(s *memSession) bool { return s.ID != keepID }), nil
+}
@@ -0,0 +1,95 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+// The *SQLite repositories run the same queries as their Postgres
+// counterparts, translated for SQLite on database/sql. Timestamps are text:
+// they are always bound from Go in UTC, never taken from CURRENT_TIMESTAMP,
+// so every stored value has one format and compares correctly as a string.
+//
+// The scan helpers shared with the PGX repositories check sql.ErrNoRows,
+// which pgx.ErrNoRows also matches.
+
+// sqliteNow is the current time as the *SQLite repositories store it.
+func sqliteNow() time.Time {
+	return time.Now().UTC()
+}
+
+// utcPtr converts an optional time for binding to a SQLite statement.
+func utcPtr(t *time.Time) *time.Time {
+	if t == nil {
+		return nil
+	}
+	u := t.UTC()
+	return &u
+}
+
+func sqliteTx(ctx context.Context, db *sql.DB, fn func(tx *sql.Tx) error) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// changed reports whether an Exec touched any row.
+func changed(res sql.Result, err error) (bool, error) {
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
+
+func isSQLiteUniqueViolation(err error) bool {
+	var e sqlite3.Error
+	return errors.As(err, &e) && e.ExtendedCode == sqlite3.ErrConstraintUnique
+}
+
+// placeholders returns n comma-separated bind parameters for an IN list.
+func placeholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?, ", n), ", ")
+}
+
+// jsonText encodes v for a JSON TEXT column.
+func jsonText(v any) (string, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+// jsonColumn scans a JSON TEXT column into dst.
+type jsonColumn struct {
+	dst any
+}
+
+func (c jsonColumn) Scan(src any) error {
+	switch v := src.(type) {
+	case string:
+		return json.Unmarshal([]byte(v), c.dst)
+	case []byte:
+		return json.Unmarshal(v, c.dst)
+	case nil:
+		return nil
+	}
+	return fmt.Errorf("jsonColumn: cannot scan %T", src)
+}
@@ -0,0 +1,204 @@
+package repo
+
+import (
+	"cmp"
+	"context"
+	"database/sql"
+	"slices"
+	"time"
+
+	"bookpulse/internal/models"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// StatsRepo computes the reading statistics of one user.
+type StatsRepo interface {
+	// FinishedByGenre counts finished books per genre, most read first and
+	// by name (byte order) among equals.
+	FinishedByGenre(ctx context.Context, userID int) ([]models.GenreStatDto, error)
+	// AddedByMonth counts books added per UTC calendar month ("YYYY-MM"),
+	// over the current month and the months before it, oldest first. Months
+	// without additions are left out.
+	AddedByMonth(ctx context.Context, userID, months int) ([]models.MonthStatDto, error)
+}
+
+// firstMonth returns the start of the oldest of the last months UTC calendar
+// months, so that every driver buckets additions the same way whatever the
+// time zone of the server or the database session.
+func firstMonth(now time.Time, months int) time.Time {
+	now = now.UTC()
+	return time.Date(now.Year(), now.Month()-time.Month(months-1), 1, 0, 0, 0, 0, time.UTC)
+}
+
+type StatsRepoPGX struct {
+	db *pgxpool.Pool
+}
+
+func NewStatsRepoPGX(db *pgxpool.Pool) *StatsRepoPGX {
+	return &StatsRepoPGX{db: db}
+}
+
+func (r *StatsRepoPGX) FinishedByGenre(ctx context.Context, userID int) ([]models.GenreStatDto, error) {
+	rows, err := r.db.Query(ctx, `
+		SELECT g.name AS genre, COUNT(*)::int AS cnt
+		FROM user_books ub
+		JOIN book_genres bg ON bg.book_id = ub.book_id
+		JOIN genres g ON g.id = bg.genre_id
+		WHERE ub.user_id = $1 AND ub.status = 'finished'
+		GROUP BY g.name
+		ORDER BY cnt DESC, g.name COLLATE "C" ASC;
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := make([]models.GenreStatDto, 0, 16)
+	for rows.Next() {
+		var dto models.GenreStatDto
+		if err := rows.Scan(&dto.Genre, &dto.Cnt); err != nil {
+			return nil, err
+		}
+		out = append(out, dto)
+	}
+	return out, rows.Err()
+}
+
+func (r *StatsRepoPGX) AddedByMonth(ctx context.Context, userID, months int) ([]models.MonthStatDto, error) {
+	rows, err := r.db.Query(ctx, `
+		SELECT to_char(ub.created_at AT TIME ZONE 'UTC', 'YYYY-MM') AS month,
+		       COUNT(*)::int AS cnt
+		FROM user_books ub
+		WHERE ub.user_id = $1
+		  AND ub.created_at >= $2
+		GROUP BY 1
+		ORDER BY 1;
+	`, userID, firstMonth(time.Now(), months))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := make([]models.MonthStatDto, 0, months)
+	for rows.Next() {
+		var dto models.MonthStatDto
+		if err := rows.Scan(&dto.Month, &dto.Cnt); err != nil {
+			return nil, err
+		}
+		out = append(out, dto)
+	}
+	return out, rows.Err()
+}
+
+type StatsRepoSQLite struct {
+	db *sql.DB
+}
+
+func NewStatsRepoSQLite(db *sql.DB) *StatsRepoSQLite {
+	return &StatsRepoSQLite{db: db}
+}
+
+func (r *StatsRepoSQLite) FinishedByGenre(ctx context.Context, userID int) ([]models.GenreStatDto, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT g.name AS genre, COUNT(*) AS cnt
+		FROM user_books ub
+		JOIN book_genres bg ON bg.book_id = ub.book_id
+		JOIN genres g ON g.id = bg.genre_id
+		WHERE ub.user_id = ? AND ub.status = 'finished'
+		GROUP BY g.name
+		ORDER BY cnt DESC, g.name ASC;
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := make([]models.GenreStatDto, 0, 16)
+	for rows.Next() {
+		var dto models.GenreStatDto
+		if err := rows.Scan(&dto.Genre, &dto.Cnt); err != nil {
+			return nil, err
+		}
+		out = append(out, dto)
+	}
+	return out, rows.Err()
+}
+
+func (r *StatsRepoSQLite) AddedByMonth(ctx context.Context, userID, months int) ([]models.MonthStatDto, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT strftime('%Y-%m', ub.created_at) AS month,
+		       COUNT(*) AS cnt
+		FROM user_books ub
+		WHERE ub.user_id = ?
+		  AND ub.created_at >= ?
+		GROUP BY 1
+		ORDER BY 1;
+	`, userID, firstMonth(sqliteNow(), months))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := make([]models.MonthStatDto, 0, months)
+	for rows.Next() {
+		var dto models.MonthStatDto
+		if err := rows.Scan(&dto.Month, &dto.Cnt); err != nil {
+			return nil, err
+		}
+		out = append(out, dto)
+	}
+	return out, rows.Err()
+}
+
+type StatsMemory struct {
+	db *MemoryDB
+}
+
+func NewStatsMemory(db *MemoryDB) *StatsMemory {
+	return &StatsMemory{db: db}
+}
+
+func (r *StatsMemory) FinishedByGenre(ctx context.Context, userID int) ([]models.GenreStatDto, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	counts := map[string]int{}
+	for k, e := range r.db.shelf {
+		if k.userID != userID || e.Status != "finished" {
+			continue
+		}
+		for _, g := range r.db.books[k.bookID].Genres {
+			counts[g]++
+		}
+	}
+
+	out := make([]models.GenreStatDto, 0, 16)
+	for g, n := range counts {
+		out = append(out, models.GenreStatDto{Genre: g, Cnt: n})
+	}
+	slices.SortFunc(out, func(a, b models.GenreStatDto) int {
+		return cmp.Or(cmp.Compare(b.Cnt, a.Cnt), cmp.Compare(a.Genre, b.Genre))
+	})
+	return out, nil
+}
+
+func (r *StatsMemory) AddedByMonth(ctx context.Context, userID, months int) ([]models.MonthStatDto, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	since := firstMonth(time.Now(), months)
+	counts := map[string]int{}
+	for k, e := range r.db.shelf {
+		if k.userID == userID && !e.CreatedAt.Before(since) {
+			counts[e.CreatedAt.UTC().Format("2006-01")]++
+		}
+	}
+
+	out := make([]models.MonthStatDto, 0, months)
+	for m, n := range counts {
+		out = append(out, models.MonthStatDto{Month: m, Cnt: n})
+	}
+	slices.SortFunc(out, func(a, b models.MonthStatDto) int { return cmp.Compare(a.Month, b.Month) })
+	return out, nil
+}
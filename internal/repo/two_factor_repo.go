@@ -0,0 +1,382 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type TwoFactorState struct {
+	Secret      string
+	EnabledAt   *time.Time
+	LastCounter *int64
+}
+
+func (s *TwoFactorState) Enabled() bool {
+	return s.EnabledAt != nil
+}
+
+// TwoFactorRepo keeps each user's TOTP secret, replay counter and recovery
+// codes.
+type TwoFactorRepo interface {
+	Get(ctx context.Context, userID int) (*TwoFactorState, error)
+	SetPending(ctx context.Context, userID int, secret string) (bool, error)
+	Enable(ctx context.Context, userID int, counter int64, recoveryHashes []string) error
+	Disable(ctx context.Context, userID int) error
+	UseCounter(ctx context.Context, userID int, counter int64) (bool, error)
+	UseRecoveryCode(ctx context.Context, userID int, codeHash string) (bool, error)
+	RecoveryCodesLeft(ctx context.Context, userID int) (int, error)
+	ReplaceRecoveryCodes(ctx context.Context, userID int, hashes []string) error
+}
+
+var errNoPendingTwoFactor = errors.New("no pending 2FA enrollment")
+
+type TwoFactorRepoPGX struct {
+	db *pgxpool.Pool
+}
+
+func NewTwoFactorRepoPGX(db *pgxpool.Pool) *TwoFactorRepoPGX {
+	return &TwoFactorRepoPGX{db: db}
+}
+
+func (r *TwoFactorRepoPGX) Get(ctx context.Context, userID int) (*TwoFactorState, error) {
+	var st TwoFactorState
+	var secret *string
+	err := r.db.QueryRow(ctx, `
+		SELECT totp_secret, totp_enabled_at, totp_last_counter FROM users WHERE id = $1;
+	`, userID).Scan(&secret, &st.EnabledAt, &st.LastCounter)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	if secret != nil {
+		st.Secret = *secret
+	}
+	return &st, nil
+}
+
+// SetPending stores a not yet confirmed secret. It does nothing once 2FA is
+// enabled.
+func (r *TwoFactorRepoPGX) SetPending(ctx context.Context, userID int, secret string) (bool, error) {
+	cmd, err := r.db.Exec(ctx, `
+		UPDATE users SET totp_secret = $2, totp_last_counter = NULL
+		WHERE id = $1 AND totp_enabled_at IS NULL;
+	`, userID, secret)
+	if err != nil {
+		return false, err
+	}
+	return cmd.RowsAffected() > 0, nil
+}
+
+// Enable turns 2FA on and replaces the recovery codes in one transaction.
+func (r *TwoFactorRepoPGX) Enable(ctx context.Context, userID int, counter int64, recoveryHashes []string) error {
+	return pgx.BeginFunc(ctx, r.db, func(tx pgx.Tx) error {
+		cmd, err := tx.Exec(ctx, `
+			UPDATE users SET totp_enabled_at = now(), totp_last_counter = $2
+			WHERE id = $1 AND totp_secret IS NOT NULL AND totp_enabled_at IS NULL;
+		`, userID, counter)
+		if err != nil {
+			return err
+		}
+		if cmd.RowsAffected() == 0 {
+			return errNoPendingTwoFactor
+		}
+		return replaceRecoveryCodes(ctx, tx, userID, recoveryHashes)
+	})
+}
+
+func (r *TwoFactorRepoPGX) Disable(ctx context.Context, userID int) error {
+	return pgx.BeginFunc(ctx, r.db, func(tx pgx.Tx) error {
+		if _, err := tx.Exec(ctx, `
+			UPDATE users SET totp_secret = NULL, totp_enabled_at = NULL, totp_last_counter = NULL
+			WHERE id = $1;
+		`, userID); err != nil {
+			return err
+		}
+		_, err := tx.Exec(ctx, `DELETE FROM recovery_codes WHERE user_id = $1`, userID)
+		return err
+	})
+}
+
+// UseCounter records a successfully used TOTP time step. It returns false if
+// the same or a later step was already used, which blocks code replay.
+func (r *TwoFactorRepoPGX) UseCounter(ctx context.Context, userID int, counter int64) (bool, error) {
+	cmd, err := r.db.Exec(ctx, `
+		UPDATE users SET totp_last_counter = $2
+		WHERE id = $1 AND (totp_last_counter IS NULL OR totp_last_counter < $2);
+	`, userID, counter)
+	if err != nil {
+		return false, err
+	}
+	return cmd.RowsAffected() > 0, nil
+}
+
+// UseRecoveryCode burns a recovery code; it succeeds once per code.
+func (r *TwoFactorRepoPGX) UseRecoveryCode(ctx context.Context, userID int, codeHash string) (bool, error) {
+	cmd, err := r.db.Exec(ctx, `
+		UPDATE recovery_codes SET used_at = now()
+		WHERE user_id = $1 AND code_hash = $2 AND used_at IS NULL;
+	`, userID, codeHash)
+	if err != nil {
+		return false, err
+	}
+	return cmd.RowsAffected() > 0, nil
+}
+
+func (r *TwoFactorRepoPGX) RecoveryCodesLeft(ctx context.Context, userID int) (int, error) {
+	var n int
+	err := r.db.QueryRow(ctx, `
+		SELECT COUNT(*)::int FROM recovery_codes WHERE user_id = $1 AND used_at IS NULL;
+	`, userID).Scan(&n)
+	return n, err
+}
+
+func (r *TwoFactorRepoPGX) ReplaceRecoveryCodes(ctx context.Context, userID int, hashes []string) error {
+	return pgx.BeginFunc(ctx, r.db, func(tx pgx.Tx) error {
+		return replaceRecoveryCodes(ctx, tx, userID, hashes)
+	})
+}
+
+func replaceRecoveryCodes(ctx context.Context, tx pgx.Tx, userID int, hashes []string) error {
+	if _, err := tx.Exec(ctx, `DELETE FROM recovery_codes WHERE user_id = $1`, userID); err != nil {
+		return err
+	}
+	_, err := tx.Exec(ctx, `
+		INSERT INTO recovery_codes (user_id, code_hash)
+		SELECT $1, unnest($2::text[]);
+	`, userID, hashes)
+	return err
+}
+
+type TwoFactorRepoSQLite struct {
+	db *sql.DB
+}
+
+func NewTwoFactorRepoSQLite(db *sql.DB) *TwoFactorRepoSQLite {
+	return &TwoFactorRepoSQLite{db: db}
+}
+
+func (r *TwoFactorRepoSQLite) Get(ctx context.Context, userID int) (*TwoFactorState, error) {
+	var st TwoFactorState
+	var secret *string
+	err := r.db.QueryRowContext(ctx, `
+		SELECT totp_secret, totp_enabled_at, totp_last_counter FROM users WHERE id = ?;
+	`, userID).Scan(&secret, &st.EnabledAt, &st.LastCounter)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	if secret != nil {
+		st.Secret = *secret
+	}
+	return &st, nil
+}
+
+func (r *TwoFactorRepoSQLite) SetPending(ctx context.Context, userID int, secret string) (bool, error) {
+	return changed(r.db.ExecContext(ctx, `
+		UPDATE users SET totp_secret = ?, totp_last_counter = NULL
+		WHERE id = ? AND totp_enabled_at IS NULL;
+	`, secret, userID))
+}
+
+func (r *TwoFactorRepoSQLite) Enable(ctx context.Context, userID int, counter int64, recoveryHashes []string) error {
+	return sqliteTx(ctx, r.db, func(tx *sql.Tx) error {
+		ok, err := changed(tx.ExecContext(ctx, `
+			UPDATE users SET totp_enabled_at = ?, totp_last_counter = ?
+			WHERE id = ? AND totp_secret IS NOT NULL AND totp_enabled_at IS NULL;
+		`, sqliteNow(), counter, userID))
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return errNoPendingTwoFactor
+		}
+		return replaceRecoveryCodesSQLite(ctx, tx, userID, recoveryHashes)
+	})
+}
+
+func (r *TwoFactorRepoSQLite) Disable(ctx context.Context, userID int) error {
+	return sqliteTx(ctx, r.db, func(tx *sql.Tx) error {
+		if _, err := tx.ExecContext(ctx, `
+			UPDATE users SET totp_secret = NULL, totp_enabled_at = NULL, totp_last_counter = NULL
+			WHERE id = ?;
+		`, userID); err != nil {
+			return err
+		}
+		_, err := tx.ExecContext(ctx, `DELETE FROM recovery_codes WHERE user_id = ?`, userID)
+		return err
+	})
+}
+
+func (r *TwoFactorRepoSQLite) UseCounter(ctx context.Context, userID int, counter int64) (bool, error) {
+	return changed(r.db.ExecContext(ctx, `
+		UPDATE users SET totp_last_counter = ?2
+		WHERE id = ?1 AND (totp_last_counter IS NULL OR totp_last_counter < ?2);
+	`, userID, counter))
+}
+
+func (r *TwoFactorRepoSQLite) UseRecoveryCode(ctx context.Context, userID int, codeHash string) (bool, error) {
+	return changed(r.db.ExecContext(ctx, `
+		UPDATE recovery_codes SET used_at = ?
+		WHERE user_id = ? AND code_hash = ? AND used_at IS NULL;
+	`, sqliteNow(), userID, codeHash))
+}
+
+func (r *TwoFactorRepoSQLite) RecoveryCodesLeft(ctx context.Context, userID int) (int, error) {
+	var n int
+	err := r.db.QueryRowContext(ctx, `
+		SELECT COUNT(*) FROM recovery_codes WHERE user_id = ? AND used_at IS NULL;
+	`, userID).Scan(&n)
+	return n, err
+}
+
+func (r *TwoFactorRepoSQLite) ReplaceRecoveryCodes(ctx context.Context, userID int, hashes []string) error {
+	return sqliteTx(ctx, r.db, func(tx *sql.Tx) error {
+		return replaceRecoveryCodesSQLite(ctx, tx, userID, hashes)
+	})
+}
+
+// replaceRecoveryCodesSQLite unnests the hashes with json_each.
+func replaceRecoveryCodesSQLite(ctx context.Context, tx *sql.Tx, userID int, hashes []string) error {
+	if _, err := tx.ExecContext(ctx, `DELETE FROM recovery_codes WHERE user_id = ?`, userID); err != nil {
+		return err
+	}
+	list, err := jsonText(hashes)
+	if err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO recovery_codes (user_id, code_hash, created_at)
+		SELECT ?, value, ? FROM json_each(?);
+	`, userID, sqliteNow(), list)
+	return err
+}
+
+type TwoFactorMemory struct {
+	db *MemoryDB
+}
+
+func NewTwoFactorMemory(db *MemoryDB) *TwoFactorMemory {
+	return &TwoFactorMemory{db: db}
+}
+
+// update runs fn on the user under the lock; fn reports whether it changed
+// anything.
+func (r *TwoFactorMemory) update(userID int, fn func(u *memUser) bool) bool {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	u := r.db.users[userID]
+	return u != nil && fn(u)
+}
+
+func (r *TwoFactorMemory) Get(ctx context.Context, userID int) (*TwoFactorState, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	u := r.db.users[userID]
+	if u == nil {
+		return nil, nil
+	}
+	st := &TwoFactorState{EnabledAt: u.TOTPEnabledAt, LastCounter: u.TOTPLastCounter}
+	if u.TOTPSecret != nil {
+		st.Secret = *u.TOTPSecret
+	}
+	return st, nil
+}
+
+func (r *TwoFactorMemory) SetPending(ctx context.Context, userID int, secret string) (bool, error) {
+	return r.update(userID, func(u *memUser) bool {
+		if u.TOTPEnabledAt != nil {
+			return false
+		}
+		u.TOTPSecret, u.TOTPLastCounter = &secret, nil
+		return true
+	}), nil
+}
+
+func (r *TwoFactorMemory) Enable(ctx context.Context, userID int, counter int64, recoveryHashes []string) error {
+	ok := r.update(userID, func(u *memUser) bool {
+		if u.TOTPSecret == nil || u.TOTPEnabledAt != nil {
+			return false
+		}
+		now := time.Now()
+		u.TOTPEnabledAt, u.TOTPLastCounter = &now, &counter
+		u.RecoveryCodes = newRecoveryCodes(recoveryHashes)
+		return true
+	})
+	if !ok {
+		return errNoPendingTwoFactor
+	}
+	return nil
+}
+
+func (r *TwoFactorMemory) Disable(ctx context.Context, userID int) error {
+	r.update(userID, func(u *memUser) bool {
+		u.TOTPSecret, u.TOTPEnabledAt, u.TOTPLastCounter = nil, nil, nil
+		u.RecoveryCodes = nil
+		return true
+	})
+	return nil
+}
+
+func (r *TwoFactorMemory) UseCounter(ctx context.Context, userID int, counter int64) (bool, error) {
+	return r.update(userID, func(u *memUser) bool {
+		if u.TOTPLastCounter != nil && *u.TOTPLastCounter >= counter {
+			return false
+		}
+		u.TOTPLastCounter = &counter
+		return true
+	}), nil
+}
+
+func (r *TwoFactorMemory) UseRecoveryCode(ctx context.Context, userID int, codeHash string) (bool, error) {
+	return r.update(userID, func(u *memUser) bool {
+		for i := range u.RecoveryCodes {
+			c := &u.RecoveryCodes[i]
+			if c.Hash == codeHash && c.UsedAt == nil {
+				now := time.Now()
+				c.UsedAt = &now
+				return true
+			}
+		}
+		return false
+	}), nil
+}
+
+func (r *TwoFactorMemory) RecoveryCodesLeft(ctx context.Context, userID int) (int, error) {
+	var n int
+	r.update(userID, func(u *memUser) bool {
+		for _, c := range u.RecoveryCodes {
+			if c.UsedAt == nil {
+				n++
+			}
+		}
+		return false
+	})
+	return n, nil
+}
+
+func (r *TwoFactorMemory) ReplaceRecoveryCodes(ctx context.Context, userID int, hashes []string) error {
+	r.update(userID, func(u *memUser) bool {
+		u.RecoveryCodes = newRecoveryCodes(hashes)
+		return true
+	})
+	return nil
+}
+
+func newRecoveryCodes(hashes []string) []memRecoveryCode {
+	codes := make([]memRecoveryCode, 0, len(hashes))
+	for _, h := range hashes {
+		codes = append(codes, memRecoveryCode{Hash: h})
+	}
+	return codes
+}
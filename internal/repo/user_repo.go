@@ -2,17 +2,80 @@ package repo
 
 import (
 	"context"
+	"database/sql"
 	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
 
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
 type User struct {
-	ID           int    `json:"id"`
-	Email        string `json:"email"`
-	Name         string `json:"name"`
-	PasswordHash string `json:"-"`
+	ID              int        `json:"id"`
+	Email           string     `json:"email"`
+	Name            string     `json:"name"`
+	PasswordHash    string     `json:"-"`
+	EmailVerifiedAt *time.Time `json:"emailVerifiedAt"`
+	TOTPEnabledAt   *time.Time `json:"-"`
+	Role            string     `json:"role"`
+	SuspendedAt     *time.Time `json:"suspendedAt"`
+	// DeletionRequestedAt is set while the account waits to be purged.
+	DeletionRequestedAt *time.Time `json:"deletionRequestedAt"`
+}
+
+func (u *User) EmailVerified() bool {
+	return u.EmailVerifiedAt != nil
+}
+
+func (u *User) TwoFactorEnabled() bool {
+	return u.TOTPEnabledAt != nil
+}
+
+func (u *User) Suspended() bool {
+	return u.SuspendedAt != nil
+}
+
+const userColumns = `id, email, name, password_hash, email_verified_at, totp_enabled_at, role, suspended_at, deletion_requested_at`
+
+func scanUser(row pgx.Row) (*User, error) {
+	var u User
+	err := row.Scan(&u.ID, &u.Email, &u.Name, &u.PasswordHash, &u.EmailVerifiedAt, &u.TOTPEnabledAt, &u.Role, &u.SuspendedAt, &u.DeletionRequestedAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &u, nil
+}
+
+// UserRepo keeps accounts. Lookups return nil, nil for unknown users.
+type UserRepo interface {
+	Create(ctx context.Context, email, name, passwordHash string) (*User, error)
+	FindByEmail(ctx context.Context, email string) (*User, error)
+	FindByID(ctx context.Context, id int) (*User, error)
+	UpdatePassword(ctx context.Context, id int, passwordHash string) error
+	UpdateName(ctx context.Context, id int, name string) (previous string, ok bool, err error)
+	MarkEmailVerified(ctx context.Context, id int, email string) (bool, error)
+	ClaimVerificationSend(ctx context.Context, id int, notBefore time.Time) (bool, *time.Time, error)
+	CreateExternal(ctx context.Context, email, name string, emailVerified bool) (*User, error)
+	SetRole(ctx context.Context, id int, role string) (bool, error)
+	BootstrapAdmin(ctx context.Context, email string) (*User, error)
+	RequestDeletion(ctx context.Context, id int) (time.Time, error)
+	CancelDeletion(ctx context.Context, id int) (bool, error)
+	DueForPurge(ctx context.Context, cutoff time.Time, limit int) ([]int, error)
+	// Purge is Delete for an account whose deletion is still pending and
+	// was requested before cutoff; otherwise it returns false and keeps it.
+	Purge(ctx context.Context, id int, cutoff time.Time, anonymizeReviews bool) (bool, error)
+
+	SearchUsers(ctx context.Context, q string, limit, offset int) ([]UserSummary, int, error)
+	Summary(ctx context.Context, id int) (*UserSummary, error)
+	SetSuspended(ctx context.Context, id int, suspend bool, reason string) (bool, error)
+	ClearPassword(ctx context.Context, id int) error
+	Delete(ctx context.Context, id int, anonymizeReviews bool) (bool, error)
 }
 
 type UserRepoPGX struct {
@@ -24,51 +87,598 @@ func NewUserRepoPGX(db *pgxpool.Pool) *UserRepoPGX {
 }
 
 func (r *UserRepoPGX) Create(ctx context.Context, email, name, passwordHash string) (*User, error) {
-	var u User
-	err := r.db.QueryRow(ctx, `
+	u, err := scanUser(r.db.QueryRow(ctx, `
 		INSERT INTO users (email, name, password_hash)
 		VALUES ($1, $2, $3)
-		RETURNING id, email, name, password_hash;
-	`, email, name, passwordHash).Scan(&u.ID, &u.Email, &u.Name, &u.PasswordHash)
+		RETURNING `+userColumns+`;
+	`, email, name, passwordHash))
 	if err != nil {
 		return nil, err
 	}
-	return &u, nil
+	return u, nil
 }
 
 func (r *UserRepoPGX) FindByEmail(ctx context.Context, email string) (*User, error) {
- var u User
- err := r.db.QueryRow(ctx, `
-  SELECT id, email, name, password_hash
-  FROM users
-  WHERE email = $1;
- `, email).Scan(&u.ID, &u.Email, &u.Name, &u.PasswordHash)
-
- if err != nil {
-  if errors.Is(err, pgx.ErrNoRows) {
-   return nil, nil 
-  }
-  return nil, err 
- }
- return &u, nil
+	return scanUser(r.db.QueryRow(ctx, `
+		SELECT `+userColumns+`
+		FROM users
+		WHERE lower(email) = lower($1);
+	`, email))
 }
 
 func (r *UserRepoPGX) FindByID(ctx context.Context, id int) (*User, error) {
- var u User
- err := r.db.QueryRow(ctx, `
-  SELECT id, email, name, password_hash
-  FROM users
-  WHERE id = $1;
- `, id).Scan(&u.ID, &u.Email, &u.Name, &u.PasswordHash)
+	return scanUser(r.db.QueryRow(ctx, `
+		SELECT `+userColumns+`
+		FROM users
+		WHERE id = $1;
+	`, id))
+}
+
+var ErrInvalidCredentials = errors.New("invalid credentials")
 
- if err != nil {
-  if errors.Is(err, pgx.ErrNoRows) {
-   return nil, nil
-  }
-  return nil, err
- }
- return &u, nil
+func (r *UserRepoPGX) UpdatePassword(ctx context.Context, id int, passwordHash string) error {
+	_, err := r.db.Exec(ctx, `UPDATE users SET password_hash=$2 WHERE id=$1`, id, passwordHash)
+	return err
 }
 
+// UpdateName renames the user and returns the previous name. ok is false when
+// the user does not exist.
+func (r *UserRepoPGX) UpdateName(ctx context.Context, id int, name string) (previous string, ok bool, err error) {
+	err = r.db.QueryRow(ctx, `
+		UPDATE users SET name = $2
+		FROM users old
+		WHERE users.id = $1 AND old.id = users.id
+		RETURNING old.name;
+	`, id, name).Scan(&previous)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, err
+	}
+	return previous, true, nil
+}
 
-var ErrInvalidCredentials = errors.New("invalid credentials")
+// MarkEmailVerified verifies the account only if its address is still email,
+// so a link sent before an address change cannot verify the new one.
+func (r *UserRepoPGX) MarkEmailVerified(ctx context.Context, id int, email string) (bool, error) {
+	cmd, err := r.db.Exec(ctx, `
+		UPDATE users SET email_verified_at = COALESCE(email_verified_at, now())
+		WHERE id = $1 AND lower(email) = lower($2);
+	`, id, email)
+	if err != nil {
+		return false, err
+	}
+	return cmd.RowsAffected() > 0, nil
+}
+
+// ClaimVerificationSend records that a verification email is about to be sent.
+// It returns false when the account is already verified or the previous email
+// went out after notBefore.
+func (r *UserRepoPGX) ClaimVerificationSend(ctx context.Context, id int, notBefore time.Time) (bool, *time.Time, error) {
+	var sentAt *time.Time
+	err := r.db.QueryRow(ctx, `
+		UPDATE users SET verification_sent_at = now()
+		WHERE id = $1
+		  AND email_verified_at IS NULL
+		  AND (verification_sent_at IS NULL OR verification_sent_at < $2)
+		RETURNING verification_sent_at;
+	`, id, notBefore).Scan(&sentAt)
+	if err == nil {
+		return true, sentAt, nil
+	}
+	if !errors.Is(err, pgx.ErrNoRows) {
+		return false, nil, err
+	}
+
+	err = r.db.QueryRow(ctx, `SELECT verification_sent_at FROM users WHERE id = $1`, id).Scan(&sentAt)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return false, nil, err
+	}
+	return false, sentAt, nil
+}
+
+// CreateExternal creates an account for a social login. It has no password
+// until the user sets one through the reset flow.
+func (r *UserRepoPGX) CreateExternal(ctx context.Context, email, name string, emailVerified bool) (*User, error) {
+	return scanUser(r.db.QueryRow(ctx, `
+		INSERT INTO users (email, name, password_hash, email_verified_at)
+		VALUES ($1, $2, '', CASE WHEN $3 THEN now() END)
+		RETURNING `+userColumns+`;
+	`, email, name, emailVerified))
+}
+
+var ErrAdminExists = errors.New("an admin already exists")
+
+// SetRole changes the role of a user. It returns false if there is no such
+// user.
+func (r *UserRepoPGX) SetRole(ctx context.Context, id int, role string) (bool, error) {
+	cmd, err := r.db.Exec(ctx, `UPDATE users SET role = $2 WHERE id = $1`, id, role)
+	if err != nil {
+		return false, err
+	}
+	return cmd.RowsAffected() > 0, nil
+}
+
+// BootstrapAdmin promotes the user with this email to admin, but only while
+// no admin exists yet. Concurrent calls are serialised by an advisory lock.
+func (r *UserRepoPGX) BootstrapAdmin(ctx context.Context, email string) (*User, error) {
+	var u *User
+	err := pgx.BeginFunc(ctx, r.db, func(tx pgx.Tx) error {
+		if _, err := tx.Exec(ctx, `SELECT pg_advisory_xact_lock(hashtext('bookpulse_bootstrap_admin'))`); err != nil {
+			return err
+		}
+
+		var exists bool
+		if err := tx.QueryRow(ctx, `SELECT EXISTS(SELECT 1 FROM users WHERE role = 'admin')`).Scan(&exists); err != nil {
+			return err
+		}
+		if exists {
+			return ErrAdminExists
+		}
+
+		var err error
+		u, err = scanUser(tx.QueryRow(ctx, `
+			UPDATE users SET role = 'admin'
+			WHERE lower(email) = lower($1)
+			RETURNING `+userColumns+`;
+		`, email))
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return u, nil
+}
+
+// RequestDeletion schedules the account for purging. It keeps the original
+// request time if one is already pending.
+func (r *UserRepoPGX) RequestDeletion(ctx context.Context, id int) (time.Time, error) {
+	var at time.Time
+	err := r.db.QueryRow(ctx, `
+		UPDATE users SET deletion_requested_at = COALESCE(deletion_requested_at, now())
+		WHERE id = $1
+		RETURNING deletion_requested_at;
+	`, id).Scan(&at)
+	return at, err
+}
+
+// CancelDeletion restores an account scheduled for purging. It returns false
+// if no deletion was pending.
+func (r *UserRepoPGX) CancelDeletion(ctx context.Context, id int) (bool, error) {
+	cmd, err := r.db.Exec(ctx, `
+		UPDATE users SET deletion_requested_at = NULL
+		WHERE id = $1 AND deletion_requested_at IS NOT NULL;
+	`, id)
+	if err != nil {
+		return false, err
+	}
+	return cmd.RowsAffected() > 0, nil
+}
+
+// DueForPurge returns users whose deletion was requested before cutoff.
+func (r *UserRepoPGX) DueForPurge(ctx context.Context, cutoff time.Time, limit int) ([]int, error) {
+	rows, err := r.db.Query(ctx, `
+		SELECT id FROM users
+		WHERE deletion_requested_at IS NOT NULL AND deletion_requested_at <= $1
+		ORDER BY deletion_requested_at
+		LIMIT $2;
+	`, cutoff, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// Purge locks the user row first, so a CancelDeletion that lands after
+// DueForPurge either wins or waits for the purge.
+func (r *UserRepoPGX) Purge(ctx context.Context, id int, cutoff time.Time, anonymizeReviews bool) (bool, error) {
+	var purged bool
+	err := pgx.BeginFunc(ctx, r.db, func(tx pgx.Tx) error {
+		var due bool
+		err := tx.QueryRow(ctx, `
+			SELECT true FROM users
+			WHERE id = $1 AND deletion_requested_at IS NOT NULL AND deletion_requested_at <= $2
+			FOR UPDATE;
+		`, id, cutoff).Scan(&due)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		purged, err = deleteUser(ctx, tx, id, anonymizeReviews)
+		return err
+	})
+	return purged, err
+}
+
+type UserRepoSQLite struct {
+	db *sql.DB
+}
+
+func NewUserRepoSQLite(db *sql.DB) *UserRepoSQLite {
+	return &UserRepoSQLite{db: db}
+}
+
+func (r *UserRepoSQLite) Create(ctx context.Context, email, name, passwordHash string) (*User, error) {
+	return scanUser(r.db.QueryRowContext(ctx, `
+		INSERT INTO users (email, name, password_hash, created_at)
+		VALUES (?, ?, ?, ?)
+		RETURNING `+userColumns+`;
+	`, email, name, passwordHash, sqliteNow()))
+}
+
+func (r *UserRepoSQLite) FindByEmail(ctx context.Context, email string) (*User, error) {
+	return scanUser(r.db.QueryRowContext(ctx, `
+		SELECT `+userColumns+`
+		FROM users
+		WHERE email = ? COLLATE NOCASE;
+	`, email))
+}
+
+func (r *UserRepoSQLite) FindByID(ctx context.Context, id int) (*User, error) {
+	return scanUser(r.db.QueryRowContext(ctx, `
+		SELECT `+userColumns+`
+		FROM users
+		WHERE id = ?;
+	`, id))
+}
+
+func (r *UserRepoSQLite) UpdatePassword(ctx context.Context, id int, passwordHash string) error {
+	_, err := r.db.ExecContext(ctx, `UPDATE users SET password_hash = ? WHERE id = ?`, passwordHash, id)
+	return err
+}
+
+func (r *UserRepoSQLite) UpdateName(ctx context.Context, id int, name string) (previous string, ok bool, err error) {
+	err = sqliteTx(ctx, r.db, func(tx *sql.Tx) error {
+		if err := tx.QueryRowContext(ctx, `SELECT name FROM users WHERE id = ?`, id).Scan(&previous); err != nil {
+			return err
+		}
+		_, err := tx.ExecContext(ctx, `UPDATE users SET name = ? WHERE id = ?`, name, id)
+		return err
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, err
+	}
+	return previous, true, nil
+}
+
+func (r *UserRepoSQLite) MarkEmailVerified(ctx context.Context, id int, email string) (bool, error) {
+	return changed(r.db.ExecContext(ctx, `
+		UPDATE users SET email_verified_at = COALESCE(email_verified_at, ?)
+		WHERE id = ? AND email = ? COLLATE NOCASE;
+	`, sqliteNow(), id, email))
+}
+
+func (r *UserRepoSQLite) ClaimVerificationSend(ctx context.Context, id int, notBefore time.Time) (bool, *time.Time, error) {
+	var sentAt *time.Time
+	err := r.db.QueryRowContext(ctx, `
+		UPDATE users SET verification_sent_at = ?
+		WHERE id = ?
+		  AND email_verified_at IS NULL
+		  AND (verification_sent_at IS NULL OR verification_sent_at < ?)
+		RETURNING verification_sent_at;
+	`, sqliteNow(), id, notBefore.UTC()).Scan(&sentAt)
+	if err == nil {
+		return true, sentAt, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return false, nil, err
+	}
+
+	err = r.db.QueryRowContext(ctx, `SELECT verification_sent_at FROM users WHERE id = ?`, id).Scan(&sentAt)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return false, nil, err
+	}
+	return false, sentAt, nil
+}
+
+func (r *UserRepoSQLite) CreateExternal(ctx context.Context, email, name string, emailVerified bool) (*User, error) {
+	now := sqliteNow()
+	var verifiedAt *time.Time
+	if emailVerified {
+		verifiedAt = &now
+	}
+	return scanUser(r.db.QueryRowContext(ctx, `
+		INSERT INTO users (email, name, password_hash, email_verified_at, created_at)
+		VALUES (?, ?, '', ?, ?)
+		RETURNING `+userColumns+`;
+	`, email, name, verifiedAt, now))
+}
+
+func (r *UserRepoSQLite) SetRole(ctx context.Context, id int, role string) (bool, error) {
+	return changed(r.db.ExecContext(ctx, `UPDATE users SET role = ? WHERE id = ?`, role, id))
+}
+
+// BootstrapAdmin promotes the user with this email to admin, but only while
+// no admin exists yet. The write transaction serialises concurrent calls.
+func (r *UserRepoSQLite) BootstrapAdmin(ctx context.Context, email string) (*User, error) {
+	var u *User
+	err := sqliteTx(ctx, r.db, func(tx *sql.Tx) error {
+		var exists bool
+		if err := tx.QueryRowContext(ctx, `SELECT EXISTS(SELECT 1 FROM users WHERE role = 'admin')`).Scan(&exists); err != nil {
+			return err
+		}
+		if exists {
+			return ErrAdminExists
+		}
+
+		var err error
+		u, err = scanUser(tx.QueryRowContext(ctx, `
+			UPDATE users SET role = 'admin'
+			WHERE email = ? COLLATE NOCASE
+			RETURNING `+userColumns+`;
+		`, email))
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return u, nil
+}
+
+func (r *UserRepoSQLite) RequestDeletion(ctx context.Context, id int) (time.Time, error) {
+	var at time.Time
+	err := r.db.QueryRowContext(ctx, `
+		UPDATE users SET deletion_requested_at = COALESCE(deletion_requested_at, ?)
+		WHERE id = ?
+		RETURNING deletion_requested_at;
+	`, sqliteNow(), id).Scan(&at)
+	return at, err
+}
+
+func (r *UserRepoSQLite) CancelDeletion(ctx context.Context, id int) (bool, error) {
+	return changed(r.db.ExecContext(ctx, `
+		UPDATE users SET deletion_requested_at = NULL
+		WHERE id = ? AND deletion_requested_at IS NOT NULL;
+	`, id))
+}
+
+func (r *UserRepoSQLite) DueForPurge(ctx context.Context, cutoff time.Time, limit int) ([]int, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT id FROM users
+		WHERE deletion_requested_at IS NOT NULL AND deletion_requested_at <= ?
+		ORDER BY deletion_requested_at
+		LIMIT ?;
+	`, cutoff.UTC(), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// Purge needs no row lock: the transaction holds SQLite's write lock.
+func (r *UserRepoSQLite) Purge(ctx context.Context, id int, cutoff time.Time, anonymizeReviews bool) (bool, error) {
+	var purged bool
+	err := sqliteTx(ctx, r.db, func(tx *sql.Tx) error {
+		var due bool
+		err := tx.QueryRowContext(ctx, `
+			SELECT EXISTS(
+				SELECT 1 FROM users
+				WHERE id = ? AND deletion_requested_at IS NOT NULL AND deletion_requested_at <= ?
+			);
+		`, id, cutoff.UTC()).Scan(&due)
+		if err != nil || !due {
+			return err
+		}
+		purged, err = deleteUserSQLite(ctx, tx, id, anonymizeReviews)
+		return err
+	})
+	return purged, err
+}
+
+type UserMemory struct {
+	db *MemoryDB
+}
+
+func NewUserMemory(db *MemoryDB) *UserMemory {
+	return &UserMemory{db: db}
+}
+
+func (r *UserMemory) Create(ctx context.Context, email, name, passwordHash string) (*User, error) {
+	return r.insert(&memUser{Email: email, Name: name, PasswordHash: passwordHash})
+}
+
+func (r *UserMemory) insert(u *memUser) (*User, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	if r.db.userByEmail(u.Email) != nil {
+		return nil, fmt.Errorf("user %q: email already exists", u.Email)
+	}
+	r.db.lastUserID++
+	u.ID = r.db.lastUserID
+	u.CreatedAt = time.Now()
+	u.Role = "user"
+	r.db.users[u.ID] = u
+	return u.user(), nil
+}
+
+func (r *UserMemory) FindByEmail(ctx context.Context, email string) (*User, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	if u := r.db.userByEmail(email); u != nil {
+		return u.user(), nil
+	}
+	return nil, nil
+}
+
+func (r *UserMemory) FindByID(ctx context.Context, id int) (*User, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	if u := r.db.users[id]; u != nil {
+		return u.user(), nil
+	}
+	return nil, nil
+}
+
+// update runs fn on the user under the lock and reports whether the user
+// exists.
+func (r *UserMemory) update(id int, fn func(u *memUser)) bool {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	u := r.db.users[id]
+	if u == nil {
+		return false
+	}
+	fn(u)
+	return true
+}
+
+func (r *UserMemory) UpdatePassword(ctx context.Context, id int, passwordHash string) error {
+	r.update(id, func(u *memUser) { u.PasswordHash = passwordHash })
+	return nil
+}
+
+func (r *UserMemory) UpdateName(ctx context.Context, id int, name string) (previous string, ok bool, err error) {
+	ok = r.update(id, func(u *memUser) {
+		previous = u.Name
+		u.Name = name
+	})
+	return previous, ok, nil
+}
+
+func (r *UserMemory) MarkEmailVerified(ctx context.Context, id int, email string) (bool, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	u := r.db.users[id]
+	if u == nil || !strings.EqualFold(u.Email, email) {
+		return false, nil
+	}
+	if u.EmailVerifiedAt == nil {
+		now := time.Now()
+		u.EmailVerifiedAt = &now
+	}
+	return true, nil
+}
+
+func (r *UserMemory) ClaimVerificationSend(ctx context.Context, id int, notBefore time.Time) (bool, *time.Time, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	u := r.db.users[id]
+	if u == nil {
+		return false, nil, nil
+	}
+	if u.EmailVerifiedAt != nil || (u.VerificationSentAt != nil && !u.VerificationSentAt.Before(notBefore)) {
+		return false, u.VerificationSentAt, nil
+	}
+	now := time.Now()
+	u.VerificationSentAt = &now
+	return true, &now, nil
+}
+
+func (r *UserMemory) CreateExternal(ctx context.Context, email, name string, emailVerified bool) (*User, error) {
+	u := &memUser{Email: email, Name: name}
+	if emailVerified {
+		now := time.Now()
+		u.EmailVerifiedAt = &now
+	}
+	return r.insert(u)
+}
+
+func (r *UserMemory) SetRole(ctx context.Context, id int, role string) (bool, error) {
+	return r.update(id, func(u *memUser) { u.Role = role }), nil
+}
+
+func (r *UserMemory) BootstrapAdmin(ctx context.Context, email string) (*User, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	for _, u := range r.db.users {
+		if u.Role == "admin" {
+			return nil, ErrAdminExists
+		}
+	}
+	u := r.db.userByEmail(email)
+	if u == nil {
+		return nil, nil
+	}
+	u.Role = "admin"
+	return u.user(), nil
+}
+
+func (r *UserMemory) RequestDeletion(ctx context.Context, id int) (time.Time, error) {
+	var at time.Time
+	ok := r.update(id, func(u *memUser) {
+		if u.DeletionRequestedAt == nil {
+			now := time.Now()
+			u.DeletionRequestedAt = &now
+		}
+		at = *u.DeletionRequestedAt
+	})
+	if !ok {
+		return time.Time{}, fmt.Errorf("user %d not found", id)
+	}
+	return at, nil
+}
+
+func (r *UserMemory) CancelDeletion(ctx context.Context, id int) (bool, error) {
+	var pending bool
+	r.update(id, func(u *memUser) {
+		pending = u.DeletionRequestedAt != nil
+		u.DeletionRequestedAt = nil
+	})
+	return pending, nil
+}
+
+func (r *UserMemory) DueForPurge(ctx context.Context, cutoff time.Time, limit int) ([]int, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	var due []*memUser
+	for _, u := range r.db.users {
+		if u.DeletionRequestedAt != nil && !u.DeletionRequestedAt.After(cutoff) {
+			due = append(due, u)
+		}
+	}
+	slices.SortFunc(due, func(a, b *memUser) int {
+		return a.DeletionRequestedAt.Compare(*b.DeletionRequestedAt)
+	})
+
+	var ids []int
+	for _, u := range due[:min(limit, len(due))] {
+		ids = append(ids, u.ID)
+	}
+	return ids, nil
+}
+
+func (r *UserMemory) Purge(ctx context.Context, id int, cutoff time.Time, anonymizeReviews bool) (bool, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	u := r.db.users[id]
+	if u == nil || u.DeletionRequestedAt == nil || u.DeletionRequestedAt.After(cutoff) {
+		return false, nil
+	}
+	r.db.deleteUser(id, anonymizeReviews)
+	return true, nil
+}
@@ -0,0 +1,112 @@
+// Package request decodes and validates JSON request bodies. Every problem
+// comes back as a *respond.Error ready for respond.WriteError: bad_json,
+// body_too_large, or validation_failed listing each invalid field at once.
+package request
+
+import (
+	"bookpulse/internal/respond"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// Field error codes, see respond.FieldError.
+const (
+	CodeRequired     = "required"
+	CodeTooShort     = "too_short"
+	CodeTooLong      = "too_long"
+	CodeOutOfRange   = "out_of_range"
+	CodeInvalid      = "invalid"
+	CodeInvalidType  = "invalid_type"
+	CodeUnknownField = "unknown_field"
+
+	CodeBodyTooLarge = "body_too_large"
+)
+
+// Options are set once per server with WithOptions.
+type Options struct {
+	MaxBodyBytes int64
+	// Strict rejects fields the target struct does not have.
+	Strict bool
+}
+
+// DefaultOptions apply to requests that did not pass through WithOptions.
+var DefaultOptions = Options{MaxBodyBytes: 1 << 20}
+
+type optionsKey struct{}
+
+// WithOptions makes Decode use opts for every request below it.
+func WithOptions(next http.Handler, opts Options) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), optionsKey{}, opts)))
+	})
+}
+
+func optionsFrom(ctx context.Context) Options {
+	if opts, ok := ctx.Value(optionsKey{}).(Options); ok {
+		return opts
+	}
+	return DefaultOptions
+}
+
+// Decode reads the JSON body of r into v, a pointer to a struct, and then
+// validates it (see Validate).
+func Decode(r *http.Request, v any) error {
+	opts := optionsFrom(r.Context())
+	body := http.MaxBytesReader(nil, r.Body, opts.MaxBodyBytes)
+
+	dec := json.NewDecoder(body)
+	if opts.Strict {
+		dec.DisallowUnknownFields()
+	}
+	if err := dec.Decode(v); err != nil {
+		return decodeError(err)
+	}
+	if _, err := dec.Token(); !errors.Is(err, io.EOF) {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			return decodeError(err)
+		}
+		return respond.BadRequest(respond.CodeBadJSON, "request body must be a single JSON value")
+	}
+	return Validate(v)
+}
+
+func decodeError(err error) error {
+	var tooLarge *http.MaxBytesError
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case errors.Is(err, io.EOF):
+		return respond.BadRequest(respond.CodeBadJSON, "request body is empty")
+	case errors.As(err, &tooLarge):
+		return respond.NewError(http.StatusRequestEntityTooLarge, CodeBodyTooLarge,
+			fmt.Sprintf("request body must be at most %d bytes", tooLarge.Limit))
+	case errors.As(err, &typeErr) && typeErr.Field != "":
+		return respond.Field(typeErr.Field, CodeInvalidType, typeErr.Field+" must be "+jsonKind(typeErr.Type.Kind().String()))
+	case strings.HasPrefix(err.Error(), "json: unknown field "):
+		// encoding/json has no error type for this one
+		name := strings.Trim(strings.TrimPrefix(err.Error(), "json: unknown field "), `"`)
+		return respond.Field(name, CodeUnknownField, "unknown field")
+	}
+	return respond.BadJSON()
+}
+
+func jsonKind(goKind string) string {
+	switch {
+	case goKind == "string":
+		return "a string"
+	case goKind == "bool":
+		return "a boolean"
+	case strings.HasPrefix(goKind, "int"), strings.HasPrefix(goKind, "uint"):
+		return "an integer"
+	case strings.HasPrefix(goKind, "float"):
+		return "a number"
+	case goKind == "slice", goKind == "array":
+		return "an array"
+	}
+	return "an object"
+}
@@ -0,0 +1,114 @@
+package request
+
+import (
+	"bookpulse/internal/respond"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+)
+
+type bookBody struct {
+	Title    string   `json:"title" validate:"required,max=5"`
+	Rating   int      `json:"rating" validate:"min=1,max=5"`
+	Status   string   `json:"status" validate:"oneof=planned reading"`
+	Tags     []string `json:"tags" validate:"max=2"`
+	GoogleID string   `json:"googleId"`
+	ID       string   `json:"id"`
+}
+
+func (b *bookBody) Validate(errs *Errors) {
+	if b.GoogleID == "" && b.ID == "" {
+		errs.Add("googleId", CodeRequired, "googleId or id is required")
+	}
+}
+
+// codes returns field:code for every field error of err.
+func codes(t *testing.T, err error) []string {
+	t.Helper()
+	var e *respond.Error
+	if !errors.As(err, &e) {
+		t.Fatalf("error = %v; want a *respond.Error", err)
+	}
+	if e.Code != respond.CodeValidation {
+		t.Fatalf("code = %s (%s); want %s", e.Code, e.Message, respond.CodeValidation)
+	}
+	var out []string
+	for _, f := range e.Details.(map[string]any)["fields"].([]respond.FieldError) {
+		out = append(out, f.Field+":"+f.Code)
+	}
+	return out
+}
+
+func TestValidateReportsEveryField(t *testing.T) {
+	b := &bookBody{Title: "  ", Rating: 9, Status: "lost", Tags: []string{"a", "b", "c"}}
+	got := codes(t, Validate(b))
+	want := []string{
+		"title:" + CodeRequired,
+		"rating:" + CodeOutOfRange,
+		"status:" + CodeInvalid,
+		"tags:" + CodeTooLong,
+		"googleId:" + CodeRequired,
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("field errors = %q; want %q", got, want)
+	}
+
+	// max counts characters, not bytes; empty optional fields skip rules
+	if err := Validate(&bookBody{Title: "ёжики", ID: "x"}); err != nil {
+		t.Errorf("Validate = %v; want nil", err)
+	}
+}
+
+func decode(opts Options, body string) error {
+	r := httptest.NewRequest("POST", "/", strings.NewReader(body))
+	r = r.WithContext(context.WithValue(r.Context(), optionsKey{}, opts))
+	var b bookBody
+	return Decode(r, &b)
+}
+
+func TestDecode(t *testing.T) {
+	lax := Options{MaxBodyBytes: 64}
+	strict := Options{MaxBodyBytes: 64, Strict: true}
+
+	tests := []struct {
+		name   string
+		opts   Options
+		body   string
+		status int
+		code   string
+	}{
+		{"valid", strict, `{"title":"Dune","id":"x"}`, 0, ""},
+		{"unknown field lax", lax, `{"title":"Dune","id":"x","extra":1}`, 0, ""},
+		{"unknown field strict", strict, `{"title":"Dune","id":"x","extra":1}`, http.StatusBadRequest, respond.CodeValidation},
+		{"empty", strict, ``, http.StatusBadRequest, respond.CodeBadJSON},
+		{"malformed", strict, `{"title":`, http.StatusBadRequest, respond.CodeBadJSON},
+		{"trailing value", strict, `{"title":"Dune","id":"x"} {}`, http.StatusBadRequest, respond.CodeBadJSON},
+		{"wrong type", strict, `{"title":"Dune","rating":"five"}`, http.StatusBadRequest, respond.CodeValidation},
+		{"too large", strict, `{"title":"` + strings.Repeat("x", 100) + `"}`, http.StatusRequestEntityTooLarge, CodeBodyTooLarge},
+		{"invalid", strict, `{"title":"Dune","rating":7,"id":"x"}`, http.StatusBadRequest, respond.CodeValidation},
+	}
+	for _, tt := range tests {
+		err := decode(tt.opts, tt.body)
+		if tt.code == "" {
+			if err != nil {
+				t.Errorf("%s: Decode = %v; want nil", tt.name, err)
+			}
+			continue
+		}
+		var e *respond.Error
+		if !errors.As(err, &e) || e.Status != tt.status || e.Code != tt.code {
+			t.Errorf("%s: Decode = %v; want %d %s", tt.name, err, tt.status, tt.code)
+		}
+	}
+
+	if got := codes(t, decode(strict, `{"title":"Dune","id":"x","extra":1}`)); !slices.Equal(got, []string{"extra:" + CodeUnknownField}) {
+		t.Errorf("unknown field errors = %q", got)
+	}
+	if got := codes(t, decode(strict, `{"title":"Dune","rating":"five"}`)); !slices.Equal(got, []string{"rating:" + CodeInvalidType}) {
+		t.Errorf("wrong type errors = %q", got)
+	}
+}
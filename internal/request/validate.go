@@ -0,0 +1,163 @@
+package request
+
+import (
+	"bookpulse/internal/respond"
+	"fmt"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// Errors collects field errors. Validator implementations add to it.
+type Errors []respond.FieldError
+
+func (e *Errors) Add(field, code, message string) {
+	*e = append(*e, respond.FieldError{Field: field, Code: code, Message: message})
+}
+
+// Validator is implemented by request bodies with rules that tags cannot
+// express, such as "one of these two fields". It runs after the tag rules.
+type Validator interface {
+	Validate(errs *Errors)
+}
+
+// Validate checks the `validate` struct tags of v, then calls its Validator,
+// and reports every failure in one validation_failed error. Rules are comma
+// separated:
+//
+//	required       not the zero value; for strings, not blank
+//	min=N, max=N   length for strings (in characters) and slices, value for numbers
+//	oneof=a b c    one of the listed strings
+//
+// Fields that are empty and not required skip the other rules. Only the top
+// level fields of the struct are checked.
+func Validate(v any) error {
+	var errs Errors
+
+	rv := reflect.Indirect(reflect.ValueOf(v))
+	if rv.Kind() == reflect.Struct {
+		for _, f := range rulesFor(rv.Type()) {
+			f.check(rv.Field(f.index), &errs)
+		}
+	}
+	if val, ok := v.(Validator); ok {
+		val.Validate(&errs)
+	}
+
+	if len(errs) > 0 {
+		return respond.Validation(errs...)
+	}
+	return nil
+}
+
+type fieldRules struct {
+	index    int
+	name     string
+	required bool
+	min, max *int64
+	oneof    []string
+}
+
+var rulesCache sync.Map // reflect.Type -> []fieldRules
+
+func rulesFor(t reflect.Type) []fieldRules {
+	if cached, ok := rulesCache.Load(t); ok {
+		return cached.([]fieldRules)
+	}
+
+	var out []fieldRules
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		tag, ok := sf.Tag.Lookup("validate")
+		if !ok {
+			continue
+		}
+		f := fieldRules{index: i, name: jsonName(sf)}
+		for _, rule := range strings.Split(tag, ",") {
+			key, arg, _ := strings.Cut(strings.TrimSpace(rule), "=")
+			switch key {
+			case "required":
+				f.required = true
+			case "min", "max":
+				n, err := strconv.ParseInt(arg, 10, 64)
+				if err != nil {
+					panic(fmt.Sprintf("request: bad %s rule on %s.%s", key, t.Name(), sf.Name))
+				}
+				if key == "min" {
+					f.min = &n
+				} else {
+					f.max = &n
+				}
+			case "oneof":
+				f.oneof = strings.Fields(arg)
+			default:
+				panic(fmt.Sprintf("request: unknown rule %q on %s.%s", key, t.Name(), sf.Name))
+			}
+		}
+		out = append(out, f)
+	}
+
+	rulesCache.Store(t, out)
+	return out
+}
+
+func jsonName(sf reflect.StructField) string {
+	name, _, _ := strings.Cut(sf.Tag.Get("json"), ",")
+	if name == "" || name == "-" {
+		return sf.Name
+	}
+	return name
+}
+
+func (f fieldRules) check(v reflect.Value, errs *Errors) {
+	empty := v.IsZero()
+	if v.Kind() == reflect.String {
+		empty = strings.TrimSpace(v.String()) == ""
+	}
+	if empty {
+		if f.required {
+			errs.Add(f.name, CodeRequired, f.name+" is required")
+		}
+		return
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		n := int64(utf8.RuneCountInString(v.String()))
+		f.checkLength(n, "characters", errs)
+		if len(f.oneof) > 0 && !slices.Contains(f.oneof, v.String()) {
+			errs.Add(f.name, CodeInvalid, f.name+" must be one of "+strings.Join(f.oneof, ", "))
+		}
+	case reflect.Slice, reflect.Map, reflect.Array:
+		f.checkLength(int64(v.Len()), "items", errs)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		f.checkRange(v.Int(), errs)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		f.checkRange(int64(v.Uint()), errs)
+	}
+}
+
+func (f fieldRules) checkLength(n int64, unit string, errs *Errors) {
+	switch {
+	case f.min != nil && n < *f.min:
+		errs.Add(f.name, CodeTooShort, fmt.Sprintf("%s must have at least %d %s", f.name, *f.min, unit))
+	case f.max != nil && n > *f.max:
+		errs.Add(f.name, CodeTooLong, fmt.Sprintf("%s must have at most %d %s", f.name, *f.max, unit))
+	}
+}
+
+func (f fieldRules) checkRange(n int64, errs *Errors) {
+	if (f.min != nil && n < *f.min) || (f.max != nil && n > *f.max) {
+		switch {
+		case f.min != nil && f.max != nil:
+			errs.Add(f.name, CodeOutOfRange, fmt.Sprintf("%s must be between %d and %d", f.name, *f.min, *f.max))
+		case f.min != nil:
+			errs.Add(f.name, CodeOutOfRange, fmt.Sprintf("%s must be at least %d", f.name, *f.min))
+		default:
+			errs.Add(f.name, CodeOutOfRange, fmt.Sprintf("%s must be at most %d", f.name, *f.max))
+		}
+	}
+}
@@ -0,0 +1,167 @@
+// Package respond writes API responses: JSON bodies and the error envelope
+//
+//	{"code": "book_not_in_library", "message": "...", "details": ..., "requestId": "..."}
+//
+// Codes are stable and meant for clients to switch on; messages are for
+// people and may change. Errors that are not an *Error are logged with the
+// request id and reach the client only as internal_error.
+package respond
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Codes shared across handlers. Domain codes (email_taken, ...) are declared
+// where they are produced.
+const (
+	CodeBadJSON          = "bad_json"
+	CodeValidation       = "validation_failed"
+	CodeUnauthorized     = "unauthorized"
+	CodeForbidden        = "forbidden"
+	CodeNotFound         = "not_found"
+	CodeMethodNotAllowed = "method_not_allowed"
+	CodeRateLimited      = "rate_limited"
+	CodeInternal         = "internal_error"
+)
+
+type Error struct {
+	Status    int    `json:"-"`
+	Code      string `json:"code"`
+	Message   string `json:"message"`
+	Details   any    `json:"details,omitempty"`
+	RequestID string `json:"requestId,omitempty"`
+
+	// RetryAfter, when set, is sent as the Retry-After header.
+	RetryAfter time.Duration `json:"-"`
+	// Err is the underlying cause. It is logged, never sent.
+	Err error `json:"-"`
+}
+
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return e.Code + ": " + e.Err.Error()
+	}
+	return e.Code + ": " + e.Message
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+func NewError(status int, code, message string) *Error {
+	return &Error{Status: status, Code: code, Message: message}
+}
+
+// WithDetails returns a copy of e carrying details.
+func (e *Error) WithDetails(details any) *Error {
+	c := *e
+	c.Details = details
+	return &c
+}
+
+func BadRequest(code, message string) *Error {
+	return NewError(http.StatusBadRequest, code, message)
+}
+
+func Forbidden(code, message string) *Error {
+	return NewError(http.StatusForbidden, code, message)
+}
+
+func NotFound(code, message string) *Error {
+	return NewError(http.StatusNotFound, code, message)
+}
+
+func Conflict(code, message string) *Error {
+	return NewError(http.StatusConflict, code, message)
+}
+
+func TooManyRequests(code, message string, retryAfter time.Duration) *Error {
+	e := NewError(http.StatusTooManyRequests, code, message)
+	e.RetryAfter = retryAfter
+	return e
+}
+
+func Unauthorized() *Error {
+	return NewError(http.StatusUnauthorized, CodeUnauthorized, "authentication required")
+}
+
+func BadJSON() *Error {
+	return BadRequest(CodeBadJSON, "request body is not valid JSON")
+}
+
+// Internal masks err behind a generic 500; err is only logged.
+func Internal(err error) *Error {
+	e := NewError(http.StatusInternalServerError, CodeInternal, "internal server error")
+	e.Err = err
+	return e
+}
+
+// FieldError is one invalid input field. Field is the JSON name, or a path
+// such as "bookIds[2]".
+type FieldError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// Validation reports every invalid field of a request at once.
+func Validation(fields ...FieldError) *Error {
+	return BadRequest(CodeValidation, "some fields are invalid").WithDetails(map[string]any{"fields": fields})
+}
+
+// Field is a shorthand for a single invalid field.
+func Field(field, code, message string) *Error {
+	return Validation(FieldError{Field: field, Code: code, Message: message})
+}
+
+// JSON writes v with status.
+func JSON(w http.ResponseWriter, status int, v any) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		log.Printf("RESPOND encode error: %v", err)
+		status = http.StatusInternalServerError
+		b = []byte(`{"code":"` + CodeInternal + `","message":"internal server error"}`)
+	}
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	_, _ = w.Write(b)
+}
+
+// WriteError writes err as the error envelope. Anything but an *Error is
+// treated as Internal(err).
+func WriteError(w http.ResponseWriter, r *http.Request, err error) {
+	var e *Error
+	if !errors.As(err, &e) {
+		e = Internal(err)
+	}
+
+	out := *e
+	out.RequestID = RequestID(r.Context())
+	if out.Status >= 500 {
+		log.Printf("ERROR request=%s %s %s: %v", out.RequestID, r.Method, r.URL.Path, &out)
+	}
+	if out.RetryAfter > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(out.RetryAfter.Seconds()))))
+	}
+	JSON(w, out.Status, out)
+}
+
+type requestIDKey struct{}
+
+// WithRequestID returns ctx carrying the request id that error responses
+// and logs refer to.
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
@@ -0,0 +1,120 @@
+// Package router registers method+pattern routes on the standard library
+// ServeMux. The mux resolves path wildcards and answers 405 with an Allow
+// header when a path exists but the method does not; Router adds path
+// prefixes and per-group middleware on top.
+package router
+
+import (
+	"bookpulse/internal/respond"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Middleware wraps a handler, e.g. middleware.RequireRole or
+// middleware.RateLimit.
+type Middleware func(http.Handler) http.Handler
+
+type Router struct {
+	mux    *http.ServeMux
+	prefix string
+	mw     []Middleware
+}
+
+func New() *Router {
+	return &Router{mux: http.NewServeMux()}
+}
+
+// Group returns a router that registers on the same mux under prefix, with
+// mw applied after any middleware of rt.
+func (rt *Router) Group(prefix string, mw ...Middleware) *Router {
+	all := make([]Middleware, 0, len(rt.mw)+len(mw))
+	all = append(all, rt.mw...)
+	all = append(all, mw...)
+	return &Router{
+		mux:    rt.mux,
+		prefix: rt.prefix + strings.TrimRight(prefix, "/"),
+		mw:     all,
+	}
+}
+
+// Handle registers h for method and pattern, relative to the group prefix.
+// Patterns use ServeMux syntax: "/books/{googleId}", "/files/{path...}".
+// GET routes also answer HEAD.
+func (rt *Router) Handle(method, pattern string, h http.Handler) {
+	for i := len(rt.mw) - 1; i >= 0; i-- {
+		h = rt.mw[i](h)
+	}
+	rt.mux.Handle(method+" "+rt.prefix+pattern, h)
+}
+
+func (rt *Router) Get(pattern string, h http.HandlerFunc) {
+	rt.Handle(http.MethodGet, pattern, h)
+}
+
+func (rt *Router) Post(pattern string, h http.HandlerFunc) {
+	rt.Handle(http.MethodPost, pattern, h)
+}
+
+func (rt *Router) Patch(pattern string, h http.HandlerFunc) {
+	rt.Handle(http.MethodPatch, pattern, h)
+}
+
+func (rt *Router) Delete(pattern string, h http.HandlerFunc) {
+	rt.Handle(http.MethodDelete, pattern, h)
+}
+
+// ServeHTTP dispatches to the matching route. Requests no route matches get
+// the respond error envelope instead of the mux's plain-text 404 and 405.
+func (rt *Router) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if h, pattern := rt.mux.Handler(r); pattern == "" {
+		// let the mux decide between 404 and 405 and build the Allow header
+		probe := &statusProbe{header: http.Header{}}
+		h.ServeHTTP(probe, r)
+		if allow := probe.header.Get("Allow"); allow != "" {
+			w.Header().Set("Allow", allow)
+		}
+		switch probe.status {
+		case http.StatusMethodNotAllowed:
+			respond.WriteError(w, r, respond.NewError(probe.status, respond.CodeMethodNotAllowed, r.Method+" is not supported here"))
+			return
+		case http.StatusNotFound:
+			respond.WriteError(w, r, respond.NotFound(respond.CodeNotFound, "no such endpoint"))
+			return
+		}
+	}
+	rt.mux.ServeHTTP(w, r)
+}
+
+// statusProbe records what the mux's internal handlers would answer.
+type statusProbe struct {
+	header http.Header
+	status int
+}
+
+func (p *statusProbe) Header() http.Header { return p.header }
+
+func (p *statusProbe) Write(b []byte) (int, error) { return len(b), nil }
+
+func (p *statusProbe) WriteHeader(status int) { p.status = status }
+
+var ErrBadParam = errors.New("bad path parameter")
+
+// IntParam returns the {name} wildcard of the matched route as a positive int.
+func IntParam(r *http.Request, name string) (int, error) {
+	n, err := strconv.Atoi(r.PathValue(name))
+	if err != nil || n <= 0 {
+		return 0, ErrBadParam
+	}
+	return n, nil
+}
+
+// Int64Param is IntParam for 64-bit ids.
+func Int64Param(r *http.Request, name string) (int64, error) {
+	n, err := strconv.ParseInt(r.PathValue(name), 10, 64)
+	if err != nil || n <= 0 {
+		return 0, ErrBadParam
+	}
+	return n, nil
+}
@@ -0,0 +1,77 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+)
+
+func TestAccessTokenScopes(t *testing.T) {
+	s, st := newTestService(t)
+	ctx := context.Background()
+	u := newTestUser(t, st, "ann@example.com")
+	client := ClientInfo{IP: "127.0.0.1"}
+
+	for _, scopes := range [][]string{nil, {}, {"admin"}, {ScopeLibraryRead, "library:*"}} {
+		if _, err := s.CreateAccessToken(ctx, u.ID, "script", scopes, 0, client); !errors.Is(err, ErrInvalidScopes) {
+			t.Errorf("CreateAccessToken with scopes %q = %v; want ErrInvalidScopes", scopes, err)
+		}
+	}
+
+	created, err := s.CreateAccessToken(ctx, u.ID, "script", []string{ScopeLibraryRead, ScopeLibraryRead}, 0, client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(created.Scopes, []string{ScopeLibraryRead}) {
+		t.Errorf("scopes = %q; want duplicates dropped", created.Scopes)
+	}
+
+	p, ok := authenticate(s, created.Token)
+	if !ok {
+		t.Fatal("access token rejected")
+	}
+	if p.UserID != u.ID || !p.IsAccessToken() {
+		t.Errorf("principal = %+v; want an access token of user %d", p, u.ID)
+	}
+	if !p.Allows(ScopeLibraryRead) {
+		t.Error("token does not allow its own scope")
+	}
+	for _, sc := range []string{ScopeLibraryWrite, ScopeReviewsWrite} {
+		if p.Allows(sc) {
+			t.Errorf("token allows %s it was not granted", sc)
+		}
+	}
+	if p.Role != RoleUser {
+		t.Errorf("token role = %q; want %q", p.Role, RoleUser)
+	}
+
+	if err := s.RevokeAccessToken(ctx, u.ID, created.ID, client); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := authenticate(s, created.Token); ok {
+		t.Error("revoked access token accepted")
+	}
+	if _, ok := authenticate(s, AccessTokenPrefix+"unknown"); ok {
+		t.Error("unknown access token accepted")
+	}
+}
+
+func TestSessionAllowsEveryScope(t *testing.T) {
+	s, st := newTestService(t)
+	newTestUser(t, st, "ann@example.com")
+
+	login, err := s.Login(context.Background(), "ann@example.com", testPassword, ClientInfo{IP: "127.0.0.1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, ok := authenticate(s, login.Token)
+	if !ok {
+		t.Fatal("session token rejected")
+	}
+	for _, sc := range Scopes {
+		if !p.Allows(sc) {
+			t.Errorf("session does not allow %s", sc)
+		}
+	}
+}
@@ -0,0 +1,154 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"log"
+	"strings"
+	"time"
+
+	"bookpulse/internal/repo"
+)
+
+const (
+	EventAccountDeletionRequested = "account.deletion_requested"
+	EventAccountRestored          = "account.restored"
+	EventAccountPurged            = "account.purged"
+	EventAccountExported          = "account.exported"
+
+	purgeBatchSize = 100
+)
+
+var (
+	ErrDeletionNotPending   = errors.New("account is not scheduled for deletion")
+	ErrConfirmationMismatch = errors.New("type your email to confirm")
+)
+
+type DeletionScheduled struct {
+	RequestedAt time.Time `json:"requestedAt"`
+	PurgeAfter  time.Time `json:"purgeAfter"`
+}
+
+// RequestDeletion schedules the account for purging after the grace period
+// and signs it out everywhere. Accounts with a password must confirm with
+// it, and wrong guesses count towards the login lockout; accounts that only
+// sign in through a provider confirm with their email.
+func (s *ServicePGX) RequestDeletion(ctx context.Context, userID int, password, confirmEmail string, client ClientInfo) (*DeletionScheduled, error) {
+	u, err := s.users.FindByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if u == nil {
+		return nil, ErrUserNotFound
+	}
+
+	if u.PasswordHash != "" {
+		if err := s.checkPassword(ctx, u, password, client); err != nil {
+			return nil, err
+		}
+	} else if !strings.EqualFold(strings.TrimSpace(confirmEmail), u.Email) {
+		return nil, ErrConfirmationMismatch
+	}
+
+	at, err := s.users.RequestDeletion(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.sessions.RevokeAllForUser(ctx, userID, "account_deleted"); err != nil {
+		return nil, err
+	}
+	if err := s.pats.RevokeAllForUser(ctx, userID); err != nil {
+		return nil, err
+	}
+
+	s.recordAudit(ctx, repo.AuditEvent{
+		Type: EventAccountDeletionRequested, ActorID: &userID, UserID: &userID,
+		IP: client.IP, UserAgent: client.UserAgent,
+	})
+	log.Printf("ACCOUNT user=%d scheduled for deletion", userID)
+	return &DeletionScheduled{RequestedAt: at, PurgeAfter: at.Add(s.opts.DeletionGracePeriod)}, nil
+}
+
+// RestoreAccount cancels a pending deletion. Signing in again during the
+// grace period is what lets the user get here.
+func (s *ServicePGX) RestoreAccount(ctx context.Context, userID int, client ClientInfo) (*UserDTO, error) {
+	ok, err := s.users.CancelDeletion(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrDeletionNotPending
+	}
+
+	s.recordAudit(ctx, repo.AuditEvent{
+		Type: EventAccountRestored, ActorID: &userID, UserID: &userID,
+		IP: client.IP, UserAgent: client.UserAgent,
+	})
+	log.Printf("ACCOUNT user=%d restored", userID)
+	return s.Me(ctx, userID)
+}
+
+// PurgeDeletedAccounts deletes every account whose grace period is over and
+// returns how many were removed.
+func (s *ServicePGX) PurgeDeletedAccounts(ctx context.Context) (int, error) {
+	cutoff := time.Now().Add(-s.opts.DeletionGracePeriod)
+	purged := 0
+	for {
+		ids, err := s.users.DueForPurge(ctx, cutoff, purgeBatchSize)
+		if err != nil {
+			return purged, err
+		}
+		for _, id := range ids {
+			ok, err := s.users.Purge(ctx, id, cutoff, s.opts.AnonymizeReviews)
+			if err != nil {
+				return purged, err
+			}
+			if !ok {
+				continue // restored since DueForPurge listed it
+			}
+			s.recordAudit(ctx, repo.AuditEvent{Type: EventAccountPurged, UserID: &id})
+			purged++
+		}
+		if len(ids) < purgeBatchSize {
+			return purged, nil
+		}
+	}
+}
+
+// RunPurger calls PurgeDeletedAccounts every interval until ctx is done. A
+// purge cut short by shutdown is not logged as an error.
+func (s *ServicePGX) RunPurger(ctx context.Context, interval time.Duration) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		n, err := s.PurgeDeletedAccounts(ctx)
+		if err != nil && ctx.Err() == nil {
+			log.Printf("PURGE error: %v", err)
+		} else if n > 0 {
+			log.Printf("PURGE removed %d account(s)", n)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+	}
+}
+
+// ExportData collects everything stored about the user.
+func (s *ServicePGX) ExportData(ctx context.Context, userID int, client ClientInfo) (*repo.UserExport, error) {
+	data, err := s.export.Collect(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if data == nil {
+		return nil, ErrUserNotFound
+	}
+
+	s.recordAudit(ctx, repo.AuditEvent{
+		Type: EventAccountExported, ActorID: &userID, UserID: &userID,
+		IP: client.IP, UserAgent: client.UserAgent,
+	})
+	return data, nil
+}
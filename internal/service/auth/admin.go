@@ -0,0 +1,180 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"log"
+
+	"bookpulse/internal/repo"
+)
+
+// Audit event types for admin actions.
+const (
+	EventAdminRoleChanged = "admin.role_changed"
+	EventAdminSuspended   = "admin.user_suspended"
+	EventAdminUnsuspended = "admin.user_unsuspended"
+	EventAdminForcedReset = "admin.password_reset_forced"
+	EventAdminDeleted     = "admin.user_deleted"
+	EventAdminBootstrap   = "admin.bootstrap"
+)
+
+const (
+	defaultAdminUsersPage  = 20
+	maxAdminUsersPageSize  = 100
+	maxSuspendReasonLength = 500
+)
+
+var (
+	ErrSelfAction    = errors.New("admins cannot do this to their own account")
+	ErrTargetIsAdmin = errors.New("demote the admin first")
+)
+
+// Actor is the admin performing an action, for the audit trail.
+type Actor struct {
+	UserID int
+	Client ClientInfo
+}
+
+type UserPage struct {
+	Users []repo.UserSummary `json:"users"`
+	Total int                `json:"total"`
+	Page  int                `json:"page"`
+	Limit int                `json:"limit"`
+}
+
+func (s *ServicePGX) recordAdminAction(ctx context.Context, actor Actor, eventType string, userID int, details map[string]any) {
+	s.recordAudit(ctx, repo.AuditEvent{
+		Type:      eventType,
+		ActorID:   &actor.UserID,
+		UserID:    &userID,
+		IP:        actor.Client.IP,
+		UserAgent: actor.Client.UserAgent,
+		Details:   details,
+	})
+}
+
+// SearchUsers pages through accounts matching q by email or name. page is
+// 1-based.
+func (s *ServicePGX) SearchUsers(ctx context.Context, q string, page, limit int) (*UserPage, error) {
+	if limit <= 0 {
+		limit = defaultAdminUsersPage
+	}
+	limit = min(limit, maxAdminUsersPageSize)
+	page = max(page, 1)
+
+	list, total, err := s.users.SearchUsers(ctx, q, limit, (page-1)*limit)
+	if err != nil {
+		return nil, err
+	}
+	return &UserPage{Users: list, Total: total, Page: page, Limit: limit}, nil
+}
+
+func (s *ServicePGX) UserSummary(ctx context.Context, userID int) (*repo.UserSummary, error) {
+	u, err := s.users.Summary(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if u == nil {
+		return nil, ErrUserNotFound
+	}
+	return u, nil
+}
+
+// adminTarget loads the user an admin acts on and refuses self-actions and,
+// unless allowAdmin, actions against other admins.
+func (s *ServicePGX) adminTarget(ctx context.Context, actor Actor, userID int, allowAdmin bool) (*repo.User, error) {
+	if actor.UserID == userID {
+		return nil, ErrSelfAction
+	}
+	u, err := s.users.FindByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if u == nil {
+		return nil, ErrUserNotFound
+	}
+	if !allowAdmin && u.Role == RoleAdmin {
+		return nil, ErrTargetIsAdmin
+	}
+	return u, nil
+}
+
+// SuspendUser blocks the account from signing in and from using any token
+// it already holds, until UnsuspendUser.
+func (s *ServicePGX) SuspendUser(ctx context.Context, actor Actor, userID int, reason string) (*repo.UserSummary, error) {
+	if _, err := s.adminTarget(ctx, actor, userID, false); err != nil {
+		return nil, err
+	}
+	if len(reason) > maxSuspendReasonLength {
+		reason = reason[:maxSuspendReasonLength]
+	}
+
+	if _, err := s.users.SetSuspended(ctx, userID, true, reason); err != nil {
+		return nil, err
+	}
+	if err := s.sessions.RevokeAllForUser(ctx, userID, "suspended"); err != nil {
+		return nil, err
+	}
+
+	s.recordAdminAction(ctx, actor, EventAdminSuspended, userID, map[string]any{"reason": reason})
+	log.Printf("ADMIN user=%d suspended by user=%d", userID, actor.UserID)
+	return s.UserSummary(ctx, userID)
+}
+
+func (s *ServicePGX) UnsuspendUser(ctx context.Context, actor Actor, userID int) (*repo.UserSummary, error) {
+	if _, err := s.adminTarget(ctx, actor, userID, false); err != nil {
+		return nil, err
+	}
+	if _, err := s.users.SetSuspended(ctx, userID, false, ""); err != nil {
+		return nil, err
+	}
+
+	s.recordAdminAction(ctx, actor, EventAdminUnsuspended, userID, nil)
+	log.Printf("ADMIN user=%d unsuspended by user=%d", userID, actor.UserID)
+	return s.UserSummary(ctx, userID)
+}
+
+// ForcePasswordReset invalidates the current password, signs the user out
+// everywhere, revokes their access tokens and emails a reset link.
+func (s *ServicePGX) ForcePasswordReset(ctx context.Context, actor Actor, userID int) error {
+	u, err := s.adminTarget(ctx, actor, userID, true)
+	if err != nil {
+		return err
+	}
+
+	if err := s.users.ClearPassword(ctx, userID); err != nil {
+		return err
+	}
+	if err := s.sessions.RevokeAllForUser(ctx, userID, "password_reset_forced"); err != nil {
+		return err
+	}
+	if err := s.pats.RevokeAllForUser(ctx, userID); err != nil {
+		return err
+	}
+
+	s.recordAdminAction(ctx, actor, EventAdminForcedReset, userID, nil)
+	log.Printf("ADMIN user=%d password reset forced by user=%d", userID, actor.UserID)
+
+	return s.sendResetLink(ctx, u)
+}
+
+// DeleteUser removes the account and everything it owns. Admins must be
+// demoted first so the last admin cannot be deleted by accident.
+func (s *ServicePGX) DeleteUser(ctx context.Context, actor Actor, userID int) error {
+	u, err := s.adminTarget(ctx, actor, userID, false)
+	if err != nil {
+		return err
+	}
+
+	ok, err := s.users.Delete(ctx, userID, s.opts.AnonymizeReviews)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrUserNotFound
+	}
+
+	s.recordAdminAction(ctx, actor, EventAdminDeleted, userID, map[string]any{"email": u.Email})
+	log.Printf("ADMIN user=%d deleted by user=%d", userID, actor.UserID)
+	return nil
+}
@@ -0,0 +1,114 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"bookpulse/internal/repo"
+)
+
+// newTestAdmin creates an admin and returns them as the acting admin.
+func newTestAdmin(t *testing.T, st Stores) Actor {
+	t.Helper()
+	u := newTestUser(t, st, "admin@example.com")
+	if _, err := st.Users.SetRole(context.Background(), u.ID, RoleAdmin); err != nil {
+		t.Fatal(err)
+	}
+	return Actor{UserID: u.ID, Client: ClientInfo{IP: "127.0.0.1"}}
+}
+
+func TestSuspendedUserIsLockedOut(t *testing.T) {
+	s, st := newTestService(t)
+	ctx := context.Background()
+	client := ClientInfo{IP: "127.0.0.1"}
+	admin := newTestAdmin(t, st)
+	ann := newTestUser(t, st, "ann@example.com")
+
+	login, err := s.Login(ctx, "ann@example.com", testPassword, client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pat, err := s.CreateAccessToken(ctx, ann.ID, "script", []string{ScopeLibraryRead}, 0, client)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := s.SuspendUser(ctx, admin, ann.ID, "spam"); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := authenticate(s, login.Token); ok {
+		t.Error("access token issued before the suspension accepted")
+	}
+	if _, ok := authenticate(s, pat.Token); ok {
+		t.Error("personal access token of a suspended user accepted")
+	}
+	if _, err := s.Refresh(ctx, login.RefreshToken); err == nil {
+		t.Error("Refresh of a suspended user succeeded")
+	}
+	if _, err := s.Login(ctx, "ann@example.com", testPassword, client); !errors.Is(err, ErrAccountSuspended) {
+		t.Errorf("Login = %v; want ErrAccountSuspended", err)
+	}
+
+	if _, err := s.UnsuspendUser(ctx, admin, ann.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Login(ctx, "ann@example.com", testPassword, client); err != nil {
+		t.Errorf("Login after UnsuspendUser = %v", err)
+	}
+	if _, ok := authenticate(s, pat.Token); !ok {
+		t.Error("personal access token rejected after UnsuspendUser")
+	}
+}
+
+func TestAdminActionsAreAudited(t *testing.T) {
+	s, st := newTestService(t)
+	ctx := context.Background()
+	admin := newTestAdmin(t, st)
+	ann := newTestUser(t, st, "ann@example.com")
+
+	if _, err := s.SuspendUser(ctx, admin, admin.UserID, "oops"); !errors.Is(err, ErrSelfAction) {
+		t.Errorf("suspending oneself = %v; want ErrSelfAction", err)
+	}
+
+	tests := []struct {
+		event string
+		act   func() error
+	}{
+		{EventAdminSuspended, func() error {
+			_, err := s.SuspendUser(ctx, admin, ann.ID, "spam")
+			return err
+		}},
+		{EventAdminUnsuspended, func() error {
+			_, err := s.UnsuspendUser(ctx, admin, ann.ID)
+			return err
+		}},
+		{EventAdminForcedReset, func() error { return s.ForcePasswordReset(ctx, admin, ann.ID) }},
+		{EventAdminDeleted, func() error { return s.DeleteUser(ctx, admin, ann.ID) }},
+	}
+	for _, tt := range tests {
+		if err := tt.act(); err != nil {
+			t.Fatalf("%s: %v", tt.event, err)
+		}
+		events, err := st.Audit.List(ctx, repo.AuditFilter{UserID: ann.ID, Types: []string{tt.event}, Limit: 10})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(events) != 1 || events[0].ActorID == nil || *events[0].ActorID != admin.UserID || events[0].IP != admin.Client.IP {
+			t.Errorf("%s: events = %+v; want one by user %d", tt.event, events, admin.UserID)
+		}
+	}
+
+	if u, err := st.Users.FindByID(ctx, ann.ID); err != nil || u != nil {
+		t.Errorf("FindByID after DeleteUser = %+v, %v; want the user gone", u, err)
+	}
+	self, err := st.Audit.List(ctx, repo.AuditFilter{UserID: admin.UserID, Limit: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range self {
+		if e.Type == EventAdminSuspended {
+			t.Errorf("refused self-suspension audited: %+v", e)
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package auth
+
+import (
+	"context"
+	"log"
+
+	"bookpulse/internal/repo"
+)
+
+// Audit event types for account activity. Admin and account deletion
+// events are declared next to the code that records them.
+const (
+	EventRegistered         = "user.registered"
+	EventLoginSucceeded     = "login.succeeded"
+	EventLoginFailed        = "login.failed"
+	EventPasswordChanged    = "password.changed"
+	EventPasswordReset      = "password.reset"
+	EventNameChanged        = "profile.name_changed"
+	EventEmailVerified      = "email.verified"
+	EventTwoFactorEnabled   = "2fa.enabled"
+	EventTwoFactorDisabled  = "2fa.disabled"
+	EventSessionRevoked     = "session.revoked"
+	EventAccessTokenCreated = "token.created"
+	EventAccessTokenRevoked = "token.revoked"
+	EventIdentityLinked     = "identity.linked"
+	EventIdentityUnlinked   = "identity.unlinked"
+)
+
+const (
+	defaultSecurityEventPage = 50
+	maxSecurityEventPage     = 200
+)
+
+// recordAudit writes an audit entry. A failed write is logged rather than
+// failing an action that has already happened.
+func (s *ServicePGX) recordAudit(ctx context.Context, e repo.AuditEvent) {
+	if s.audit == nil {
+		return
+	}
+	if err := s.audit.Record(ctx, e); err != nil {
+		log.Printf("AUDIT write error type=%s: %v", e.Type, err)
+	}
+}
+
+// recordUserEvent records something the user did to their own account.
+func (s *ServicePGX) recordUserEvent(ctx context.Context, eventType string, userID int, client ClientInfo, details map[string]any) {
+	s.recordAudit(ctx, repo.AuditEvent{
+		Type:      eventType,
+		ActorID:   &userID,
+		UserID:    &userID,
+		IP:        client.IP,
+		UserAgent: client.UserAgent,
+		Details:   details,
+	})
+}
+
+func clampPage(limit int) int {
+	if limit <= 0 {
+		return defaultSecurityEventPage
+	}
+	return min(limit, maxSecurityEventPage)
+}
+
+// SecurityEvents lists the user's own recent events, newest first.
+func (s *ServicePGX) SecurityEvents(ctx context.Context, userID int, beforeID int64, limit int) ([]repo.AuditEvent, error) {
+	return s.audit.List(ctx, repo.AuditFilter{UserID: userID, BeforeID: beforeID, Limit: clampPage(limit)})
+}
+
+// AuditEvents is the admin query over the whole log.
+func (s *ServicePGX) AuditEvents(ctx context.Context, f repo.AuditFilter) ([]repo.AuditEvent, error) {
+	f.Limit = clampPage(f.Limit)
+	return s.audit.List(ctx, f)
+}
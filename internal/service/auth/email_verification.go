@@ -0,0 +1,129 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/mail"
+	"net/url"
+	"strings"
+	"time"
+
+	bpmail "bookpulse/internal/mail"
+	"bookpulse/internal/repo"
+)
+
+var (
+	ErrInvalidVerificationToken = errors.New("invalid or expired verification link")
+	ErrAlreadyVerified          = errors.New("email already verified")
+)
+
+// ResendThrottledError is returned when a verification email was sent too
+// recently.
+type ResendThrottledError struct {
+	RetryAfter time.Duration
+}
+
+func (e *ResendThrottledError) Error() string {
+	return fmt.Sprintf("verification email already sent, retry in %s", e.RetryAfter.Round(time.Second))
+}
+
+// normalizeEmail trims and lowercases the address and checks it is a bare
+// RFC 5322 address. Accounts are stored and looked up by the result, so
+// addresses that differ by case only belong to one account.
+func normalizeEmail(email string) (string, bool) {
+	email = strings.TrimSpace(email)
+	if email == "" || len(email) > 254 {
+		return "", false
+	}
+	addr, err := mail.ParseAddress(email)
+	if err != nil || addr.Address != email || addr.Name != "" {
+		return "", false
+	}
+	return strings.ToLower(email), true
+}
+
+// VerifyEmail redeems a verification link token.
+func (s *ServicePGX) VerifyEmail(ctx context.Context, token string, client ClientInfo) (*UserDTO, error) {
+	claims, err := s.jwt.ParsePurposeToken(token, PurposeVerifyEmail)
+	if err != nil {
+		return nil, ErrInvalidVerificationToken
+	}
+
+	ok, err := s.users.MarkEmailVerified(ctx, int(claims.UserID), claims.Email)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrInvalidVerificationToken
+	}
+	log.Printf("VERIFY EMAIL user=%d", claims.UserID)
+	s.recordUserEvent(ctx, EventEmailVerified, int(claims.UserID), client, map[string]any{"email": claims.Email})
+
+	return s.Me(ctx, int(claims.UserID))
+}
+
+// ResendVerification sends a new verification email, at most once per
+// VerificationResendInterval.
+func (s *ServicePGX) ResendVerification(ctx context.Context, userID int) error {
+	u, err := s.users.FindByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if u == nil {
+		return ErrUserNotFound
+	}
+	if u.EmailVerified() {
+		return ErrAlreadyVerified
+	}
+
+	ok, sentAt, err := s.users.ClaimVerificationSend(ctx, userID, time.Now().Add(-s.opts.VerificationResendInterval))
+	if err != nil {
+		return err
+	}
+	if !ok {
+		retry := s.opts.VerificationResendInterval
+		if sentAt != nil {
+			retry = time.Until(sentAt.Add(s.opts.VerificationResendInterval))
+		}
+		if retry < time.Second {
+			retry = time.Second
+		}
+		return &ResendThrottledError{RetryAfter: retry}
+	}
+
+	return s.sendVerification(ctx, u)
+}
+
+func (s *ServicePGX) sendVerificationAsync(u *repo.User) {
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+
+		ok, _, err := s.users.ClaimVerificationSend(ctx, u.ID, time.Now().Add(-s.opts.VerificationResendInterval))
+		if err == nil && ok {
+			err = s.sendVerification(ctx, u)
+		}
+		if err != nil {
+			log.Printf("VERIFY EMAIL user=%d send error: %v", u.ID, err)
+		}
+	}()
+}
+
+func (s *ServicePGX) sendVerification(ctx context.Context, u *repo.User) error {
+	token, err := s.jwt.GeneratePurposeToken(uint(u.ID), PurposeVerifyEmail, u.Email, s.opts.VerificationTTL)
+	if err != nil {
+		return err
+	}
+
+	link := strings.TrimRight(s.opts.AppBaseURL, "/") + "/verify-email?token=" + url.QueryEscape(token)
+	return s.mailer.Send(ctx, bpmail.Message{
+		To:      u.Email,
+		Subject: "BookPulse: confirm your email",
+		Text: fmt.Sprintf(
+			"Hi %s,\n\nplease confirm the email address of your BookPulse account:\n\n%s\n\n"+
+				"The link is valid for %s.\n",
+			u.Name, link, s.opts.VerificationTTL),
+	})
+}
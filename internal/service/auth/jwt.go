@@ -1,42 +1,186 @@
 package auth
 
 import (
+	"context"
 	"errors"
+	"log"
 	"net/http"
+	"strings"
 	"time"
 
+	"bookpulse/internal/repo"
+
 	"github.com/golang-jwt/jwt/v5"
 )
 
+// SessionChecker reports whether the session an access token was issued for
+// is still open.
+type SessionChecker interface {
+	IsActive(ctx context.Context, sessionID int64) (bool, error)
+}
+
+// UserFinder loads the account behind a token.
+type UserFinder interface {
+	FindByID(ctx context.Context, id int) (*repo.User, error)
+}
+
 type JWT struct {
-	Secret []byte
+	// Secret signs HS256 tokens when Keys is nil. With Keys set it is only
+	// used to verify HS256 tokens issued before the switch, and may be empty.
+	Secret    []byte
+	Keys      *KeySet
+	AccessTTL time.Duration
+	// Issuer is the iss claim of every token; tokens from another issuer
+	// are rejected.
+	Issuer string
+
+	// Sessions, when set, makes Authenticate reject tokens of revoked
+	// sessions.
+	Sessions SessionChecker
+
+	// Tokens, when set, lets Authenticate accept personal access tokens.
+	Tokens AccessTokenLookup
+
+	// Users, when set, makes Authenticate load the caller's account.
+	Users UserFinder
 }
 
-func NewJWT(secret string) *JWT {
-	return &JWT{Secret: []byte(secret)}
+func NewJWT(secret string, accessTTL time.Duration) *JWT {
+	return &JWT{Secret: []byte(secret), AccessTTL: accessTTL, Issuer: DefaultIssuer}
+}
+
+const (
+	DefaultIssuer = "bookpulse"
+
+	// AccessAudience is the aud claim of access tokens. Services that verify
+	// BookPulse tokens against the JWKS must require it, so that they never
+	// take a purpose token for a login.
+	AccessAudience = "bookpulse-api"
+
+	// typ headers: access tokens per RFC 9068, purpose tokens their own
+	accessTokenType  = "at+jwt"
+	purposeTokenType = "bookpulse-purpose+jwt"
+)
+
+// purposeAudience is the aud claim of purpose tokens, one per purpose.
+func purposeAudience(purpose string) string {
+	return "bookpulse-" + purpose
 }
 
 type Claims struct {
-	UserID uint `json:"userId"`
+	UserID    uint   `json:"userId"`
+	SessionID int64  `json:"sid,omitempty"`
+	Role      string `json:"role,omitempty"`
+	// Purpose is empty for access tokens and names the single use of
+	// special-purpose tokens such as email verification links.
+	Purpose string `json:"pur,omitempty"`
+	Email   string `json:"email,omitempty"`
 	jwt.RegisteredClaims
 }
 
-func (j *JWT) GenerateToken(userID uint) (string, error) {
+// RoleOrDefault treats tokens issued before roles existed as ordinary users.
+func (c *Claims) RoleOrDefault() string {
+	if c.Role == "" {
+		return RoleUser
+	}
+	return c.Role
+}
+
+const (
+	PurposeVerifyEmail        = "verify_email"
+	PurposeTwoFactorChallenge = "2fa_challenge"
+)
+
+func (j *JWT) GenerateToken(userID uint, sessionID int64, role string) (string, error) {
+	claims := Claims{
+		UserID:    userID,
+		SessionID: sessionID,
+		Role:      role,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    j.Issuer,
+			Audience:  jwt.ClaimStrings{AccessAudience},
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(j.AccessTTL)),
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+		},
+	}
+	return j.sign(claims, accessTokenType)
+}
+
+// GeneratePurposeToken signs a short-lived token that is only accepted by
+// ParsePurposeToken with the same purpose, never as an access token: its
+// typ header and aud claim differ from those of access tokens.
+func (j *JWT) GeneratePurposeToken(userID uint, purpose, email string, ttl time.Duration) (string, error) {
 	claims := Claims{
-		UserID: userID,
+		UserID:  userID,
+		Purpose: purpose,
+		Email:   email,
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(24 * time.Hour * 30)),
+			Issuer:    j.Issuer,
+			Audience:  jwt.ClaimStrings{purposeAudience(purpose)},
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(ttl)),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
 		},
 	}
-	t := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return t.SignedString(j.Secret)
+	return j.sign(claims, purposeTokenType)
 }
 
 func (j *JWT) ParseToken(tokenStr string) (*Claims, error) {
+	claims, err := j.parse(tokenStr, accessTokenType, AccessAudience)
+	if err != nil {
+		return nil, err
+	}
+	if claims.Purpose != "" {
+		return nil, errors.New("not an access token")
+	}
+	return claims, nil
+}
+
+func (j *JWT) ParsePurposeToken(tokenStr, purpose string) (*Claims, error) {
+	claims, err := j.parse(tokenStr, purposeTokenType, purposeAudience(purpose))
+	if err != nil {
+		return nil, err
+	}
+	if claims.Purpose != purpose {
+		return nil, errors.New("wrong token purpose")
+	}
+	return claims, nil
+}
+
+func (j *JWT) sign(claims Claims, typ string) (string, error) {
+	if j.Keys == nil {
+		t := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+		t.Header["typ"] = typ
+		return t.SignedString(j.Secret)
+	}
+	k := j.Keys.Signing()
+	t := jwt.NewWithClaims(k.Method, claims)
+	t.Header["typ"] = typ
+	t.Header["kid"] = k.ID
+	return t.SignedString(k.Private)
+}
+
+// parse verifies the signature, the typ header, iss and aud. Tokens issued
+// before iss and aud were set fail: access tokens are renewed with the
+// refresh token, and verification emails can be sent again.
+func (j *JWT) parse(tokenStr, typ, audience string) (*Claims, error) {
+	methods := []string{jwt.SigningMethodRS256.Alg(), jwt.SigningMethodEdDSA.Alg()}
+	if len(j.Secret) > 0 {
+		methods = append(methods, jwt.SigningMethodHS256.Alg())
+	}
+
 	t, err := jwt.ParseWithClaims(tokenStr, &Claims{}, func(token *jwt.Token) (any, error) {
-		return j.Secret, nil
-	})
+		if h, _ := token.Header["typ"].(string); !strings.EqualFold(h, typ) {
+			return nil, errors.New("wrong token type")
+		}
+		if token.Method.Alg() == jwt.SigningMethodHS256.Alg() {
+			return j.Secret, nil
+		}
+		if j.Keys == nil {
+			return nil, errors.New("no verification keys configured")
+		}
+		kid, _ := token.Header["kid"].(string)
+		return j.Keys.Verifier(kid, token.Method.Alg())
+	}, jwt.WithValidMethods(methods), jwt.WithIssuer(j.Issuer), jwt.WithAudience(audience))
 	if err != nil {
 		return nil, err
 	}
@@ -47,16 +191,83 @@ func (j *JWT) ParseToken(tokenStr string) (*Claims, error) {
 	return claims, nil
 }
 
-func MustAuth(r *http.Request, jwt *JWT) (int, bool) {
+// bearerClaims parses a JWT bearer token and checks that its session is
+// still open.
+func bearerClaims(r *http.Request, jwt *JWT) (*Claims, bool) {
 	h := r.Header.Get("Authorization")
 	const prefix = "Bearer "
 	if len(h) <= len(prefix) || h[:len(prefix)] != prefix {
-		return 0, false
+		return nil, false
 	}
 	token := h[len(prefix):]
 	claims, err := jwt.ParseToken(token)
 	if err != nil {
-		return 0, false
+		return nil, false
+	}
+
+	if jwt.Sessions != nil {
+		if claims.SessionID == 0 {
+			return nil, false
+		}
+		active, err := jwt.Sessions.IsActive(r.Context(), claims.SessionID)
+		if err != nil {
+			log.Printf("AUTH session check error: %v", err)
+			return nil, false
+		}
+		if !active {
+			return nil, false
+		}
+	}
+	return claims, true
+}
+
+// Authenticate resolves the bearer token, a session JWT or a personal access
+// token, to a Principal. With jwt.Users set it also loads the account, so
+// the role is the current one rather than the one the token was issued with,
+// and suspended accounts are turned away. Handlers read the result from the
+// request context (PrincipalFrom); only middleware calls this.
+func Authenticate(r *http.Request, jwt *JWT) (*Principal, bool) {
+	var p *Principal
+
+	h := r.Header.Get("Authorization")
+	const prefix = "Bearer "
+	if len(h) > len(prefix) && h[:len(prefix)] == prefix && strings.HasPrefix(h[len(prefix):], AccessTokenPrefix) {
+		if jwt.Tokens == nil {
+			return nil, false
+		}
+		t, err := jwt.Tokens.Lookup(r.Context(), hashToken(h[len(prefix):]))
+		if err != nil {
+			log.Printf("AUTH access token lookup error: %v", err)
+			return nil, false
+		}
+		if t == nil {
+			return nil, false
+		}
+		p = &Principal{UserID: t.UserID, AccessTokenID: t.ID, Scopes: t.Scopes}
+	} else {
+		claims, ok := bearerClaims(r, jwt)
+		if !ok {
+			return nil, false
+		}
+		p = &Principal{UserID: int(claims.UserID), SessionID: claims.SessionID, Role: claims.RoleOrDefault()}
+	}
+
+	if jwt.Users != nil {
+		u, err := jwt.Users.FindByID(r.Context(), p.UserID)
+		if err != nil {
+			log.Printf("AUTH user lookup error: %v", err)
+			return nil, false
+		}
+		if u == nil || u.Suspended() {
+			return nil, false
+		}
+		p.User = u
+		p.Role = u.Role
+	}
+
+	// tokens never carry a role, so they only reach user-level routes
+	if p.IsAccessToken() {
+		p.Role = RoleUser
 	}
-	return int(claims.UserID), true
+	return p, true
 }
@@ -0,0 +1,162 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	"bookpulse/internal/oidc"
+)
+
+const minRSABits = 2048
+
+// KeyFile names a PEM file holding either a private key (can sign and
+// verify) or only a public key (verifies tokens signed before a rotation).
+type KeyFile struct {
+	ID   string
+	Path string
+}
+
+// Key is one entry of a KeySet. Private is nil for verification-only keys.
+type Key struct {
+	ID      string
+	Method  jwt.SigningMethod
+	Public  crypto.PublicKey
+	Private crypto.PrivateKey
+}
+
+// KeySet holds every key tokens may be verified with and the one new tokens
+// are signed with. Rotation is: add the new key, make it the signing key,
+// and drop the old one once the tokens it signed have expired.
+type KeySet struct {
+	signing *Key
+	keys    map[string]*Key
+}
+
+// LoadKeySet reads keys from PEM files. signingID must name one of them and
+// that one must hold a private key.
+func LoadKeySet(files []KeyFile, signingID string) (*KeySet, error) {
+	ks := &KeySet{keys: make(map[string]*Key, len(files))}
+	for _, f := range files {
+		if f.ID == "" {
+			return nil, fmt.Errorf("jwt key %s: missing id", f.Path)
+		}
+		if _, dup := ks.keys[f.ID]; dup {
+			return nil, fmt.Errorf("jwt key %s: duplicate id", f.ID)
+		}
+		b, err := os.ReadFile(f.Path)
+		if err != nil {
+			return nil, fmt.Errorf("jwt key %s: %w", f.ID, err)
+		}
+		k, err := parseKeyPEM(f.ID, b)
+		if err != nil {
+			return nil, err
+		}
+		ks.keys[f.ID] = k
+	}
+
+	ks.signing = ks.keys[signingID]
+	if ks.signing == nil {
+		return nil, fmt.Errorf("jwt signing key %q is not in the keyset", signingID)
+	}
+	if ks.signing.Private == nil {
+		return nil, fmt.Errorf("jwt signing key %q has no private key", signingID)
+	}
+	return ks, nil
+}
+
+func parseKeyPEM(id string, b []byte) (*Key, error) {
+	block, _ := pem.Decode(b)
+	if block == nil {
+		return nil, fmt.Errorf("jwt key %s: no PEM block", id)
+	}
+
+	var priv, pub any
+	var err error
+	switch block.Type {
+	case "PRIVATE KEY":
+		priv, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		priv, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "PUBLIC KEY":
+		pub, err = x509.ParsePKIXPublicKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("jwt key %s: unsupported PEM type %q", id, block.Type)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("jwt key %s: %w", id, err)
+	}
+
+	k := &Key{ID: id}
+	switch p := priv.(type) {
+	case *rsa.PrivateKey:
+		k.Private, pub = p, &p.PublicKey
+	case ed25519.PrivateKey:
+		k.Private, pub = p, p.Public()
+	case nil:
+	default:
+		return nil, fmt.Errorf("jwt key %s: unsupported private key type %T", id, priv)
+	}
+
+	switch p := pub.(type) {
+	case *rsa.PublicKey:
+		if p.N.BitLen() < minRSABits {
+			return nil, fmt.Errorf("jwt key %s: RSA keys must be at least %d bits", id, minRSABits)
+		}
+		k.Method = jwt.SigningMethodRS256
+	case ed25519.PublicKey:
+		k.Method = jwt.SigningMethodEdDSA
+	default:
+		return nil, fmt.Errorf("jwt key %s: unsupported public key type %T", id, pub)
+	}
+	k.Public = pub
+	return k, nil
+}
+
+// Signing returns the key new tokens are signed with.
+func (ks *KeySet) Signing() *Key {
+	return ks.signing
+}
+
+var errUnknownKey = errors.New("unknown key id")
+
+// Verifier returns the public key for a token header's kid and checks the
+// token uses that key's algorithm.
+func (ks *KeySet) Verifier(kid, alg string) (crypto.PublicKey, error) {
+	k := ks.keys[kid]
+	if k == nil {
+		return nil, errUnknownKey
+	}
+	if k.Method.Alg() != alg {
+		return nil, fmt.Errorf("key %s is for %s, token uses %s", kid, k.Method.Alg(), alg)
+	}
+	return k.Public, nil
+}
+
+// JWKS returns the public half of every key, for /.well-known/jwks.json.
+func (ks *KeySet) JWKS() (oidc.JWKS, error) {
+	ids := make([]string, 0, len(ks.keys))
+	for id := range ks.keys {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	out := oidc.JWKS{Keys: make([]oidc.JWK, 0, len(ids))}
+	for _, id := range ids {
+		k := ks.keys[id]
+		jwk, err := oidc.NewJWK(k.ID, k.Method.Alg(), k.Public)
+		if err != nil {
+			return oidc.JWKS{}, err
+		}
+		out.Keys = append(out.Keys, jwk)
+	}
+	return out, nil
+}
@@ -0,0 +1,132 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"bookpulse/internal/repo"
+)
+
+// ThrottleOptions configure login brute-force protection. After FreeFailures
+// failed attempts a key is locked for BaseLockout, doubling with every
+// further failure up to MaxLockout.
+type ThrottleOptions struct {
+	AccountFreeFailures int
+	IPFreeFailures      int
+	BaseLockout         time.Duration
+	MaxLockout          time.Duration
+	Window              time.Duration
+}
+
+// TooManyAttemptsError is returned while an account or IP is locked out.
+type TooManyAttemptsError struct {
+	RetryAfter time.Duration
+}
+
+func (e *TooManyAttemptsError) Error() string {
+	return fmt.Sprintf("too many failed login attempts, retry in %s", e.RetryAfter.Round(time.Second))
+}
+
+type LoginThrottle struct {
+	store repo.LoginAttemptStore
+	opts  ThrottleOptions
+}
+
+func NewLoginThrottle(store repo.LoginAttemptStore, opts ThrottleOptions) *LoginThrottle {
+	return &LoginThrottle{store: store, opts: opts}
+}
+
+// accountKey keys the account by the address Login looks it up by, so the
+// lockout and the lookup agree on which spellings are the same account.
+func accountKey(email string) string {
+	if norm, ok := normalizeEmail(email); ok {
+		email = norm
+	}
+	return "acct:" + email
+}
+
+func ipKey(ip string) string {
+	return "ip:" + ip
+}
+
+// Check fails with TooManyAttemptsError if either the account or the IP is
+// currently locked.
+func (t *LoginThrottle) Check(ctx context.Context, email, ip string) error {
+	var wait time.Duration
+	for _, key := range t.keys(email, ip) {
+		until, err := t.store.LockedUntil(ctx, key)
+		if err != nil {
+			return err
+		}
+		if d := time.Until(until); d > wait {
+			wait = d
+		}
+	}
+	if wait > 0 {
+		return &TooManyAttemptsError{RetryAfter: wait}
+	}
+	return nil
+}
+
+// Failure counts a failed attempt against the account and the IP and locks
+// whichever went over its allowance.
+func (t *LoginThrottle) Failure(ctx context.Context, email, ip string) {
+	for _, key := range t.keys(email, ip) {
+		free := t.opts.AccountFreeFailures
+		if strings.HasPrefix(key, "ip:") {
+			free = t.opts.IPFreeFailures
+		}
+
+		n, err := t.store.RecordFailure(ctx, key, t.opts.Window)
+		if err != nil {
+			log.Printf("LOGIN throttle record error: %v", err)
+			continue
+		}
+		if n <= free {
+			continue
+		}
+
+		lock := t.lockout(n - free)
+		if err := t.store.Lock(ctx, key, time.Now().Add(lock)); err != nil {
+			log.Printf("LOGIN throttle lock error: %v", err)
+			continue
+		}
+		log.Printf("LOGIN throttle %s locked for %s after %d failures", key, lock, n)
+	}
+}
+
+// Success clears the account counter. The IP counter is left alone so a
+// valid login cannot be used to reset guessing from the same address.
+func (t *LoginThrottle) Success(ctx context.Context, email string) {
+	if email == "" {
+		return
+	}
+	if err := t.store.Reset(ctx, accountKey(email)); err != nil {
+		log.Printf("LOGIN throttle reset error: %v", err)
+	}
+}
+
+func (t *LoginThrottle) keys(email, ip string) []string {
+	keys := make([]string, 0, 2)
+	if email != "" {
+		keys = append(keys, accountKey(email))
+	}
+	if ip != "" {
+		keys = append(keys, ipKey(ip))
+	}
+	return keys
+}
+
+func (t *LoginThrottle) lockout(over int) time.Duration {
+	d := t.opts.BaseLockout
+	for i := 1; i < over && d < t.opts.MaxLockout; i++ {
+		d *= 2
+	}
+	if d > t.opts.MaxLockout {
+		d = t.opts.MaxLockout
+	}
+	return d
+}
@@ -0,0 +1,82 @@
+package auth
+
+import (
+	"bufio"
+	_ "embed"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// bcrypt ignores everything past 72 bytes, so longer passwords would be
+// silently truncated.
+const (
+	maxPasswordBytes         = 72
+	defaultPasswordMinLength = 8
+)
+
+//go:embed common_passwords.txt
+var commonPasswordsFile string
+
+var commonPasswords = loadCommonPasswords(commonPasswordsFile)
+
+func loadCommonPasswords(src string) map[string]struct{} {
+	out := make(map[string]struct{}, 512)
+	sc := bufio.NewScanner(strings.NewReader(src))
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		out[strings.ToLower(line)] = struct{}{}
+	}
+	return out
+}
+
+// PasswordPolicyError says why a password was rejected. The message is safe
+// to show to the user.
+type PasswordPolicyError struct {
+	Reason string
+}
+
+func (e *PasswordPolicyError) Error() string {
+	return e.Reason
+}
+
+// PasswordPolicy is the one set of password rules used for registration,
+// password change and reset.
+type PasswordPolicy struct {
+	MinLength int
+}
+
+func NewPasswordPolicy(minLength int) PasswordPolicy {
+	if minLength <= 0 {
+		minLength = defaultPasswordMinLength
+	}
+	return PasswordPolicy{MinLength: minLength}
+}
+
+// Check validates password for the account with this email. Length is
+// counted in characters, the upper bound in bytes.
+func (p PasswordPolicy) Check(password, email string) error {
+	if utf8.RuneCountInString(password) < p.MinLength {
+		return &PasswordPolicyError{Reason: fmt.Sprintf("password must be at least %d characters", p.MinLength)}
+	}
+	if len(password) > maxPasswordBytes {
+		return &PasswordPolicyError{Reason: fmt.Sprintf("password must be at most %d bytes", maxPasswordBytes)}
+	}
+
+	lower := strings.ToLower(password)
+	if _, ok := commonPasswords[lower]; ok {
+		return &PasswordPolicyError{Reason: "this password is too common, choose another one"}
+	}
+
+	email = strings.ToLower(strings.TrimSpace(email))
+	if email != "" {
+		local, _, _ := strings.Cut(email, "@")
+		if lower == email || lower == local {
+			return &PasswordPolicyError{Reason: "password must not be your email"}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,107 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+
+	"bookpulse/internal/mail"
+	"bookpulse/internal/repo"
+)
+
+// ForgotPassword emails a one-time reset link if email belongs to an account.
+// It never reports whether the account exists, and the mail is sent in the
+// background so response timing does not tell either.
+func (s *ServicePGX) ForgotPassword(ctx context.Context, email string) error {
+	email, ok := normalizeEmail(email)
+	if !ok {
+		return nil
+	}
+
+	u, err := s.users.FindByEmail(ctx, email)
+	if err != nil {
+		return err
+	}
+	if u == nil {
+		log.Printf("FORGOT PASSWORD unknown email")
+		return nil
+	}
+
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		if err := s.sendResetLink(ctx, u); err != nil {
+			log.Printf("FORGOT PASSWORD user=%d send error: %v", u.ID, err)
+		}
+	}()
+	return nil
+}
+
+func (s *ServicePGX) sendResetLink(ctx context.Context, u *repo.User) error {
+	token, err := newOpaqueToken()
+	if err != nil {
+		return err
+	}
+	if err := s.resets.Create(ctx, u.ID, hashToken(token), time.Now().Add(s.opts.ResetTTL)); err != nil {
+		return err
+	}
+
+	link := strings.TrimRight(s.opts.AppBaseURL, "/") + "/reset-password?token=" + url.QueryEscape(token)
+	return s.mailer.Send(ctx, mail.Message{
+		To:      u.Email,
+		Subject: "BookPulse: password reset",
+		Text: fmt.Sprintf(
+			"Hi %s,\n\nsomeone asked to reset the password of your BookPulse account.\n"+
+				"Open this link within %s to choose a new one:\n\n%s\n\n"+
+				"If it wasn't you, just ignore this email.\n",
+			u.Name, s.opts.ResetTTL, link),
+	})
+}
+
+// ResetPassword redeems a reset token, sets the new password and signs the
+// user out everywhere.
+func (s *ServicePGX) ResetPassword(ctx context.Context, token, password string, client ClientInfo) error {
+	if token == "" {
+		return repo.ErrResetTokenInvalid
+	}
+
+	// validate against the owner's email before the token is spent
+	ownerID, err := s.resets.Peek(ctx, hashToken(token))
+	if err != nil {
+		return err
+	}
+	u, err := s.users.FindByID(ctx, ownerID)
+	if err != nil {
+		return err
+	}
+	if u == nil {
+		return repo.ErrResetTokenInvalid
+	}
+	if err := s.policy.Check(password, u.Email); err != nil {
+		return err
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+
+	userID, err := s.resets.Consume(ctx, hashToken(token))
+	if err != nil {
+		return err
+	}
+	if err := s.users.UpdatePassword(ctx, userID, string(hash)); err != nil {
+		return err
+	}
+	if err := s.sessions.RevokeAllForUser(ctx, userID, "password_reset"); err != nil {
+		return err
+	}
+	log.Printf("RESET PASSWORD user=%d", userID)
+	s.recordUserEvent(ctx, EventPasswordReset, userID, client, nil)
+	return nil
+}
@@ -0,0 +1,36 @@
+package auth
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"bookpulse/internal/mail"
+)
+
+// mailbox collects the messages the service sends in the background.
+type mailbox chan mail.Message
+
+func (m mailbox) Send(ctx context.Context, msg mail.Message) error {
+	m <- msg
+	return nil
+}
+
+func TestForgotPasswordIgnoresEmailCase(t *testing.T) {
+	s, st := newTestService(t)
+	box := make(mailbox, 1)
+	s.mailer = box
+	newTestUser(t, st, "ann@example.com")
+
+	if err := s.ForgotPassword(context.Background(), " Ann@Example.COM "); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case msg := <-box:
+		if msg.To != "ann@example.com" {
+			t.Errorf("reset link sent to %q; want the stored address", msg.To)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no reset link sent for an address in other case")
+	}
+}
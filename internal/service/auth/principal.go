@@ -0,0 +1,46 @@
+package auth
+
+import (
+	"context"
+	"slices"
+
+	"bookpulse/internal/repo"
+)
+
+// Principal is the authenticated caller. Scopes are only set for personal
+// access tokens; a session may do everything its user may.
+type Principal struct {
+	UserID        int
+	SessionID     int64
+	AccessTokenID int64
+	Scopes        []string
+	Role          string
+	// User is the account as loaded for this request; nil when the JWT has
+	// no UserFinder.
+	User *repo.User
+}
+
+func (p *Principal) IsAccessToken() bool {
+	return p.AccessTokenID != 0
+}
+
+func (p *Principal) Allows(scope string) bool {
+	if !p.IsAccessToken() {
+		return true
+	}
+	return slices.Contains(p.Scopes, scope)
+}
+
+type principalKey struct{}
+
+// WithPrincipal returns ctx carrying p.
+func WithPrincipal(ctx context.Context, p *Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, p)
+}
+
+// PrincipalFrom returns the caller stored by the auth middleware. It reports
+// false on public routes and for anonymous callers of optional-auth routes.
+func PrincipalFrom(ctx context.Context) (*Principal, bool) {
+	p, ok := ctx.Value(principalKey{}).(*Principal)
+	return p, ok && p != nil
+}
@@ -0,0 +1,83 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"log"
+
+	"bookpulse/internal/repo"
+)
+
+const (
+	RoleUser      = "user"
+	RoleModerator = "moderator"
+	RoleAdmin     = "admin"
+)
+
+// roleRank orders roles so that a higher role passes every check a lower
+// one does.
+var roleRank = map[string]int{
+	RoleUser:      1,
+	RoleModerator: 2,
+	RoleAdmin:     3,
+}
+
+var (
+	ErrInvalidRole  = errors.New("role must be user, moderator or admin")
+	ErrUserNotFound = errors.New("user not found")
+)
+
+func ValidRole(role string) bool {
+	_, ok := roleRank[role]
+	return ok
+}
+
+// HasRole reports whether have satisfies a requirement of want.
+func HasRole(have, want string) bool {
+	return roleRank[have] >= roleRank[want] && roleRank[have] > 0
+}
+
+// SetRole changes the role of userID on behalf of an admin. The user's
+// sessions are revoked so that access tokens carrying the old role stop
+// working right away instead of at expiry.
+func (s *ServicePGX) SetRole(ctx context.Context, actor Actor, userID int, role string) (*UserDTO, error) {
+	if !ValidRole(role) {
+		return nil, ErrInvalidRole
+	}
+	u, err := s.adminTarget(ctx, actor, userID, true)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := s.users.SetRole(ctx, userID, role); err != nil {
+		return nil, err
+	}
+	if err := s.sessions.RevokeAllForUser(ctx, userID, "role_changed"); err != nil {
+		return nil, err
+	}
+
+	s.recordAdminAction(ctx, actor, EventAdminRoleChanged, userID, map[string]any{"from": u.Role, "to": role})
+	log.Printf("ROLE user=%d set to %s by user=%d", userID, role, actor.UserID)
+	return s.Me(ctx, userID)
+}
+
+// BootstrapAdmin promotes the first admin. It fails once any admin exists.
+func (s *ServicePGX) BootstrapAdmin(ctx context.Context, email string) (*UserDTO, error) {
+	email, ok := normalizeEmail(email)
+	if !ok {
+		return nil, ErrInvalidEmail
+	}
+	u, err := s.users.BootstrapAdmin(ctx, email)
+	if err != nil {
+		return nil, err
+	}
+	if u == nil {
+		return nil, ErrUserNotFound
+	}
+	if err := s.sessions.RevokeAllForUser(ctx, u.ID, "role_changed"); err != nil {
+		return nil, err
+	}
+	s.recordAudit(ctx, repo.AuditEvent{Type: EventAdminBootstrap, UserID: &u.ID})
+	dto := toUserDTO(u)
+	return &dto, nil
+}
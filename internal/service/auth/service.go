@@ -4,36 +4,146 @@ import (
 	"context"
 	"errors"
 	"log"
+	"net"
+	"net/http"
+	"time"
 
 	"golang.org/x/crypto/bcrypt"
 
+	"bookpulse/internal/mail"
 	"bookpulse/internal/repo"
 )
 
+var (
+	ErrInvalidRefreshToken = errors.New("invalid refresh token")
+	ErrInvalidEmail        = errors.New("invalid email")
+	ErrEmailTaken          = errors.New("email already exists")
+	ErrAccountSuspended    = errors.New("account suspended")
+	ErrNoPassword          = errors.New("this account has no password yet; use forgot password to set one")
+)
+
+// Stores groups the repositories the auth service persists to.
+type Stores struct {
+	Users     repo.UserRepo
+	Sessions  repo.SessionRepo
+	Resets    repo.PasswordResetRepo
+	TwoFactor repo.TwoFactorRepo
+	Tokens    repo.AccessTokenRepo
+	Audit     repo.AuditRepo
+	Export    repo.ExportRepo
+}
+
+type Options struct {
+	RefreshTTL time.Duration
+	ResetTTL   time.Duration
+
+	PasswordMinLength int
+
+	VerificationTTL            time.Duration
+	VerificationResendInterval time.Duration
+
+	// AppBaseURL is the frontend origin used to build links in emails.
+	AppBaseURL string
+
+	DeletionGracePeriod time.Duration
+	// AnonymizeReviews keeps the reviews of deleted accounts without an
+	// author instead of deleting them.
+	AnonymizeReviews bool
+}
+
 type ServicePGX struct {
-	users *repo.UserRepoPGX
-	jwt   *JWT
+	users    repo.UserRepo
+	sessions repo.SessionRepo
+	resets   repo.PasswordResetRepo
+	totp     repo.TwoFactorRepo
+	pats     repo.AccessTokenRepo
+	audit    repo.AuditRepo
+	export   repo.ExportRepo
+	mailer   mail.Mailer
+	throttle *LoginThrottle
+	jwt      *JWT
+	policy   PasswordPolicy
+	opts     Options
 }
 
-func NewServicePGX(users *repo.UserRepoPGX, jwt *JWT) *ServicePGX {
-	return &ServicePGX{users: users, jwt: jwt}
+func NewServicePGX(stores Stores, jwt *JWT, mailer mail.Mailer, throttle *LoginThrottle, opts Options) *ServicePGX {
+	return &ServicePGX{
+		users:    stores.Users,
+		sessions: stores.Sessions,
+		resets:   stores.Resets,
+		totp:     stores.TwoFactor,
+		pats:     stores.Tokens,
+		audit:    stores.Audit,
+		export:   stores.Export,
+		mailer:   mailer,
+		throttle: throttle,
+		jwt:      jwt,
+		policy:   NewPasswordPolicy(opts.PasswordMinLength),
+		opts:     opts,
+	}
 }
 
 type UserDTO struct {
-	ID    int    `json:"id"`
-	Email string `json:"email"`
-	Name  string `json:"name"`
+	ID               int    `json:"id"`
+	Email            string `json:"email"`
+	Name             string `json:"name"`
+	EmailVerified    bool   `json:"emailVerified"`
+	TwoFactorEnabled bool   `json:"twoFactorEnabled"`
+	Role             string `json:"role"`
+	// DeletionRequestedAt is set while the account is scheduled for purging.
+	DeletionRequestedAt *time.Time `json:"deletionRequestedAt,omitempty"`
+}
+
+func toUserDTO(u *repo.User) UserDTO {
+	return UserDTO{
+		ID:                  u.ID,
+		Email:               u.Email,
+		Name:                u.Name,
+		EmailVerified:       u.EmailVerified(),
+		TwoFactorEnabled:    u.TwoFactorEnabled(),
+		Role:                u.Role,
+		DeletionRequestedAt: u.DeletionRequestedAt,
+	}
 }
 
 type AuthResponse struct {
-	Token string  `json:"token"`
-	User  UserDTO `json:"user"`
+	Token        string  `json:"token"`
+	RefreshToken string  `json:"refreshToken"`
+	ExpiresIn    int     `json:"expiresIn"`
+	User         UserDTO `json:"user"`
+}
+
+// ClientInfo describes the device a session is opened from.
+type ClientInfo struct {
+	UserAgent string
+	IP        string
+}
+
+func ClientFromRequest(r *http.Request) ClientInfo {
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		ip = r.RemoteAddr
+	}
+	ua := r.UserAgent()
+	if len(ua) > 512 {
+		ua = ua[:512]
+	}
+	return ClientInfo{UserAgent: ua, IP: ip}
 }
 
-func (s *ServicePGX) Register(ctx context.Context, email, password, name string) (*AuthResponse, error) {
+func (s *ServicePGX) Register(ctx context.Context, email, password, name string, client ClientInfo) (*AuthResponse, error) {
+	email, ok := normalizeEmail(email)
+	if !ok {
+		return nil, ErrInvalidEmail
+	}
+
+	if err := s.policy.Check(password, email); err != nil {
+		return nil, err
+	}
+
 	existing, _ := s.users.FindByEmail(ctx, email)
 	if existing != nil {
-		return nil, errors.New("email already exists")
+		return nil, ErrEmailTaken
 	}
 
 	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
@@ -46,51 +156,270 @@ func (s *ServicePGX) Register(ctx context.Context, email, password, name string)
 		return nil, err
 	}
 
-	token, err := s.jwt.GenerateToken(uint(u.ID))
+	s.sendVerificationAsync(u)
+	s.recordUserEvent(ctx, EventRegistered, u.ID, client, map[string]any{"method": "password"})
+
+	return s.issue(ctx, u, client)
+}
+
+// Login checks an email and password. The email is normalized the way
+// Register stores it; one that could not have been registered matches no
+// account. Log lines name the user id, never the email.
+func (s *ServicePGX) Login(ctx context.Context, email, password string, client ClientInfo) (*AuthResponse, error) {
+	email, ok := normalizeEmail(email)
+	if !ok {
+		return nil, repo.ErrInvalidCredentials
+	}
+	if err := s.throttle.Check(ctx, email, client.IP); err != nil {
+		log.Printf("LOGIN throttled ip=%s: %v", client.IP, err)
+		s.recordLoginFailure(ctx, nil, email, "locked", client)
+		return nil, err
+	}
+
+	u, err := s.users.FindByEmail(ctx, email)
 	if err != nil {
+		log.Printf("LOGIN FindByEmail error: %v", err)
 		return nil, err
 	}
+	if u == nil {
+		log.Printf("LOGIN unknown user ip=%s", client.IP)
+		s.throttle.Failure(ctx, email, client.IP)
+		s.recordLoginFailure(ctx, nil, email, "unknown_user", client)
+		return nil, repo.ErrInvalidCredentials
+	}
 
-	return &AuthResponse{
-		Token: token,
-		User:  UserDTO{ID: u.ID, Email: u.Email, Name: u.Name},
-	}, nil
+	if err := bcrypt.CompareHashAndPassword([]byte(u.PasswordHash), []byte(password)); err != nil {
+		log.Printf("LOGIN user=%d wrong password ip=%s", u.ID, client.IP)
+		s.throttle.Failure(ctx, email, client.IP)
+		s.recordLoginFailure(ctx, u, email, "bad_password", client)
+		return nil, repo.ErrInvalidCredentials
+	}
+
+	if u.Suspended() {
+		log.Printf("LOGIN user=%d suspended", u.ID)
+		s.recordLoginFailure(ctx, u, email, "suspended", client)
+		return nil, ErrAccountSuspended
+	}
+
+	if u.TwoFactorEnabled() {
+		return nil, s.twoFactorChallenge(u)
+	}
+
+	s.throttle.Success(ctx, email)
+	return s.issueLogin(ctx, u, "password", client)
 }
 
-func (s *ServicePGX) Login(ctx context.Context, email, password string) (*AuthResponse, error) {
- log.Printf("LOGIN email=%q pass_len=%d", email, len(password))
- u, err := s.users.FindByEmail(ctx, email)
- if err != nil {
-	log.Printf("LOGIN FindByEmail error: %v", err)
-  return nil, err 
- }
- if u == nil {
-	log.Printf("LOGIN user not found")
-  return nil, repo.ErrInvalidCredentials
- }
+// Refresh rotates a refresh token and returns a fresh token pair. Reusing an
+// already rotated token revokes the session it belonged to.
+func (s *ServicePGX) Refresh(ctx context.Context, refreshToken string) (*AuthResponse, error) {
+	if refreshToken == "" {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	next, err := newOpaqueToken()
+	if err != nil {
+		return nil, err
+	}
+
+	sess, err := s.sessions.Rotate(ctx, hashToken(refreshToken), hashToken(next), time.Now().Add(s.opts.RefreshTTL))
+	switch {
+	case errors.Is(err, repo.ErrRefreshTokenReused):
+		log.Printf("REFRESH token reuse detected, session revoked")
+		return nil, ErrInvalidRefreshToken
+	case errors.Is(err, repo.ErrSessionNotFound), errors.Is(err, repo.ErrRefreshTokenExpired):
+		return nil, ErrInvalidRefreshToken
+	case err != nil:
+		return nil, err
+	}
+
+	u, err := s.users.FindByID(ctx, sess.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if u == nil {
+		return nil, ErrInvalidRefreshToken
+	}
+	if u.Suspended() {
+		return nil, ErrAccountSuspended
+	}
+
+	return s.tokens(u, sess.ID, next)
+}
+
+// Logout revokes the session identified by refreshToken, or by sessionID when
+// no refresh token is given.
+func (s *ServicePGX) Logout(ctx context.Context, refreshToken string, userID int, sessionID int64) error {
+	if refreshToken != "" {
+		sess, err := s.sessions.FindByRefreshHash(ctx, hashToken(refreshToken))
+		if err != nil {
+			return err
+		}
+		if sess == nil {
+			return ErrInvalidRefreshToken
+		}
+		return s.sessions.Revoke(ctx, sess.ID, sess.UserID, "logout")
+	}
+	if sessionID == 0 {
+		return ErrInvalidRefreshToken
+	}
+	return s.sessions.Revoke(ctx, sessionID, userID, "logout")
+}
+
+// ChangePassword replaces the password after checking the current one with
+// checkPassword, revokes every session of the user and opens a new one for
+// the caller.
+func (s *ServicePGX) ChangePassword(ctx context.Context, userID int, current, password string, client ClientInfo) (*AuthResponse, error) {
+	u, err := s.users.FindByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if u == nil {
+		return nil, ErrUserNotFound
+	}
+	if u.PasswordHash == "" {
+		return nil, ErrNoPassword
+	}
+
+	if err := s.checkPassword(ctx, u, current, client); err != nil {
+		return nil, err
+	}
+	if err := s.policy.Check(password, u.Email); err != nil {
+		return nil, err
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.users.UpdatePassword(ctx, userID, string(hash)); err != nil {
+		return nil, err
+	}
+	if err := s.sessions.RevokeAllForUser(ctx, userID, "password_change"); err != nil {
+		return nil, err
+	}
+	s.recordUserEvent(ctx, EventPasswordChanged, userID, client, nil)
+
+	return s.issue(ctx, u, client)
+}
 
- if err := bcrypt.CompareHashAndPassword([]byte(u.PasswordHash), []byte(password)); err != nil {
-	log.Printf("LOGIN bcrypt compare error: %v", err)
-  return nil, repo.ErrInvalidCredentials
- }
+// checkPassword verifies the password of a signed-in user before a sensitive
+// change. Wrong guesses count towards the login lockout, so a stolen access
+// token cannot be used to brute-force the password.
+func (s *ServicePGX) checkPassword(ctx context.Context, u *repo.User, password string, client ClientInfo) error {
+	if err := s.throttle.Check(ctx, u.Email, client.IP); err != nil {
+		return err
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(u.PasswordHash), []byte(password)); err != nil {
+		s.throttle.Failure(ctx, u.Email, client.IP)
+		return repo.ErrInvalidCredentials
+	}
+	return nil
+}
 
- token, err := s.jwt.GenerateToken(uint(u.ID))
- if err != nil {
-  return nil, err
- }
+func (s *ServicePGX) Sessions(ctx context.Context, userID int) ([]repo.Session, error) {
+	return s.sessions.ListActive(ctx, userID)
+}
 
- return &AuthResponse{
-  Token: token,
-  User:  UserDTO{ID: u.ID, Email: u.Email, Name: u.Name},
- }, nil
+func (s *ServicePGX) RevokeSession(ctx context.Context, userID int, sessionID int64, client ClientInfo) error {
+	if err := s.sessions.Revoke(ctx, sessionID, userID, "revoked_by_user"); err != nil {
+		return err
+	}
+	s.recordUserEvent(ctx, EventSessionRevoked, userID, client, map[string]any{"sessionId": sessionID})
+	return nil
 }
 
+func (s *ServicePGX) RevokeOtherSessions(ctx context.Context, userID int, currentID int64, client ClientInfo) (int64, error) {
+	n, err := s.sessions.RevokeOthers(ctx, userID, currentID, "revoked_by_user")
+	if err != nil {
+		return 0, err
+	}
+	if n > 0 {
+		s.recordUserEvent(ctx, EventSessionRevoked, userID, client, map[string]any{"allExcept": currentID, "count": n})
+	}
+	return n, nil
+}
 
 func (s *ServicePGX) Me(ctx context.Context, userID int) (*UserDTO, error) {
-	u, _ := s.users.FindByID(ctx, userID)
+	u, err := s.users.FindByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
 	if u == nil {
-		return nil, errors.New("user not found")
+		return nil, ErrUserNotFound
 	}
-	dto := &UserDTO{ID: u.ID, Email: u.Email, Name: u.Name}
-	return dto, nil
+	dto := toUserDTO(u)
+	return &dto, nil
+}
+
+// UpdateName changes the display name and records the change.
+func (s *ServicePGX) UpdateName(ctx context.Context, userID int, name string, client ClientInfo) error {
+	previous, ok, err := s.users.UpdateName(ctx, userID, name)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrUserNotFound
+	}
+	if previous != name {
+		s.recordUserEvent(ctx, EventNameChanged, userID, client, map[string]any{"from": previous, "to": name})
+	}
+	return nil
+}
+
+// issueLogin opens a session for a completed sign-in and records it.
+func (s *ServicePGX) issueLogin(ctx context.Context, u *repo.User, method string, client ClientInfo) (*AuthResponse, error) {
+	resp, err := s.issue(ctx, u, client)
+	if err != nil {
+		return nil, err
+	}
+	s.recordUserEvent(ctx, EventLoginSucceeded, u.ID, client, map[string]any{"method": method})
+	return resp, nil
+}
+
+// recordLoginFailure records a failed sign-in. u is nil when the email
+// matched no account.
+func (s *ServicePGX) recordLoginFailure(ctx context.Context, u *repo.User, email, reason string, client ClientInfo) {
+	e := repo.AuditEvent{
+		Type:      EventLoginFailed,
+		IP:        client.IP,
+		UserAgent: client.UserAgent,
+		Details:   map[string]any{"email": email, "reason": reason},
+	}
+	if u != nil {
+		e.UserID = &u.ID
+	}
+	s.recordAudit(ctx, e)
+}
+
+// issue opens a new session for u and returns its token pair.
+func (s *ServicePGX) issue(ctx context.Context, u *repo.User, client ClientInfo) (*AuthResponse, error) {
+	if u.Suspended() {
+		return nil, ErrAccountSuspended
+	}
+
+	refresh, err := newOpaqueToken()
+	if err != nil {
+		return nil, err
+	}
+
+	sess, err := s.sessions.Create(ctx, u.ID, client.UserAgent, client.IP, hashToken(refresh), time.Now().Add(s.opts.RefreshTTL))
+	if err != nil {
+		return nil, err
+	}
+
+	return s.tokens(u, sess.ID, refresh)
+}
+
+func (s *ServicePGX) tokens(u *repo.User, sessionID int64, refresh string) (*AuthResponse, error) {
+	token, err := s.jwt.GenerateToken(uint(u.ID), sessionID, u.Role)
+	if err != nil {
+		return nil, err
+	}
+
+	return &AuthResponse{
+		Token:        token,
+		RefreshToken: refresh,
+		ExpiresIn:    int(s.jwt.AccessTTL.Seconds()),
+		User:         toUserDTO(u),
+	}, nil
 }
@@ -0,0 +1,145 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"bookpulse/internal/mail"
+	"bookpulse/internal/repo"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+type discardMailer struct{}
+
+func (discardMailer) Send(context.Context, mail.Message) error { return nil }
+
+// newTestService wires the service to a fresh memory database, the way
+// main does for the memory driver.
+func newTestService(t *testing.T) (*ServicePGX, Stores) {
+	t.Helper()
+	return newTestServiceOn(t, repo.NewMemoryDB())
+}
+
+func newTestServiceOn(t *testing.T, mem *repo.MemoryDB) (*ServicePGX, Stores) {
+	t.Helper()
+	st := Stores{
+		Users:     repo.NewUserMemory(mem),
+		Sessions:  repo.NewSessionMemory(mem),
+		Resets:    repo.NewPasswordResetMemory(mem),
+		TwoFactor: repo.NewTwoFactorMemory(mem),
+		Tokens:    repo.NewAccessTokenMemory(mem),
+		Audit:     repo.NewAuditMemory(mem),
+		Export:    repo.NewExportMemory(mem),
+	}
+	jwt := NewJWT("test-secret-test-secret-test-secret", 15*time.Minute)
+	jwt.Sessions = st.Sessions
+	jwt.Tokens = st.Tokens
+	jwt.Users = st.Users
+	throttle := NewLoginThrottle(repo.NewLoginAttemptMemory(), ThrottleOptions{
+		AccountFreeFailures: 5,
+		IPFreeFailures:      20,
+		BaseLockout:         time.Minute,
+		MaxLockout:          time.Hour,
+		Window:              time.Hour,
+	})
+	s := NewServicePGX(st, jwt, discardMailer{}, throttle, Options{RefreshTTL: time.Hour, PasswordMinLength: 8})
+	return s, st
+}
+
+const testPassword = "correct horse battery"
+
+func newTestUser(t *testing.T, st Stores, email string) *repo.User {
+	t.Helper()
+	hash, err := bcrypt.GenerateFromPassword([]byte(testPassword), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	u, err := st.Users.Create(context.Background(), email, "", string(hash))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return u
+}
+
+// authenticate runs Authenticate on a request carrying token as bearer.
+func authenticate(s *ServicePGX, token string) (*Principal, bool) {
+	r := httptest.NewRequest("GET", "/api/me", nil)
+	r.Header.Set("Authorization", "Bearer "+token)
+	return Authenticate(r, s.jwt)
+}
+
+func TestRefreshReuseRevokesSession(t *testing.T) {
+	s, st := newTestService(t)
+	ctx := context.Background()
+	newTestUser(t, st, "ann@example.com")
+
+	login, err := s.Login(ctx, "ann@example.com", testPassword, ClientInfo{IP: "127.0.0.1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	next, err := s.Refresh(ctx, login.RefreshToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := authenticate(s, next.Token); !ok {
+		t.Fatal("fresh access token rejected")
+	}
+
+	if _, err := s.Refresh(ctx, login.RefreshToken); !errors.Is(err, ErrInvalidRefreshToken) {
+		t.Fatalf("reusing a rotated refresh token = %v; want ErrInvalidRefreshToken", err)
+	}
+	if _, err := s.Refresh(ctx, next.RefreshToken); !errors.Is(err, ErrInvalidRefreshToken) {
+		t.Errorf("refresh after reuse = %v; want the session revoked", err)
+	}
+	if _, ok := authenticate(s, next.Token); ok {
+		t.Error("access token of a revoked session accepted")
+	}
+}
+
+func TestEmailIgnoresCase(t *testing.T) {
+	s, _ := newTestService(t)
+	ctx := context.Background()
+	client := ClientInfo{IP: "127.0.0.1"}
+
+	reg, err := s.Register(ctx, " Alice@Example.com ", testPassword, "Alice", client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reg.User.Email != "alice@example.com" {
+		t.Errorf("stored email = %q; want it lowercased", reg.User.Email)
+	}
+	if _, err := s.Register(ctx, "alice@example.com", testPassword, "Other", client); !errors.Is(err, ErrEmailTaken) {
+		t.Errorf("second Register = %v; want ErrEmailTaken", err)
+	}
+	login, err := s.Login(ctx, "ALICE@example.COM", testPassword, client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if login.User.ID != reg.User.ID {
+		t.Errorf("Login with other case reached user %d; want %d", login.User.ID, reg.User.ID)
+	}
+}
+
+func TestLoginLockoutIgnoresEmailCase(t *testing.T) {
+	s, st := newTestService(t)
+	ctx := context.Background()
+	newTestUser(t, st, "ann@example.com")
+
+	// a new IP per attempt, so only the account counter can lock
+	for i, email := range []string{"Ann@example.com", "ANN@EXAMPLE.COM", "ann@Example.com", "Ann@Example.Com", "aNN@example.com", "ann@EXAMPLE.com"} {
+		client := ClientInfo{IP: fmt.Sprintf("10.0.0.%d", i+1)}
+		if _, err := s.Login(ctx, email, "wrong password", client); !errors.Is(err, repo.ErrInvalidCredentials) {
+			t.Fatalf("attempt %d = %v; want ErrInvalidCredentials", i+1, err)
+		}
+	}
+
+	var locked *TooManyAttemptsError
+	if _, err := s.Login(ctx, "ann@example.com", testPassword, ClientInfo{IP: "10.0.1.1"}); !errors.As(err, &locked) {
+		t.Errorf("Login after failures in other cases = %v; want the account locked", err)
+	}
+}
@@ -0,0 +1,217 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"log"
+	"strings"
+	"time"
+
+	"bookpulse/internal/oidc"
+	"bookpulse/internal/repo"
+)
+
+const oidcStateTTL = 10 * time.Minute
+
+var (
+	ErrUnknownProvider  = errors.New("unknown identity provider")
+	ErrLastLoginMethod  = errors.New("cannot unlink the only way to sign in; set a password first")
+	ErrEmailNotVerified = errors.New("the provider did not confirm this email; sign in with your password and link the account from your profile")
+)
+
+// SocialResult is the outcome of an OIDC callback: either a login (Auth) or,
+// for a link flow, Linked set to true.
+type SocialResult struct {
+	Auth   *AuthResponse
+	Linked bool
+}
+
+// SocialLogin signs users in through OpenID Connect providers and manages
+// the identities linked to an account.
+type SocialLogin struct {
+	svc        *ServicePGX
+	identities repo.IdentityRepo
+	providers  map[string]*oidc.Provider
+}
+
+func NewSocialLogin(svc *ServicePGX, identities repo.IdentityRepo, providers map[string]*oidc.Provider) *SocialLogin {
+	return &SocialLogin{svc: svc, identities: identities, providers: providers}
+}
+
+func (s *SocialLogin) Providers() []string {
+	out := make([]string, 0, len(s.providers))
+	for name := range s.providers {
+		out = append(out, name)
+	}
+	return out
+}
+
+// Start returns the provider authorization URL for a login, or for linking
+// to linkUserID when it is non-zero.
+func (s *SocialLogin) Start(ctx context.Context, provider string, linkUserID int) (string, error) {
+	p, ok := s.providers[provider]
+	if !ok {
+		return "", ErrUnknownProvider
+	}
+
+	state, err := oidc.RandomString()
+	if err != nil {
+		return "", err
+	}
+	nonce, err := oidc.RandomString()
+	if err != nil {
+		return "", err
+	}
+	verifier, err := oidc.RandomString()
+	if err != nil {
+		return "", err
+	}
+
+	st := repo.OIDCState{Provider: provider, CodeVerifier: verifier, Nonce: nonce}
+	if linkUserID != 0 {
+		st.LinkUserID = &linkUserID
+	}
+
+	authURL, err := p.AuthCodeURL(ctx, state, nonce, verifier)
+	if err != nil {
+		return "", err
+	}
+	if err := s.identities.SaveState(ctx, hashToken(state), st, time.Now().Add(oidcStateTTL)); err != nil {
+		return "", err
+	}
+	return authURL, nil
+}
+
+// Callback finishes the flow started by Start. For logins it finds the
+// linked account, links by email when both sides have verified it, or
+// creates a new account.
+func (s *SocialLogin) Callback(ctx context.Context, provider, code, state string, client ClientInfo) (*SocialResult, error) {
+	p, ok := s.providers[provider]
+	if !ok {
+		return nil, ErrUnknownProvider
+	}
+
+	st, err := s.identities.ConsumeState(ctx, hashToken(state))
+	if err != nil {
+		return nil, err
+	}
+	if st.Provider != provider {
+		return nil, repo.ErrOIDCStateInvalid
+	}
+
+	claims, err := p.Exchange(ctx, code, st.CodeVerifier, st.Nonce)
+	if err != nil {
+		return nil, err
+	}
+	email := strings.TrimSpace(claims.Email)
+
+	if st.LinkUserID != nil {
+		if err := s.identities.Link(ctx, *st.LinkUserID, provider, claims.Subject, email); err != nil {
+			return nil, err
+		}
+		log.Printf("OIDC %s linked user=%d", provider, *st.LinkUserID)
+		s.svc.recordUserEvent(ctx, EventIdentityLinked, *st.LinkUserID, client, map[string]any{"provider": provider})
+		return &SocialResult{Linked: true}, nil
+	}
+
+	userID, err := s.identities.FindUserID(ctx, provider, claims.Subject)
+	if err != nil {
+		return nil, err
+	}
+
+	var u *repo.User
+	if userID != 0 {
+		u, err = s.svc.users.FindByID(ctx, userID)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if u == nil {
+		u, err = s.findOrCreate(ctx, provider, claims, client)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if u.TwoFactorEnabled() {
+		return nil, s.svc.twoFactorChallenge(u)
+	}
+
+	resp, err := s.svc.issueLogin(ctx, u, "oidc:"+provider, client)
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("OIDC %s login user=%d", provider, u.ID)
+	return &SocialResult{Auth: resp}, nil
+}
+
+func (s *SocialLogin) findOrCreate(ctx context.Context, provider string, claims *oidc.IDClaims, client ClientInfo) (*repo.User, error) {
+	email, ok := normalizeEmail(claims.Email)
+	if !ok {
+		return nil, ErrInvalidEmail
+	}
+
+	existing, err := s.svc.users.FindByEmail(ctx, email)
+	if err != nil {
+		return nil, err
+	}
+
+	u := existing
+	if u != nil {
+		// only auto-link when neither side can be a spoofed address
+		if !claims.Verified() || !u.EmailVerified() {
+			return nil, ErrEmailNotVerified
+		}
+	} else {
+		name := strings.TrimSpace(claims.Name)
+		if name == "" {
+			name = "User"
+		}
+		u, err = s.svc.users.CreateExternal(ctx, email, name, claims.Verified())
+		if err != nil {
+			return nil, err
+		}
+		if !u.EmailVerified() {
+			s.svc.sendVerificationAsync(u)
+		}
+		s.svc.recordUserEvent(ctx, EventRegistered, u.ID, client, map[string]any{"method": "oidc:" + provider})
+	}
+
+	if err := s.identities.Link(ctx, u.ID, provider, claims.Subject, email); err != nil {
+		return nil, err
+	}
+	s.svc.recordUserEvent(ctx, EventIdentityLinked, u.ID, client, map[string]any{"provider": provider, "byEmail": existing != nil})
+	return u, nil
+}
+
+func (s *SocialLogin) Identities(ctx context.Context, userID int) ([]repo.Identity, error) {
+	return s.identities.ListForUser(ctx, userID)
+}
+
+// Unlink removes a linked identity unless it is the account's only way in.
+func (s *SocialLogin) Unlink(ctx context.Context, userID int, provider string, client ClientInfo) error {
+	u, err := s.svc.users.FindByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if u == nil {
+		return ErrUserNotFound
+	}
+
+	if u.PasswordHash == "" {
+		list, err := s.identities.ListForUser(ctx, userID)
+		if err != nil {
+			return err
+		}
+		if len(list) <= 1 {
+			return ErrLastLoginMethod
+		}
+	}
+
+	if err := s.identities.Unlink(ctx, userID, provider); err != nil {
+		return err
+	}
+	s.svc.recordUserEvent(ctx, EventIdentityUnlinked, userID, client, map[string]any{"provider": provider})
+	return nil
+}
@@ -0,0 +1,190 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"bookpulse/internal/oidc"
+	"bookpulse/internal/oidc/oidctest"
+	"bookpulse/internal/repo"
+)
+
+type socialHarness struct {
+	svc    *ServicePGX
+	st     Stores
+	social *SocialLogin
+	srv    *oidctest.Server
+}
+
+// newTestSocial wires SocialLogin to a local mock provider named "mock".
+func newTestSocial(t *testing.T) *socialHarness {
+	t.Helper()
+	mem := repo.NewMemoryDB()
+	svc, st := newTestServiceOn(t, mem)
+	srv := oidctest.NewServer("bookpulse")
+	t.Cleanup(srv.Close)
+	p := oidc.NewProvider("mock", srv.Config("http://localhost/auth/oidc/mock/callback"), srv.Client())
+	social := NewSocialLogin(svc, repo.NewIdentityMemory(mem), map[string]*oidc.Provider{"mock": p})
+	return &socialHarness{svc: svc, st: st, social: social, srv: srv}
+}
+
+// signIn runs the whole flow as user; linkUserID starts a link flow.
+func (h *socialHarness) signIn(t *testing.T, user oidctest.User, linkUserID int) (*SocialResult, error) {
+	t.Helper()
+	ctx := context.Background()
+	authURL, err := h.social.Start(ctx, "mock", linkUserID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	code, state, err := h.srv.Authorize(authURL, user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return h.social.Callback(ctx, "mock", code, state, ClientInfo{IP: "127.0.0.1"})
+}
+
+// newPasswordUser registers email and, if verified, confirms the address.
+func (h *socialHarness) newPasswordUser(t *testing.T, email string, verified bool) *repo.User {
+	t.Helper()
+	ctx := context.Background()
+	reg, err := h.svc.Register(ctx, email, testPassword, "Alice", ClientInfo{IP: "127.0.0.1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if verified {
+		if _, err := h.st.Users.MarkEmailVerified(ctx, reg.User.ID, reg.User.Email); err != nil {
+			t.Fatal(err)
+		}
+	}
+	u, err := h.st.Users.FindByID(ctx, reg.User.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return u
+}
+
+func TestSocialLoginCreatesAccount(t *testing.T) {
+	h := newTestSocial(t)
+	user := oidctest.User{Subject: "sub-1", Email: "New@Example.com", EmailVerified: true, Name: "New"}
+
+	res, err := h.signIn(t, user, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Auth == nil || res.Auth.User.Email != "new@example.com" || !res.Auth.User.EmailVerified {
+		t.Fatalf("result = %+v; want a login to a new verified account", res)
+	}
+
+	again, err := h.signIn(t, user, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again.Auth.User.ID != res.Auth.User.ID {
+		t.Errorf("second login reached user %d; want %d", again.Auth.User.ID, res.Auth.User.ID)
+	}
+}
+
+func TestSocialLoginLinksByVerifiedEmail(t *testing.T) {
+	h := newTestSocial(t)
+	// registered with capitals; providers such as Google send lowercase
+	u := h.newPasswordUser(t, "Alice@Example.com", true)
+
+	res, err := h.signIn(t, oidctest.User{Subject: "sub-1", Email: "alice@example.com", EmailVerified: true}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Auth == nil || res.Auth.User.ID != u.ID {
+		t.Fatalf("result = %+v; want a login to user %d", res, u.ID)
+	}
+	ids, err := h.social.Identities(context.Background(), u.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ids) != 1 || ids[0].Provider != "mock" {
+		t.Errorf("identities = %+v; want mock linked", ids)
+	}
+}
+
+func TestSocialLoginRequiresVerifiedEmails(t *testing.T) {
+	tests := []struct {
+		name             string
+		accountVerified  bool
+		providerVerified bool
+	}{
+		{"account unverified", false, true},
+		{"provider unverified", true, false},
+	}
+	for _, tt := range tests {
+		h := newTestSocial(t)
+		u := h.newPasswordUser(t, "alice@example.com", tt.accountVerified)
+
+		user := oidctest.User{Subject: "sub-1", Email: "alice@example.com", EmailVerified: tt.providerVerified}
+		if _, err := h.signIn(t, user, 0); !errors.Is(err, ErrEmailNotVerified) {
+			t.Errorf("%s: Callback = %v; want ErrEmailNotVerified", tt.name, err)
+		}
+		if ids, _ := h.social.Identities(context.Background(), u.ID); len(ids) != 0 {
+			t.Errorf("%s: identities = %+v; want none linked", tt.name, ids)
+		}
+	}
+}
+
+func TestSocialLinkAndUnlink(t *testing.T) {
+	h := newTestSocial(t)
+	ctx := context.Background()
+	client := ClientInfo{IP: "127.0.0.1"}
+	u := h.newPasswordUser(t, "alice@example.com", false)
+	// the provider account may have any address once linked explicitly
+	user := oidctest.User{Subject: "sub-1", Email: "alice.work@example.com"}
+
+	res, err := h.signIn(t, user, u.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !res.Linked {
+		t.Fatalf("result = %+v; want Linked", res)
+	}
+	login, err := h.signIn(t, user, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if login.Auth.User.ID != u.ID {
+		t.Errorf("login through the linked identity reached user %d; want %d", login.Auth.User.ID, u.ID)
+	}
+
+	// the password still signs in, so the only identity may go
+	if err := h.social.Unlink(ctx, u.ID, "mock", client); err != nil {
+		t.Fatal(err)
+	}
+	if ids, _ := h.social.Identities(ctx, u.ID); len(ids) != 0 {
+		t.Errorf("identities after Unlink = %+v; want none", ids)
+	}
+}
+
+func TestSocialUnlinkLastLoginMethod(t *testing.T) {
+	h := newTestSocial(t)
+	res, err := h.signIn(t, oidctest.User{Subject: "sub-1", Email: "bob@example.com", EmailVerified: true}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = h.social.Unlink(context.Background(), res.Auth.User.ID, "mock", ClientInfo{})
+	if !errors.Is(err, ErrLastLoginMethod) {
+		t.Errorf("Unlink of the only login method = %v; want ErrLastLoginMethod", err)
+	}
+}
+
+func TestSocialCallbackRejectsUnknownState(t *testing.T) {
+	h := newTestSocial(t)
+	ctx := context.Background()
+	authURL, err := h.social.Start(ctx, "mock", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	code, _, err := h.srv.Authorize(authURL, oidctest.User{Subject: "sub-1", Email: "bob@example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := h.social.Callback(ctx, "mock", code, "forged", ClientInfo{}); err == nil {
+		t.Error("Callback with a forged state succeeded")
+	}
+}
@@ -0,0 +1,70 @@
+package auth
+
+import (
+	"testing"
+	"time"
+)
+
+// rfcSecret is the SHA-1 key of the RFC 6238 test vectors.
+var rfcSecret = b32.EncodeToString([]byte("12345678901234567890"))
+
+func TestTOTPCodeRFC6238(t *testing.T) {
+	// RFC 6238 appendix B, truncated to our six digits
+	vectors := []struct {
+		unix int64
+		code string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+	}
+	for _, v := range vectors {
+		got, err := totpCode(rfcSecret, v.unix/totpPeriod)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != v.code {
+			t.Errorf("totpCode at %d = %s; want %s", v.unix, got, v.code)
+		}
+	}
+}
+
+func TestMatchTOTP(t *testing.T) {
+	now := time.Unix(1111111111, 0)
+	step := now.Unix() / totpPeriod
+	code := func(counter int64) string {
+		c, err := totpCode(rfcSecret, counter)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return c
+	}
+
+	tests := []struct {
+		name    string
+		code    string
+		want    int64
+		matched bool
+	}{
+		{"current step", code(step), step, true},
+		{"previous step", code(step - 1), step - 1, true},
+		{"next step", code(step + 1), step + 1, true},
+		{"too old", code(step - 2), 0, false},
+		{"too new", code(step + 2), 0, false},
+		{"spaces", " " + code(step)[:3] + " " + code(step)[3:] + " ", step, true},
+		{"too short", code(step)[:5], 0, false},
+		{"empty", "", 0, false},
+	}
+	for _, tt := range tests {
+		got, ok := matchTOTP(rfcSecret, tt.code, now)
+		if ok != tt.matched || got != tt.want {
+			t.Errorf("%s: matchTOTP(%q) = %d, %v; want %d, %v", tt.name, tt.code, got, ok, tt.want, tt.matched)
+		}
+	}
+
+	if _, ok := matchTOTP("not base32!", code(step), now); ok {
+		t.Error("matchTOTP accepted a code for an invalid secret")
+	}
+}
@@ -0,0 +1,241 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"bookpulse/internal/repo"
+)
+
+const (
+	totpIssuer         = "BookPulse"
+	twoFactorChallenge = 5 * time.Minute
+)
+
+var (
+	ErrTwoFactorEnabled     = errors.New("two-factor authentication is already enabled")
+	ErrTwoFactorNotEnabled  = errors.New("two-factor authentication is not enabled")
+	ErrTwoFactorNotPending  = errors.New("start two-factor setup first")
+	ErrInvalidTwoFactorCode = errors.New("invalid two-factor code")
+	ErrInvalidChallenge     = errors.New("invalid or expired login challenge")
+)
+
+// TwoFactorRequiredError is returned by Login when the password was right
+// but a second factor is still needed. ChallengeToken is exchanged via
+// LoginTwoFactor.
+type TwoFactorRequiredError struct {
+	ChallengeToken string
+	ExpiresIn      int
+}
+
+func (e *TwoFactorRequiredError) Error() string {
+	return "two-factor authentication required"
+}
+
+type TwoFactorSetup struct {
+	Secret     string `json:"secret"`
+	OTPAuthURI string `json:"otpauthUri"`
+}
+
+type TwoFactorStatus struct {
+	Enabled           bool `json:"enabled"`
+	RecoveryCodesLeft int  `json:"recoveryCodesLeft"`
+}
+
+func (s *ServicePGX) twoFactorChallenge(u *repo.User) error {
+	token, err := s.jwt.GeneratePurposeToken(uint(u.ID), PurposeTwoFactorChallenge, u.Email, twoFactorChallenge)
+	if err != nil {
+		return err
+	}
+	return &TwoFactorRequiredError{ChallengeToken: token, ExpiresIn: int(twoFactorChallenge.Seconds())}
+}
+
+// LoginTwoFactor completes a login started by Login using either a TOTP code
+// or a recovery code.
+func (s *ServicePGX) LoginTwoFactor(ctx context.Context, challenge, code, recoveryCode string, client ClientInfo) (*AuthResponse, error) {
+	claims, err := s.jwt.ParsePurposeToken(challenge, PurposeTwoFactorChallenge)
+	if err != nil {
+		return nil, ErrInvalidChallenge
+	}
+
+	u, err := s.users.FindByID(ctx, int(claims.UserID))
+	if err != nil {
+		return nil, err
+	}
+	if u == nil || u.Email != claims.Email || !u.TwoFactorEnabled() {
+		return nil, ErrInvalidChallenge
+	}
+
+	if err := s.throttle.Check(ctx, u.Email, client.IP); err != nil {
+		return nil, err
+	}
+	if err := s.verifySecondFactor(ctx, u.ID, code, recoveryCode); err != nil {
+		log.Printf("LOGIN 2FA user=%d failed: %v", u.ID, err)
+		if errors.Is(err, ErrInvalidTwoFactorCode) {
+			s.throttle.Failure(ctx, u.Email, client.IP)
+			s.recordLoginFailure(ctx, u, u.Email, "bad_2fa_code", client)
+		}
+		return nil, err
+	}
+
+	s.throttle.Success(ctx, u.Email)
+	method := "password+totp"
+	if recoveryCode != "" {
+		method = "password+recovery_code"
+	}
+	return s.issueLogin(ctx, u, method, client)
+}
+
+func (s *ServicePGX) TwoFactorStatus(ctx context.Context, userID int) (*TwoFactorStatus, error) {
+	st, err := s.totp.Get(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if st == nil || !st.Enabled() {
+		return &TwoFactorStatus{}, nil
+	}
+	left, err := s.totp.RecoveryCodesLeft(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	return &TwoFactorStatus{Enabled: true, RecoveryCodesLeft: left}, nil
+}
+
+// BeginTwoFactorSetup generates a new secret. 2FA stays off until the user
+// proves their app works via ConfirmTwoFactor.
+func (s *ServicePGX) BeginTwoFactorSetup(ctx context.Context, userID int) (*TwoFactorSetup, error) {
+	u, err := s.users.FindByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if u == nil {
+		return nil, ErrUserNotFound
+	}
+	if u.TwoFactorEnabled() {
+		return nil, ErrTwoFactorEnabled
+	}
+
+	secret, err := newTOTPSecret()
+	if err != nil {
+		return nil, err
+	}
+	ok, err := s.totp.SetPending(ctx, userID, secret)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrTwoFactorEnabled
+	}
+
+	return &TwoFactorSetup{Secret: secret, OTPAuthURI: totpURI(totpIssuer, u.Email, secret)}, nil
+}
+
+// ConfirmTwoFactor enables 2FA once the first code checks out and returns the
+// recovery codes. They are shown only this once.
+func (s *ServicePGX) ConfirmTwoFactor(ctx context.Context, userID int, code string, client ClientInfo) ([]string, error) {
+	st, err := s.totp.Get(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if st == nil || st.Secret == "" {
+		return nil, ErrTwoFactorNotPending
+	}
+	if st.Enabled() {
+		return nil, ErrTwoFactorEnabled
+	}
+
+	counter, ok := matchTOTP(st.Secret, code, time.Now())
+	if !ok {
+		return nil, ErrInvalidTwoFactorCode
+	}
+
+	codes, err := newRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	hashes := make([]string, 0, len(codes))
+	for _, c := range codes {
+		hashes = append(hashes, hashRecoveryCode(c))
+	}
+
+	if err := s.totp.Enable(ctx, userID, counter, hashes); err != nil {
+		return nil, err
+	}
+	log.Printf("2FA enabled user=%d", userID)
+	s.recordUserEvent(ctx, EventTwoFactorEnabled, userID, client, nil)
+	return codes, nil
+}
+
+// DisableTwoFactor requires re-authentication: the account password (when it
+// has one) plus a current TOTP or recovery code. Accounts that only sign in
+// through a provider have no password, so the code alone is enough for them.
+// Wrong passwords and codes count towards the login lockout.
+func (s *ServicePGX) DisableTwoFactor(ctx context.Context, userID int, password, code, recoveryCode string, client ClientInfo) error {
+	u, err := s.users.FindByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if u == nil {
+		return ErrUserNotFound
+	}
+	if !u.TwoFactorEnabled() {
+		return ErrTwoFactorNotEnabled
+	}
+
+	if u.PasswordHash != "" {
+		if err := s.checkPassword(ctx, u, password, client); err != nil {
+			return err
+		}
+	} else if err := s.throttle.Check(ctx, u.Email, client.IP); err != nil {
+		return err
+	}
+	if err := s.verifySecondFactor(ctx, userID, code, recoveryCode); err != nil {
+		if errors.Is(err, ErrInvalidTwoFactorCode) {
+			s.throttle.Failure(ctx, u.Email, client.IP)
+		}
+		return err
+	}
+
+	if err := s.totp.Disable(ctx, userID); err != nil {
+		return err
+	}
+	log.Printf("2FA disabled user=%d", userID)
+	s.recordUserEvent(ctx, EventTwoFactorDisabled, userID, client, nil)
+	return nil
+}
+
+func (s *ServicePGX) verifySecondFactor(ctx context.Context, userID int, code, recoveryCode string) error {
+	if recoveryCode != "" {
+		ok, err := s.totp.UseRecoveryCode(ctx, userID, hashRecoveryCode(recoveryCode))
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return ErrInvalidTwoFactorCode
+		}
+		return nil
+	}
+
+	st, err := s.totp.Get(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if st == nil || !st.Enabled() {
+		return ErrTwoFactorNotEnabled
+	}
+
+	counter, ok := matchTOTP(st.Secret, code, time.Now())
+	if !ok {
+		return ErrInvalidTwoFactorCode
+	}
+	fresh, err := s.totp.UseCounter(ctx, userID, counter)
+	if err != nil {
+		return err
+	}
+	if !fresh {
+		return ErrInvalidTwoFactorCode
+	}
+	return nil
+}
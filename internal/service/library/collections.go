@@ -0,0 +1,103 @@
+package library
+
+import (
+	"context"
+
+	"bookpulse/internal/models"
+	"bookpulse/internal/repo"
+)
+
+func (s *Service) Collections(ctx context.Context, userID int) ([]models.MyCollectionDTO, error) {
+	return s.collections.List(ctx, userID)
+}
+
+// CreateCollection adds the books to the user's collection called name,
+// creating it if there is none. Every book must be on the user's shelf;
+// otherwise nothing is written.
+func (s *Service) CreateCollection(ctx context.Context, userID int, name string, bookIDs []int) (int, error) {
+	books := make([]BookRef, len(bookIDs))
+	for i, id := range bookIDs {
+		books[i] = BookRef{ID: id}
+	}
+	ids, err := s.shelved(ctx, userID, books)
+	if err != nil {
+		return 0, err
+	}
+	return s.collections.Upsert(ctx, userID, name, ids)
+}
+
+// AddToCollection adds the books, named by Google id, to one of the user's
+// collections. Every book must be on the user's shelf; otherwise nothing is
+// written.
+func (s *Service) AddToCollection(ctx context.Context, userID, collectionID int, googleIDs []string) error {
+	exists, err := s.collections.Exists(ctx, userID, collectionID)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return repo.ErrCollectionNotFound
+	}
+
+	books := make([]BookRef, len(googleIDs))
+	for i, gid := range googleIDs {
+		books[i] = BookRef{GoogleID: gid}
+	}
+	ids, err := s.shelved(ctx, userID, books)
+	if err != nil {
+		return err
+	}
+	return s.collections.AddBooks(ctx, userID, collectionID, ids)
+}
+
+// RenameCollection fails with repo.ErrCollectionNameTaken if the user
+// already has a collection called name.
+func (s *Service) RenameCollection(ctx context.Context, userID, collectionID int, name string) error {
+	return s.collections.Rename(ctx, userID, collectionID, name)
+}
+
+// DeleteCollection deletes one of the user's collections. The books in it
+// stay on the shelf.
+func (s *Service) DeleteCollection(ctx context.Context, userID, collectionID int) error {
+	return s.collections.Delete(ctx, userID, collectionID)
+}
+
+// RemoveFromCollection takes a book out of one of the user's collections.
+func (s *Service) RemoveFromCollection(ctx context.Context, userID, collectionID int, book BookRef) error {
+	exists, err := s.collections.Exists(ctx, userID, collectionID)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return repo.ErrCollectionNotFound
+	}
+	bookID, err := s.resolve(ctx, book)
+	if err != nil {
+		return err
+	}
+	if err := s.collections.RemoveBook(ctx, userID, collectionID, bookID); err != nil {
+		return bookError(err, book)
+	}
+	return nil
+}
+
+// shelved resolves the books to ids and checks that each is on the user's
+// shelf, so the first bad book is reported before anything is written.
+func (s *Service) shelved(ctx context.Context, userID int, books []BookRef) ([]int, error) {
+	ids := make([]int, 0, len(books))
+	for _, book := range books {
+		bookID, err := s.resolve(ctx, book)
+		if err != nil {
+			return nil, err
+		}
+
+		inLib, err := s.library.Contains(ctx, userID, bookID)
+		if err != nil {
+			return nil, err
+		}
+		if !inLib {
+			return nil, bookError(repo.ErrNotInLibrary, book)
+		}
+		ids = append(ids, bookID)
+	}
+	return ids, nil
+}
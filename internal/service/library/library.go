@@ -0,0 +1,192 @@
+// Package library holds the rules for users' shelves, collections, reading
+// stats and book reviews. Persistence goes through the repo interfaces, so
+// any storage backend can sit behind it.
+package library
+
+import (
+	"context"
+	"strconv"
+	"strings"
+
+	"bookpulse/internal/models"
+	"bookpulse/internal/repo"
+	"bookpulse/internal/utils"
+)
+
+// DefaultStatus is the reading status of a book added without one.
+const DefaultStatus = "planned"
+
+// Stores groups the repositories the library service persists to.
+type Stores struct {
+	Books       repo.BookRepo
+	Library     repo.LibraryRepo
+	Collections repo.CollectionRepo
+	Stats       repo.StatsRepo
+}
+
+type Service struct {
+	books       repo.BookRepo
+	library     repo.LibraryRepo
+	collections repo.CollectionRepo
+	stats       repo.StatsRepo
+}
+
+func NewService(stores Stores) *Service {
+	return &Service{
+		books:       stores.Books,
+		library:     stores.Library,
+		collections: stores.Collections,
+		stats:       stores.Stats,
+	}
+}
+
+// BookError ties repo.ErrBookNotFound, repo.ErrNotInLibrary or
+// repo.ErrNotInCollection to the book the request named, by id or by Google
+// id.
+type BookError struct {
+	Err      error
+	BookID   int
+	GoogleID string
+}
+
+func (e *BookError) Error() string {
+	if e.GoogleID != "" {
+		return e.Err.Error() + ": " + e.GoogleID
+	}
+	return e.Err.Error() + ": " + strconv.Itoa(e.BookID)
+}
+
+func (e *BookError) Unwrap() error {
+	return e.Err
+}
+
+// NewBook is a book as the client saw it in Google Books search results.
+type NewBook struct {
+	GoogleID      string
+	Title         string
+	Author        string
+	CoverURL      string
+	Description   string
+	Categories    []string
+	PublishedYear int
+	PageCount     int
+	// Maturity is Google's maturityRating, "MATURE" or "NOT_MATURE".
+	Maturity string
+}
+
+// BookRef names a book by id or, when ID is 0, by Google id.
+type BookRef struct {
+	ID       int
+	GoogleID string
+}
+
+func (s *Service) Books(ctx context.Context, userID int) ([]models.MyBookDTO, error) {
+	return s.library.List(ctx, userID)
+}
+
+// AddBook saves the book to the catalogue, files it under its genres and
+// puts it on the user's shelf with status (DefaultStatus if empty), all or
+// nothing. Adding a book again refreshes its details and sets the status.
+func (s *Service) AddBook(ctx context.Context, userID int, b NewBook, status string) (int, error) {
+	if status == "" {
+		status = DefaultStatus
+	}
+
+	return s.library.Add(ctx, userID, repo.Book{
+		GoogleID:      b.GoogleID,
+		Title:         b.Title,
+		Author:        b.Author,
+		CoverURL:      b.CoverURL,
+		Description:   b.Description,
+		PublishedYear: b.PublishedYear,
+		PageCount:     b.PageCount,
+		AgeRating:     utils.MaturityToAge(b.Maturity),
+		Genres:        genresOf(b.Categories),
+	}, status)
+}
+
+// genresOf turns Google categories such as "Fiction / Science Fiction /
+// General" into genres: the first two levels, minus the catch-all
+// "General", without duplicates.
+func genresOf(categories []string) []string {
+	var out []string
+	seen := map[string]bool{}
+	for _, raw := range categories {
+		parts := strings.Split(raw, "/")
+		for i := 0; i < len(parts) && i < 2; i++ {
+			g := strings.TrimSpace(parts[i])
+			if g == "" || g == "General" || seen[g] {
+				continue
+			}
+			seen[g] = true
+			out = append(out, g)
+		}
+	}
+	return out
+}
+
+func (s *Service) SetStatus(ctx context.Context, userID int, book BookRef, status string) error {
+	bookID, err := s.resolve(ctx, book)
+	if err != nil {
+		return err
+	}
+	if err := s.library.SetStatus(ctx, userID, bookID, status); err != nil {
+		return bookError(err, book)
+	}
+	return nil
+}
+
+// RemoveBook takes the book off the user's shelf and out of their
+// collections. Their review of the book goes too, unless keepReview is set.
+func (s *Service) RemoveBook(ctx context.Context, userID int, book BookRef, keepReview bool) error {
+	bookID, err := s.resolve(ctx, book)
+	if err != nil {
+		return err
+	}
+	if err := s.library.Remove(ctx, userID, bookID, keepReview); err != nil {
+		return bookError(err, book)
+	}
+	return nil
+}
+
+func (s *Service) resolve(ctx context.Context, book BookRef) (int, error) {
+	if book.ID != 0 {
+		return book.ID, nil
+	}
+	id, err := s.books.IDByGoogleID(ctx, book.GoogleID)
+	if err != nil {
+		return 0, bookError(err, book)
+	}
+	return id, nil
+}
+
+// bookError names book in the book-level errors of the repos.
+func bookError(err error, book BookRef) error {
+	switch err {
+	case repo.ErrBookNotFound, repo.ErrNotInLibrary, repo.ErrNotInCollection:
+		return &BookError{Err: err, BookID: book.ID, GoogleID: book.GoogleID}
+	}
+	return err
+}
+
+// Stats is the reading summary shown on the profile page.
+type Stats struct {
+	Genres []models.GenreStatDto `json:"genres"`
+	Months []models.MonthStatDto `json:"months"`
+}
+
+// statsMonths is how many calendar months, the current one included, the
+// monthly chart covers.
+const statsMonths = 6
+
+func (s *Service) Stats(ctx context.Context, userID int) (*Stats, error) {
+	genres, err := s.stats.FinishedByGenre(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	months, err := s.stats.AddedByMonth(ctx, userID, statsMonths)
+	if err != nil {
+		return nil, err
+	}
+	return &Stats{Genres: genres, Months: months}, nil
+}
@@ -0,0 +1,125 @@
+package library
+
+import (
+	"context"
+	"errors"
+	"log"
+	"strings"
+
+	"bookpulse/internal/models"
+	"bookpulse/internal/repo"
+)
+
+var ErrEmailNotVerified = errors.New("verify your email to post reviews")
+
+// EventReviewDeleted is the audit event of a moderator deleting a review.
+const EventReviewDeleted = "moderation.review_deleted"
+
+// reviewTimeLayout is how review dates are shown.
+const reviewTimeLayout = "2006-01-02 15:04"
+
+type ReviewOptions struct {
+	// RequireVerifiedEmail keeps users with an unverified email from posting.
+	RequireVerifiedEmail bool
+}
+
+// Moderator is the moderator acting on a review, for the audit trail.
+type Moderator struct {
+	UserID    int
+	IP        string
+	UserAgent string
+}
+
+// Reviews holds the rules for book reviews.
+type Reviews struct {
+	books   repo.BookRepo
+	reviews repo.ReviewRepo
+	audit   repo.AuditRepo
+	opts    ReviewOptions
+}
+
+func NewReviews(books repo.BookRepo, reviews repo.ReviewRepo, audit repo.AuditRepo, opts ReviewOptions) *Reviews {
+	return &Reviews{books: books, reviews: reviews, audit: audit, opts: opts}
+}
+
+// ForBook lists the reviews of the book, newest first, marking those by
+// viewerID (0 for anonymous viewers).
+func (s *Reviews) ForBook(ctx context.Context, googleID string, viewerID int) ([]models.ReviewDto, error) {
+	bookID, err := s.bookID(ctx, googleID)
+	if err != nil {
+		return nil, err
+	}
+
+	list, err := s.reviews.ListForBook(ctx, bookID, viewerID)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]models.ReviewDto, 0, len(list))
+	for i := range list {
+		out = append(out, toReviewDTO(&list[i]))
+	}
+	return out, nil
+}
+
+// Post creates or replaces the author's review of the book.
+func (s *Reviews) Post(ctx context.Context, author *repo.User, googleID string, rating int, text string) (*models.ReviewDto, error) {
+	if s.opts.RequireVerifiedEmail && !author.EmailVerified() {
+		return nil, ErrEmailNotVerified
+	}
+	bookID, err := s.bookID(ctx, googleID)
+	if err != nil {
+		return nil, err
+	}
+
+	rv, err := s.reviews.Upsert(ctx, author.ID, bookID, rating, strings.TrimSpace(text))
+	if err != nil {
+		return nil, err
+	}
+	dto := toReviewDTO(rv)
+	return &dto, nil
+}
+
+// Delete removes a review on a moderator's behalf and records it in the
+// audit log against the author.
+func (s *Reviews) Delete(ctx context.Context, reviewID int, mod Moderator) error {
+	d, err := s.reviews.Delete(ctx, reviewID)
+	if err != nil {
+		return err
+	}
+
+	e := repo.AuditEvent{
+		Type:      EventReviewDeleted,
+		ActorID:   &mod.UserID,
+		IP:        mod.IP,
+		UserAgent: mod.UserAgent,
+		Details:   map[string]any{"reviewId": reviewID, "bookId": d.BookID},
+	}
+	if d.AuthorID != 0 {
+		e.UserID = &d.AuthorID
+	}
+	// the review is gone either way; a failed write is only logged
+	if err := s.audit.Record(ctx, e); err != nil {
+		log.Printf("AUDIT write error type=%s: %v", e.Type, err)
+	}
+	log.Printf("MODERATION review=%d deleted by user=%d", reviewID, mod.UserID)
+	return nil
+}
+
+func (s *Reviews) bookID(ctx context.Context, googleID string) (int, error) {
+	id, err := s.books.IDByGoogleID(ctx, googleID)
+	if err != nil {
+		return 0, bookError(err, BookRef{GoogleID: googleID})
+	}
+	return id, nil
+}
+
+func toReviewDTO(rv *repo.Review) models.ReviewDto {
+	return models.ReviewDto{
+		ID:        rv.ID,
+		UserName:  rv.UserName,
+		CreatedAt: rv.CreatedAt.Format(reviewTimeLayout),
+		Rating:    rv.Rating,
+		Text:      rv.Text,
+		Mine:      rv.Mine,
+	}
+}
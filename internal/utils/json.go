@@ -1,16 +0,0 @@
-package utils
-
-import (
-	"encoding/json"
-	"net/http"
-)
-
-func WriteJSON(w http.ResponseWriter, v any) {
-	b, err := json.Marshal(v)
-	if err != nil {
-		http.Error(w, "JSON error: "+err.Error(), http.StatusInternalServerError)
-		return
-	}
-	w.WriteHeader(http.StatusOK)
-	_, _ = w.Write(b)
-}
@@ -1,53 +1,156 @@
 package main
 
 import (
-	"bookpulse/internal/db"
+	"bookpulse/internal/config"
 	"bookpulse/internal/google"
-	"bookpulse/internal/handlers"
+	"bookpulse/internal/mail"
 	"bookpulse/internal/middleware"
+	"bookpulse/internal/oidc"
 	"bookpulse/internal/repo"
+	"bookpulse/internal/request"
 	"bookpulse/internal/service/auth"
+	"bookpulse/internal/service/library"
+	"context"
+	"errors"
+	"flag"
 	"log"
 	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 )
 
 func main() {
-	db.InitDB()
-	googleBooks := google.NewGoogleBooksHandler()
-	defer db.DBpool.Close()
-	http.HandleFunc("/api/health", handlers.Health)
-
-	jwtSecret := "dev_secret_change_me"
-	jwt := auth.NewJWT(jwtSecret)
-	userRepo := repo.NewUserRepoPGX(db.DBpool)
-	authSvc := auth.NewServicePGX(userRepo, jwt)
-
-	http.HandleFunc("/api/books/google", googleBooks.Search)
-
-    http.HandleFunc("/api/books/google/", googleBooks.GetByID)
-
-	http.HandleFunc("/api/auth/register", handlers.Register(authSvc))
-
-	http.HandleFunc("/api/auth/login", handlers.Authorization(authSvc))
-	
-	http.HandleFunc("/api/auth/me", handlers.CurrentUser(authSvc, jwt))
-
-	http.HandleFunc("/api/me/profile", handlers.UpdateName(jwt))
-
-	http.HandleFunc("/api/me/password", handlers.UpdatePassword(jwt))
-
-	http.HandleFunc("/api/me/books", handlers.GetAndAddMyBook(jwt))
-
-	http.HandleFunc("/api/me/books/status", handlers.SetStatus(jwt))
-
-	http.HandleFunc("/api/me/collections", handlers.GetAndAddCollection(jwt))
-
-	http.HandleFunc("/api/me/collections/add-books", handlers.AddBookToCollection(jwt)) 
-
-	http.HandleFunc("/api/me/stats", handlers.StatsHandler(jwt)) 
-
-	http.HandleFunc("/api/books/reviews/", handlers.BooksReviewsHandler(jwt))
+	configPath := flag.String("config", "", "path to YAML config file (defaults to $BOOKPULSE_CONFIG)")
+	flag.Parse()
+
+	cfg, err := config.Load(*configPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if args := flag.Args(); len(args) > 0 {
+		switch args[0] {
+		case "migrate":
+			runMigrate(cfg, args[1:])
+			return
+		case "admin":
+			runAdmin(cfg, args[1:])
+			return
+		}
+	}
+
+	st := openStores(cfg)
+	if st.migrate != nil && cfg.DB.AutoMigrate {
+		if err := st.migrate(context.Background()); err != nil {
+			log.Fatal("Не удалось применить миграции:", err)
+		}
+	}
+
+	googleBooks := google.NewGoogleBooksHandler(cfg.Google.BaseURL, cfg.Google.APIKey, cfg.Google.Timeout)
+
+	jwt := auth.NewJWT(cfg.JWT.Secret, cfg.JWT.AccessTTL)
+	jwt.Issuer = cfg.JWT.Issuer
+	jwt.Sessions = st.auth.Sessions
+	jwt.Users = st.auth.Users
+	if len(cfg.JWT.Keys) > 0 {
+		files := make([]auth.KeyFile, 0, len(cfg.JWT.Keys))
+		for _, k := range cfg.JWT.Keys {
+			files = append(files, auth.KeyFile{ID: k.ID, Path: k.File})
+		}
+		keys, err := auth.LoadKeySet(files, cfg.JWT.SigningKey)
+		if err != nil {
+			log.Fatal(err)
+		}
+		jwt.Keys = keys
+	}
+	jwt.Tokens = st.auth.Tokens
+	authSvc := auth.NewServicePGX(st.auth, jwt, newMailer(cfg), newLoginThrottle(cfg, st.loginAttempts), auth.Options{
+		RefreshTTL: cfg.JWT.RefreshTTL,
+		ResetTTL:   cfg.Auth.PasswordResetTTL,
+		AppBaseURL: cfg.App.BaseURL,
+
+		PasswordMinLength: cfg.Auth.PasswordMinLength,
+
+		VerificationTTL:            cfg.Auth.EmailVerificationTTL,
+		VerificationResendInterval: cfg.Auth.VerificationResendInterval,
+
+		DeletionGracePeriod: cfg.Account.DeletionGracePeriod,
+		AnonymizeReviews:    cfg.Account.ReviewsOnDelete == config.ReviewsAnonymize,
+	})
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	purgerDone := make(chan struct{})
+	go func() {
+		authSvc.RunPurger(ctx, cfg.Account.PurgeInterval)
+		close(purgerDone)
+	}()
+
+	oidcProviders := map[string]*oidc.Provider{}
+	for name, p := range cfg.OIDC.Providers {
+		oidcProviders[name] = oidc.NewProvider(name, oidc.Config{
+			Issuer:       p.Issuer,
+			ClientID:     p.ClientID,
+			ClientSecret: p.ClientSecret,
+			RedirectURL:  p.RedirectURL,
+			Scopes:       p.Scopes,
+		}, nil)
+	}
+	social := auth.NewSocialLogin(authSvc, st.identities, oidcProviders)
+
+	lib := library.NewService(st.library)
+	reviews := library.NewReviews(st.library.Books, st.reviews, st.auth.Audit, library.ReviewOptions{
+		RequireVerifiedEmail: cfg.Auth.RequireVerifiedEmailForReviews,
+	})
+
+	var handler http.Handler = routes(cfg, jwt, authSvc, social, googleBooks, lib, reviews)
+	handler = request.WithOptions(handler, request.Options{
+		MaxBodyBytes: int64(cfg.HTTP.MaxBodyBytes),
+		Strict:       cfg.HTTP.StrictJSON,
+	})
+	handler = middleware.WithCORS(handler, cfg.CORS.AllowedOrigins)
+	if cfg.HTTP.TrustProxy {
+		handler = middleware.WithRealIP(handler, cfg.HTTP.TrustedProxies)
+	}
+	handler = middleware.WithRequestID(handler)
+
+	srv := &http.Server{Addr: cfg.HTTP.Addr, Handler: handler}
+	// on SIGINT/SIGTERM let open requests and a running purge finish
+	// before closing the stores
+	drained := make(chan struct{})
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		_ = srv.Shutdown(shutdownCtx)
+		close(drained)
+	}()
+
+	log.Printf("BookPulse (%s) listening on %s", cfg.Env, cfg.HTTP.Addr)
+	if err := srv.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+		log.Fatal(err)
+	}
+	<-drained
+	<-purgerDone
+	st.close()
+}
 
-	log.Fatal(http.ListenAndServe(":8080", middleware.WithCORS(http.DefaultServeMux)))
+func newLoginThrottle(cfg *config.Config, store repo.LoginAttemptStore) *auth.LoginThrottle {
+	lt := cfg.Auth.LoginThrottle
+	return auth.NewLoginThrottle(store, auth.ThrottleOptions{
+		AccountFreeFailures: lt.AccountFreeFailures,
+		IPFreeFailures:      lt.IPFreeFailures,
+		BaseLockout:         lt.BaseLockout,
+		MaxLockout:          lt.MaxLockout,
+		Window:              lt.Window,
+	})
 }
 
+func newMailer(cfg *config.Config) mail.Mailer {
+	if cfg.Mail.Driver == config.MailDriverSMTP {
+		smtp := cfg.Mail.SMTP
+		return mail.NewSMTPMailer(smtp.Host, smtp.Port, smtp.Username, smtp.Password, cfg.Mail.From)
+	}
+	return mail.NewLogMailer(cfg.Mail.Dir, cfg.Mail.From)
+}
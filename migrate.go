@@ -0,0 +1,90 @@
+package main
+
+import (
+	"bookpulse/internal/config"
+	"bookpulse/internal/db"
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+)
+
+// migrator is implemented by db.Migrator and db.SQLiteMigrator.
+type migrator interface {
+	Up(ctx context.Context) (int, error)
+	Down(ctx context.Context, steps int) (int, error)
+	Status(ctx context.Context) ([]db.MigrationStatus, error)
+}
+
+func openMigrator(cfg *config.Config) (migrator, func(), error) {
+	switch cfg.DB.Driver {
+	case config.StorePostgres:
+		db.InitDB(cfg.DB.DSN)
+		m, err := db.NewMigrator(db.DBpool)
+		return m, db.DBpool.Close, err
+	case config.StoreSQLite:
+		sqlDB, err := db.OpenSQLite(cfg.DB.DSN)
+		if err != nil {
+			return nil, nil, err
+		}
+		m, err := db.NewSQLiteMigrator(sqlDB)
+		return m, func() { sqlDB.Close() }, err
+	}
+	return nil, nil, fmt.Errorf("the %s db driver has no migrations", cfg.DB.Driver)
+}
+
+// runMigrate implements `bookpulse migrate [up|down [N]|status]`.
+func runMigrate(cfg *config.Config, args []string) {
+	cmd := "up"
+	if len(args) > 0 {
+		cmd = args[0]
+	}
+
+	m, closeDB, err := openMigrator(cfg)
+	if err != nil {
+		log.Fatal("migrate: ", err)
+	}
+	defer closeDB()
+	ctx := context.Background()
+
+	switch cmd {
+	case "up":
+		n, err := m.Up(ctx)
+		if err != nil {
+			log.Fatal("migrate up: ", err)
+		}
+		fmt.Printf("applied %d migration(s)\n", n)
+
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			steps, err = strconv.Atoi(args[1])
+			if err != nil || steps <= 0 {
+				log.Fatalf("migrate down: bad step count %q", args[1])
+			}
+		}
+		n, err := m.Down(ctx, steps)
+		if err != nil {
+			log.Fatal("migrate down: ", err)
+		}
+		fmt.Printf("reverted %d migration(s)\n", n)
+
+	case "status":
+		list, err := m.Status(ctx)
+		if err != nil {
+			log.Fatal("migrate status: ", err)
+		}
+		for _, st := range list {
+			applied := "pending"
+			if st.AppliedAt != nil {
+				applied = st.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Printf("%04d  %-30s %s\n", st.Version, st.Name, applied)
+		}
+
+	default:
+		fmt.Fprintln(os.Stderr, "usage: bookpulse [-config file] migrate [up|down [N]|status]")
+		os.Exit(2)
+	}
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"bookpulse/internal/config"
+	"bookpulse/internal/google"
+	"bookpulse/internal/handlers"
+	"bookpulse/internal/middleware"
+	"bookpulse/internal/router"
+	"bookpulse/internal/service/auth"
+	"bookpulse/internal/service/library"
+)
+
+// routes builds the API. Each handler serves one method; the mux answers
+// other methods with 405 and an Allow header. CORS preflights never get
+// here, middleware.WithCORS answers them.
+//
+// Every route is public, optional-auth (middleware.Authenticate) or
+// required-auth (RequireAuth, or RequireSession where access tokens must
+// not be accepted); handlers read the caller from the request context.
+func routes(cfg *config.Config, jwt *auth.JWT, authSvc *auth.ServicePGX, social *auth.SocialLogin, googleBooks *google.GoogleBooksHandler, lib *library.Service, reviews *library.Reviews) *router.Router {
+	optionalAuth := middleware.Authenticate(jwt)
+	requireAuth := middleware.RequireAuth(jwt)
+	requireSession := middleware.RequireSession(jwt)
+
+	r := router.New()
+
+	r.Get("/api/health", handlers.Health)
+	r.Get("/.well-known/jwks.json", handlers.JWKS(jwt))
+
+	books := r.Group("/api/books")
+	books.Get("/google", googleBooks.Search)
+	books.Get("/google/{id}", googleBooks.GetByID)
+	books.Group("", optionalAuth).Get("/reviews/{googleId}", handlers.ListBookReviews(reviews))
+	books.Group("", requireAuth).Post("/reviews/{googleId}", handlers.PostBookReview(reviews))
+
+	// polled by the frontend, so kept out of the rate-limited group
+	r.Group("", requireAuth).Get("/api/auth/me", handlers.CurrentUser(authSvc))
+
+	limit := cfg.HTTP.AuthRateLimit
+	authn := r.Group("/api/auth", middleware.RateLimit(limit.Requests, limit.Window))
+	authn.Post("/register", handlers.Register(authSvc))
+	authn.Post("/login", handlers.Authorization(authSvc))
+	authn.Post("/login/2fa", handlers.LoginTwoFactor(authSvc))
+	authn.Post("/refresh", handlers.Refresh(authSvc))
+	authn.Group("", optionalAuth).Post("/logout", handlers.Logout(authSvc))
+	authn.Post("/forgot-password", handlers.ForgotPassword(authSvc))
+	authn.Post("/reset-password", handlers.ResetPassword(authSvc))
+	authn.Post("/verify-email", handlers.VerifyEmail(authSvc))
+	authn.Group("", requireSession).Post("/resend-verification", handlers.ResendVerification(authSvc))
+	authn.Get("/oidc/{provider}/start", handlers.OIDCStart(social))
+	authn.Post("/oidc/{provider}/callback", handlers.OIDCCallback(social))
+
+	// library data; personal access tokens are accepted within their scopes
+	library := r.Group("/api/me", requireAuth)
+	library.Get("/books", handlers.ListMyBooks(lib))
+	library.Post("/books", handlers.AddMyBook(lib))
+	library.Patch("/books/{googleId}", handlers.SetStatus(lib))
+	library.Patch("/books/status", handlers.SetStatus(lib)) // deprecated, googleId in the body
+	library.Delete("/books/{googleId}", handlers.RemoveMyBook(lib))
+
+	library.Get("/collections", handlers.ListCollections(lib))
+	library.Post("/collections", handlers.CreateCollection(lib))
+	library.Patch("/collections/{id}", handlers.RenameCollection(lib))
+	library.Delete("/collections/{id}", handlers.DeleteCollection(lib))
+	library.Post("/collections/{id}/books", handlers.AddBookToCollection(lib))
+	library.Delete("/collections/{id}/books/{googleId}", handlers.RemoveBookFromCollection(lib))
+	library.Post("/collections/add-books", handlers.AddBookToCollection(lib)) // deprecated, collectionId in the body
+
+	library.Get("/stats", handlers.StatsHandler(lib))
+
+	// account management, signed-in sessions only
+	account := r.Group("/api/me", requireSession)
+	account.Patch("/profile", handlers.UpdateName(authSvc))
+	account.Patch("/password", handlers.UpdatePassword(authSvc))
+
+	account.Get("/sessions", handlers.ListSessions(authSvc))
+	account.Delete("/sessions", handlers.RevokeOtherSessions(authSvc))
+	account.Delete("/sessions/{id}", handlers.RevokeSession(authSvc))
+
+	account.Get("/2fa", handlers.TwoFactorStatus(authSvc))
+	account.Post("/2fa/setup", handlers.SetupTwoFactor(authSvc))
+	account.Post("/2fa/confirm", handlers.ConfirmTwoFactor(authSvc))
+	account.Post("/2fa/disable", handlers.DisableTwoFactor(authSvc))
+
+	account.Get("/export", handlers.ExportAccount(authSvc))
+	account.Post("/delete", handlers.DeleteAccount(authSvc))
+	account.Post("/restore", handlers.RestoreAccount(authSvc))
+
+	account.Get("/tokens", handlers.ListAccessTokens(authSvc))
+	account.Post("/tokens", handlers.CreateAccessToken(authSvc))
+	account.Delete("/tokens/{id}", handlers.RevokeAccessToken(authSvc))
+
+	account.Get("/identities", handlers.ListIdentities(social))
+	account.Post("/identities/{provider}", handlers.LinkIdentity(social))
+	account.Delete("/identities/{provider}", handlers.UnlinkIdentity(social))
+
+	account.Get("/security-events", handlers.SecurityEvents(authSvc))
+
+	admin := r.Group("/api/admin", middleware.RequireRole(jwt, auth.RoleAdmin))
+	admin.Get("/users", handlers.AdminListUsers(authSvc))
+	admin.Get("/users/{id}", handlers.AdminGetUser(authSvc))
+	admin.Delete("/users/{id}", handlers.AdminDeleteUser(authSvc))
+	admin.Patch("/users/{id}/role", handlers.AdminSetRole(authSvc))
+	admin.Post("/users/{id}/suspend", handlers.AdminSuspendUser(authSvc))
+	admin.Post("/users/{id}/unsuspend", handlers.AdminUnsuspendUser(authSvc))
+	admin.Post("/users/{id}/force-password-reset", handlers.AdminForcePasswordReset(authSvc))
+	admin.Get("/audit-events", handlers.AdminAuditEvents(authSvc))
+
+	moderation := r.Group("/api/moderation", middleware.RequireRole(jwt, auth.RoleModerator))
+	moderation.Delete("/reviews/{id}", handlers.DeleteReview(reviews))
+
+	return r
+}
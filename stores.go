@@ -0,0 +1,160 @@
+package main
+
+import (
+	"bookpulse/internal/config"
+	"bookpulse/internal/db"
+	"bookpulse/internal/repo"
+	"bookpulse/internal/service/auth"
+	"bookpulse/internal/service/library"
+	"context"
+	"log"
+	"time"
+)
+
+// stores holds every repository, on the driver picked by db.driver.
+type stores struct {
+	auth          auth.Stores
+	library       library.Stores
+	reviews       repo.ReviewRepo
+	identities    repo.IdentityRepo
+	loginAttempts repo.LoginAttemptStore
+
+	// migrate applies pending schema migrations; nil for the memory driver.
+	migrate func(ctx context.Context) error
+	// close releases the database; the memory driver saves its snapshot.
+	close func()
+}
+
+func openStores(cfg *config.Config) *stores {
+	switch cfg.DB.Driver {
+	case config.StoreMemory:
+		return openMemoryStores(cfg)
+	case config.StoreSQLite:
+		return openSQLiteStores(cfg)
+	}
+
+	db.InitDB(cfg.DB.DSN)
+	books := repo.NewBookRepoPGX(db.DBpool)
+	s := &stores{
+		auth: auth.Stores{
+			Users:     repo.NewUserRepoPGX(db.DBpool),
+			Sessions:  repo.NewSessionRepoPGX(db.DBpool),
+			Resets:    repo.NewPasswordResetRepoPGX(db.DBpool),
+			TwoFactor: repo.NewTwoFactorRepoPGX(db.DBpool),
+			Tokens:    repo.NewAccessTokenRepoPGX(db.DBpool),
+			Audit:     repo.NewAuditRepoPGX(db.DBpool),
+			Export:    repo.NewExportRepoPGX(db.DBpool),
+		},
+		library: library.Stores{
+			Books:       books,
+			Library:     repo.NewLibraryRepoPGX(db.DBpool),
+			Collections: repo.NewCollectionRepoPGX(db.DBpool),
+			Stats:       repo.NewStatsRepoPGX(db.DBpool),
+		},
+		reviews:       repo.NewReviewRepoPGX(db.DBpool),
+		identities:    repo.NewIdentityRepoPGX(db.DBpool),
+		loginAttempts: repo.NewLoginAttemptRepoPGX(db.DBpool),
+		migrate:       db.Migrate,
+		close:         db.DBpool.Close,
+	}
+	if cfg.Auth.LoginThrottle.Store == config.StoreMemory {
+		s.loginAttempts = repo.NewLoginAttemptMemory()
+	}
+	return s
+}
+
+func openSQLiteStores(cfg *config.Config) *stores {
+	sqlDB, err := db.OpenSQLite(cfg.DB.DSN)
+	if err != nil {
+		log.Fatalf("sqlite %s: %v", cfg.DB.DSN, err)
+	}
+	log.Printf("Using the SQLite database %s", cfg.DB.DSN)
+
+	return &stores{
+		auth: auth.Stores{
+			Users:     repo.NewUserRepoSQLite(sqlDB),
+			Sessions:  repo.NewSessionRepoSQLite(sqlDB),
+			Resets:    repo.NewPasswordResetRepoSQLite(sqlDB),
+			TwoFactor: repo.NewTwoFactorRepoSQLite(sqlDB),
+			Tokens:    repo.NewAccessTokenRepoSQLite(sqlDB),
+			Audit:     repo.NewAuditRepoSQLite(sqlDB),
+			Export:    repo.NewExportRepoSQLite(sqlDB),
+		},
+		library: library.Stores{
+			Books:       repo.NewBookRepoSQLite(sqlDB),
+			Library:     repo.NewLibraryRepoSQLite(sqlDB),
+			Collections: repo.NewCollectionRepoSQLite(sqlDB),
+			Stats:       repo.NewStatsRepoSQLite(sqlDB),
+		},
+		reviews:       repo.NewReviewRepoSQLite(sqlDB),
+		identities:    repo.NewIdentityRepoSQLite(sqlDB),
+		loginAttempts: repo.NewLoginAttemptMemory(),
+		migrate: func(ctx context.Context) error {
+			return db.MigrateSQLite(ctx, sqlDB)
+		},
+		close: func() {
+			if err := sqlDB.Close(); err != nil {
+				log.Printf("sqlite %s: %v", cfg.DB.DSN, err)
+			}
+		},
+	}
+}
+
+func openMemoryStores(cfg *config.Config) *stores {
+	mem := repo.NewMemoryDB()
+	s := &stores{
+		auth: auth.Stores{
+			Users:     repo.NewUserMemory(mem),
+			Sessions:  repo.NewSessionMemory(mem),
+			Resets:    repo.NewPasswordResetMemory(mem),
+			TwoFactor: repo.NewTwoFactorMemory(mem),
+			Tokens:    repo.NewAccessTokenMemory(mem),
+			Audit:     repo.NewAuditMemory(mem),
+			Export:    repo.NewExportMemory(mem),
+		},
+		library: library.Stores{
+			Books:       repo.NewBookMemory(mem),
+			Library:     repo.NewLibraryMemory(mem),
+			Collections: repo.NewCollectionMemory(mem),
+			Stats:       repo.NewStatsMemory(mem),
+		},
+		reviews:       repo.NewReviewMemory(mem),
+		identities:    repo.NewIdentityMemory(mem),
+		loginAttempts: repo.NewLoginAttemptMemory(),
+		close:         func() {},
+	}
+
+	path := cfg.DB.Snapshot
+	if path == "" {
+		log.Println("Using the in-memory database; data is lost on exit")
+		return s
+	}
+	if err := mem.Load(path); err != nil {
+		log.Fatalf("snapshot %s: %v", path, err)
+	}
+	log.Printf("Using the in-memory database, saved to %s", path)
+
+	save := func() {
+		if err := mem.Save(path); err != nil {
+			log.Printf("snapshot %s: %v", path, err)
+		}
+	}
+	done := make(chan struct{})
+	go func() {
+		t := time.NewTicker(cfg.DB.SnapshotInterval)
+		defer t.Stop()
+		for {
+			select {
+			case <-t.C:
+				save()
+			case <-done:
+				return
+			}
+		}
+	}()
+	s.close = func() {
+		close(done)
+		save()
+	}
+	return s
+}